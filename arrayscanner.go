@@ -0,0 +1,216 @@
+package fastjson
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ArrayScanner scans elements of a huge JSON array read from io.Reader.
+//
+// Each element is parsed separately, so memory usage is bounded
+// by the largest element instead of the whole input.
+//
+// The array may be either the top-level value or a value nested in objects
+// at the given Path. The rest of the input is validated after the last
+// element.
+//
+// ArrayScanner may be re-used for subsequent scanning after Init call.
+//
+// ArrayScanner cannot be used from concurrent goroutines.
+type ArrayScanner struct {
+	// Path contains object keys leading to the array.
+	//
+	// For instance, Path: []string{"data", "items"} scans the array
+	// from {"data":{"items":[...]}}. The top-level array is scanned
+	// if Path is empty.
+	Path []string
+
+	// t reads tokens from the input.
+	t Tokenizer
+
+	// p parses array elements.
+	p Parser
+
+	// b contains the current element.
+	b []byte
+
+	// v contains the current parsed element.
+	v *Value
+
+	// err contains the last error.
+	err error
+
+	// opened is set after the array start is found.
+	opened bool
+
+	// depth is the depth of the array.
+	depth int
+
+	// index is the number of elements read so far.
+	index int
+}
+
+// Init initializes as for scanning the array read from r.
+//
+// Path setting is preserved.
+func (as *ArrayScanner) Init(r io.Reader) {
+	as.t.Reset(r)
+	as.b = as.b[:0]
+	as.v = nil
+	as.err = nil
+	as.opened = false
+	as.depth = 0
+	as.index = 0
+}
+
+// Next parses the next array element.
+//
+// Returns true on success. The parsed element is available via Value call.
+//
+// Returns false either on error or on the end of the array.
+// Call Error in order to determine the cause of the returned false.
+func (as *ArrayScanner) Next() bool {
+	if as.err != nil {
+		return false
+	}
+	as.v = nil
+	if !as.opened {
+		if !as.open() {
+			return false
+		}
+		as.opened = true
+	}
+	t := &as.t
+	if !t.Next() {
+		as.err = as.tokenizerError()
+		return false
+	}
+	if t.Kind() == TokenEndArray && t.Depth() == as.depth {
+		// Validate the rest of the input.
+		for t.Next() {
+		}
+		as.err = t.Error()
+		if as.err == nil {
+			as.err = io.EOF
+		}
+		return false
+	}
+	if !as.readElement() {
+		return false
+	}
+	v, err := as.p.ParseBytes(as.b)
+	if err != nil {
+		as.err = fmt.Errorf("cannot parse array element #%d: %s", as.index, err)
+		return false
+	}
+	as.v = v
+	as.index++
+	return true
+}
+
+// open finds the array start at Path.
+func (as *ArrayScanner) open() bool {
+	t := &as.t
+	if !t.Next() {
+		as.err = as.tokenizerError()
+		return false
+	}
+	for i, key := range as.Path {
+		if t.Kind() != TokenBeginObject {
+			as.err = fmt.Errorf("cannot find array at path %q: value at %q must be object; got %s",
+				as.pathString(), strings.Join(as.Path[:i], "."), t.Kind())
+			return false
+		}
+		for {
+			if !t.Next() {
+				as.err = as.tokenizerError()
+				return false
+			}
+			if t.Kind() == TokenEndObject && t.Depth() == i {
+				as.err = fmt.Errorf("cannot find array at path %q: missing key %q", as.pathString(), key)
+				return false
+			}
+			if string(t.Key()) == key {
+				break
+			}
+			t.Skip()
+		}
+	}
+	if t.Kind() != TokenBeginArray {
+		as.err = fmt.Errorf("cannot find array at path %q: value must be array; got %s", as.pathString(), t.Kind())
+		return false
+	}
+	as.depth = t.Depth()
+	return true
+}
+
+// readElement reads the element starting at the current token to as.b.
+//
+// The element is written in compact form.
+func (as *ArrayScanner) readElement() bool {
+	t := &as.t
+	b := as.b[:0]
+	depth := t.Depth()
+	needComma := false
+	for {
+		kind := t.Kind()
+		isBegin := kind == TokenBeginObject || kind == TokenBeginArray
+		isEnd := kind == TokenEndObject || kind == TokenEndArray
+		if needComma && !isEnd {
+			b = append(b, ',')
+		}
+		if key := t.Key(); key != nil && t.Depth() > depth {
+			b = appendEscapedString(b, b2s(key))
+			b = append(b, ':')
+		}
+		b = append(b, t.Raw()...)
+		if t.Depth() == depth && !isBegin {
+			break
+		}
+		needComma = !isBegin
+		if !t.Next() {
+			as.b = b
+			as.err = as.tokenizerError()
+			return false
+		}
+	}
+	as.b = b
+	return true
+}
+
+// tokenizerError returns the error for unexpected end of tokens.
+func (as *ArrayScanner) tokenizerError() error {
+	if err := as.t.Error(); err != nil {
+		return err
+	}
+	// This shouldn't happen, since the tokenizer returns error
+	// on incomplete JSON.
+	return fmt.Errorf("BUG: unexpected end of JSON")
+}
+
+func (as *ArrayScanner) pathString() string {
+	return strings.Join(as.Path, ".")
+}
+
+// Error returns the last error.
+//
+// nil is returned at the end of the array.
+func (as *ArrayScanner) Error() error {
+	if as.err == io.EOF {
+		return nil
+	}
+	return as.err
+}
+
+// Value returns the last parsed element.
+//
+// The value is valid until the next call to Next.
+func (as *ArrayScanner) Value() *Value {
+	return as.v
+}
+
+// Index returns the number of elements read so far.
+func (as *ArrayScanner) Index() int {
+	return as.index
+}
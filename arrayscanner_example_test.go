@@ -0,0 +1,27 @@
+package fastjson_test
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/valyala/fastjson"
+)
+
+func ExampleArrayScanner() {
+	r := strings.NewReader(`{"data": {"items": [{"id": 1, "name": "foo"}, {"id": 2, "name": "bar"}]}}`)
+	var as fastjson.ArrayScanner
+	as.Path = []string{"data", "items"}
+	as.Init(r)
+	for as.Next() {
+		v := as.Value()
+		fmt.Printf("id=%d, name=%s\n", v.GetInt("id"), v.GetStringBytes("name"))
+	}
+	if err := as.Error(); err != nil {
+		log.Fatalf("unexpected error: %s", err)
+	}
+
+	// Output:
+	// id=1, name=foo
+	// id=2, name=bar
+}
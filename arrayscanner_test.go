@@ -0,0 +1,145 @@
+package fastjson
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestArrayScannerSuccess(t *testing.T) {
+	f := func(s string, path []string, expected string) {
+		t.Helper()
+		for _, r := range []io.Reader{strings.NewReader(s), iotest.OneByteReader(strings.NewReader(s))} {
+			var as ArrayScanner
+			as.Path = path
+			as.Init(r)
+			var a []string
+			for as.Next() {
+				a = append(a, as.Value().String())
+			}
+			if err := as.Error(); err != nil {
+				t.Fatalf("unexpected error for %q: %s", s, err)
+			}
+			result := strings.Join(a, " ")
+			if result != expected {
+				t.Fatalf("unexpected elements for %q;\ngot\n%s\nwant\n%s", s, result, expected)
+			}
+			if as.Index() != len(a) {
+				t.Fatalf("unexpected index; got %d; want %d", as.Index(), len(a))
+			}
+			if as.Next() {
+				t.Fatalf("Next must return false after the end of the array")
+			}
+		}
+	}
+
+	f(`[]`, nil, ``)
+	f(` [ ] `, nil, ``)
+	f(`[1]`, nil, `1`)
+	f(`[1, "foo", true, false, null, -2.5e3]`, nil, `1 "foo" true false null -2500`)
+	f(`[ {"a" : [1, {"b":2}, []], "c\"d": {}} , [ [ ] , { } ] ]`, nil, `{"a":[1,{"b":2},[]],"c\"d":{}} [[],{}]`)
+	f(`[{"":""},{"x":"A\n"}]`, nil, `{"":""} {"x":"A\n"}`)
+
+	// Arrays at the given path.
+	f(`{"data":{"items":[1,2]}}`, []string{"data", "items"}, `1 2`)
+	f(`{"meta":{"items":[0]},"data":{"count":2,"items":[{"id":1},{"id":2}],"next":null},"x":[3]}`, []string{"data", "items"}, `{"id":1} {"id":2}`)
+	f(`{"a":[]}`, []string{"a"}, ``)
+}
+
+func TestArrayScannerError(t *testing.T) {
+	f := func(s string, path []string, expectedElements int) {
+		t.Helper()
+		var as ArrayScanner
+		as.Path = path
+		as.Init(strings.NewReader(s))
+		n := 0
+		for as.Next() {
+			n++
+		}
+		if as.Error() == nil {
+			t.Fatalf("expecting non-nil error for %q", s)
+		}
+		if n != expectedElements {
+			t.Fatalf("unexpected number of elements for %q; got %d; want %d", s, n, expectedElements)
+		}
+		if as.Next() {
+			t.Fatalf("Next must return false after error for %q", s)
+		}
+	}
+
+	// Invalid JSON.
+	f(``, nil, 0)
+	f(`[`, nil, 0)
+	f(`[1,`, nil, 1)
+	f(`[1,2`, nil, 2)
+	f(`[1,{"a":2]`, nil, 1)
+	f(`[1] 2`, nil, 1)
+	f(`{"a":[1]`, []string{"a"}, 1)
+	f(`{"a":[1],}`, []string{"a"}, 1)
+	f(`[tru]`, nil, 0)
+
+	// Missing array.
+	f(`{}`, nil, 0)
+	f(`1`, nil, 0)
+	f(`{"a":{}}`, []string{"a"}, 0)
+	f(`{"b":[]}`, []string{"a"}, 0)
+	f(`[]`, []string{"a"}, 0)
+	f(`{"a":[{"b":[]}]}`, []string{"a", "b"}, 0)
+	f(`{"a":{"c":[]}}`, []string{"a", "b"}, 0)
+
+	// The first key is used like Object.Get does.
+	f(`{"a":1,"a":[1]}`, []string{"a"}, 0)
+}
+
+func TestArrayScannerBoundedMemory(t *testing.T) {
+	const n = 100000
+	r := &repeatReader{
+		prefix: `{"total":100000,"items":[`,
+		item:   `{"id":12345,"name":"foobar","tags":["a","b","c"],"nested":{"x":null,"y":true}}`,
+		suffix: `]}`,
+		n:      n,
+	}
+	var as ArrayScanner
+	as.Path = []string{"items"}
+	as.Init(r)
+	for as.Next() {
+		v := as.Value()
+		if v.GetInt("id") != 12345 || len(v.GetArray("tags")) != 3 {
+			t.Fatalf("unexpected element #%d: %s", as.Index(), v)
+		}
+	}
+	if err := as.Error(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if as.Index() != n {
+		t.Fatalf("unexpected number of elements; got %d; want %d", as.Index(), n)
+	}
+	if cap(as.b) > 1024 {
+		t.Fatalf("unexpected element buffer growth to %d bytes", cap(as.b))
+	}
+	if len(as.p.c.slabs) > 1 {
+		t.Fatalf("unexpected parser cache growth to %d slabs", len(as.p.c.slabs))
+	}
+}
+
+func TestArrayScannerReuse(t *testing.T) {
+	var as ArrayScanner
+	as.Init(strings.NewReader(`[1,`))
+	for as.Next() {
+	}
+	if as.Error() == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+	as.Init(strings.NewReader(`[2,3]`))
+	var sum int
+	for as.Next() {
+		sum += as.Value().GetInt()
+	}
+	if err := as.Error(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if sum != 5 || as.Index() != 2 {
+		t.Fatalf("unexpected result; sum=%d, index=%d", sum, as.Index())
+	}
+}
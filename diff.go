@@ -0,0 +1,520 @@
+package fastjson
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ChangeKind is the kind of a Change.
+type ChangeKind int
+
+const (
+	// ChangeAdded means the value is missing in the old JSON
+	// and is present in the new JSON.
+	ChangeAdded ChangeKind = 0
+
+	// ChangeRemoved means the value is present in the old JSON
+	// and is missing in the new JSON.
+	ChangeRemoved ChangeKind = 1
+
+	// ChangeReplaced means the value at the given path has been changed.
+	ChangeReplaced ChangeKind = 2
+)
+
+// String returns string representation of k.
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeAdded:
+		return "added"
+	case ChangeRemoved:
+		return "removed"
+	case ChangeReplaced:
+		return "replaced"
+	default:
+		panic(fmt.Errorf("BUG: unknown ChangeKind: %d", k))
+	}
+}
+
+// Change describes a single difference between two JSON values.
+type Change struct {
+	// Kind is the change kind.
+	Kind ChangeKind
+
+	// Path is JSON Pointer ( https://tools.ietf.org/html/rfc6901 )
+	// to the changed value.
+	//
+	// Array indexes in the Path refer to the array with all the preceding
+	// changes applied, so Changes may be applied sequentially
+	// as RFC 6902 patch.
+	Path string
+
+	// Old is the old value. It is nil for ChangeAdded.
+	Old *Value
+
+	// New is the new value. It is nil for ChangeRemoved.
+	New *Value
+}
+
+// ArrayDiffStrategy determines how arrays are compared by Differ.
+type ArrayDiffStrategy int
+
+const (
+	// ArrayDiffIndex compares array items with the same indexes.
+	//
+	// This is the fastest strategy, but inserting or removing an item
+	// in the beginning of the array results in changes for all
+	// the subsequent items.
+	ArrayDiffIndex ArrayDiffStrategy = 0
+
+	// ArrayDiffLCS finds the longest common subsequence of array items,
+	// so only the actually inserted and removed items are reported.
+	//
+	// It requires O(len(a)*len(b)) time and memory for arrays a and b
+	// after stripping their common prefix and suffix. Arrays exceeding
+	// Differ.MaxLCSSize are compared index-wise after stripping.
+	ArrayDiffLCS ArrayDiffStrategy = 1
+)
+
+// DefaultMaxLCSSize is the default value for Differ.MaxLCSSize.
+const DefaultMaxLCSSize = 1 << 20
+
+// Differ finds differences between JSON values.
+//
+// Differ may be used from concurrent goroutines, but the compared values
+// mustn't be accessed concurrently, since they are parsed lazily during
+// comparison. Call Value.Freeze on the values before comparing them
+// from concurrent goroutines.
+type Differ struct {
+	// ArrayStrategy is the strategy for comparing arrays.
+	//
+	// ArrayDiffIndex is used by default.
+	ArrayStrategy ArrayDiffStrategy
+
+	// MaxLCSSize is the maximum value of len(a)*len(b) for arrays a and b
+	// compared with ArrayDiffLCS after stripping their common prefix
+	// and suffix. It limits time and memory used for comparing big arrays.
+	// Bigger arrays are compared index-wise.
+	//
+	// DefaultMaxLCSSize is used if MaxLCSSize isn't set.
+	MaxLCSSize int
+}
+
+// Diff returns changes required for turning a into b.
+//
+// Object items are compared by keys regardless of their order.
+// Numbers are compared by their float64 values.
+//
+// The returned changes reference a and b, so they are valid until Parse
+// is called on the Parsers returned a and b.
+func (d *Differ) Diff(a, b *Value) Changes {
+	var cs Changes
+	var stack []diffTask
+	path := make([]byte, 0, 64)
+	cs, stack = d.diff(cs, stack, path, a, b)
+	for len(stack) > 0 {
+		t := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		path = path[:t.pathLen]
+		if t.key == nil {
+			path = appendPointerIndex(path, t.index)
+		} else {
+			path = appendPointerToken(path, *t.key)
+		}
+		if t.kind == diffValues {
+			cs, stack = d.diff(cs, stack, path, t.a, t.b)
+		} else {
+			cs = append(cs, newChange(t.kind, path, t.a, t.b))
+		}
+	}
+	return cs
+}
+
+// Diff returns changes required for turning a into b.
+//
+// Arrays are compared index-wise. Use Differ for other strategies.
+func Diff(a, b *Value) Changes {
+	var d Differ
+	return d.Diff(a, b)
+}
+
+// diffValues is diffTask.kind for comparing diffTask.a with diffTask.b.
+const diffValues ChangeKind = -1
+
+// diffTask is a pending change or comparison in Differ.Diff.
+//
+// Values are compared without recursion, so deeply nested values
+// don't overflow the stack.
+type diffTask struct {
+	// kind is the kind of the change to report or diffValues.
+	kind ChangeKind
+
+	a *Value
+	b *Value
+
+	// pathLen is the length of the parent path.
+	pathLen int
+
+	// key is the object key, which is appended to the parent path.
+	// index is appended to the parent path if key is nil.
+	key   *string
+	index int
+}
+
+// diff compares a and b at the given path.
+//
+// Changes for a and b are appended to cs, while their children
+// are pushed to stack, so they are compared by the caller
+// in the original order.
+func (d *Differ) diff(cs Changes, stack []diffTask, path []byte, a, b *Value) (Changes, []diffTask) {
+	t := a.Type()
+	if t != b.Type() {
+		return append(cs, newChange(ChangeReplaced, path, a, b)), stack
+	}
+	n := len(stack)
+	switch t {
+	case TypeObject:
+		stack = d.diffObjects(stack, len(path), &a.o, &b.o)
+	case TypeArray:
+		if d.ArrayStrategy == ArrayDiffLCS {
+			stack = d.diffArraysLCS(stack, len(path), a.a, b.a)
+		} else {
+			stack = d.diffArraysIndex(stack, len(path), a.a, b.a, 0)
+		}
+	default:
+		if !equalValues(a, b) {
+			cs = append(cs, newChange(ChangeReplaced, path, a, b))
+		}
+		return cs, stack
+	}
+
+	// Reverse the pushed tasks, so they are popped in the original order.
+	tasks := stack[n:]
+	for i, j := 0, len(tasks)-1; i < j; i, j = i+1, j-1 {
+		tasks[i], tasks[j] = tasks[j], tasks[i]
+	}
+	return cs, stack
+}
+
+func (d *Differ) diffObjects(stack []diffTask, pathLen int, a, b *Object) []diffTask {
+	a.unescapeKeys()
+	b.unescapeKeys()
+	for i := range a.kvs {
+		kv := &a.kvs[i]
+		bv := b.Get(kv.k)
+		if bv == nil {
+			stack = appendKeyTask(stack, ChangeRemoved, pathLen, &kv.k, kv.v, nil)
+			continue
+		}
+		stack = appendKeyTask(stack, diffValues, pathLen, &kv.k, kv.v, bv)
+	}
+	for i := range b.kvs {
+		kv := &b.kvs[i]
+		if a.Get(kv.k) != nil {
+			continue
+		}
+		stack = appendKeyTask(stack, ChangeAdded, pathLen, &kv.k, nil, kv.v)
+	}
+	return stack
+}
+
+// diffArraysIndex compares a and b index-wise. The items of a and b
+// are located at the given offset in the compared arrays.
+func (d *Differ) diffArraysIndex(stack []diffTask, pathLen int, a, b []*Value, offset int) []diffTask {
+	i := 0
+	for i < len(a) && i < len(b) {
+		stack = appendIndexTask(stack, diffValues, pathLen, offset+i, a[i], b[i])
+		i++
+	}
+	for j := i; j < len(b); j++ {
+		stack = appendIndexTask(stack, ChangeAdded, pathLen, offset+j, nil, b[j])
+	}
+
+	// Remove the trailing items starting from the end,
+	// so the indexes remain valid during sequential application.
+	for j := len(a) - 1; j >= i; j-- {
+		stack = appendIndexTask(stack, ChangeRemoved, pathLen, offset+j, a[j], nil)
+	}
+	return stack
+}
+
+func (d *Differ) diffArraysLCS(stack []diffTask, pathLen int, a, b []*Value) []diffTask {
+	// Strip common prefix and suffix.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && equalValues(a[prefix], b[prefix]) {
+		prefix++
+	}
+	a = a[prefix:]
+	b = b[prefix:]
+	for len(a) > 0 && len(b) > 0 && equalValues(a[len(a)-1], b[len(b)-1]) {
+		a = a[:len(a)-1]
+		b = b[:len(b)-1]
+	}
+
+	maxSize := d.MaxLCSSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxLCSSize
+	}
+	if len(a) > 0 && len(b) > maxSize/len(a) {
+		// The LCS table would be too big.
+		return d.diffArraysIndex(stack, pathLen, a, b, prefix)
+	}
+
+	// lcs[i*w+j] contains the LCS length for a[i:] and b[j:].
+	w := len(b) + 1
+	lcs := make([]int, (len(a)+1)*w)
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if equalValues(a[i], b[j]) {
+				lcs[i*w+j] = lcs[(i+1)*w+j+1] + 1
+			} else if lcs[(i+1)*w+j] >= lcs[i*w+j+1] {
+				lcs[i*w+j] = lcs[(i+1)*w+j]
+			} else {
+				lcs[i*w+j] = lcs[i*w+j+1]
+			}
+		}
+	}
+
+	// Walk the edit script. k is the index in the array with all
+	// the preceding changes applied.
+	k := prefix
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		if i < len(a) && j < len(b) && equalValues(a[i], b[j]) {
+			i++
+			j++
+			k++
+			continue
+		}
+
+		// Collect a run of removed and added items up to the next common item.
+		ri, rj := i, j
+		for ri < len(a) || rj < len(b) {
+			if ri < len(a) && rj < len(b) && equalValues(a[ri], b[rj]) {
+				break
+			}
+			if rj < len(b) && (ri == len(a) || lcs[ri*w+rj+1] >= lcs[(ri+1)*w+rj]) {
+				rj++
+			} else {
+				ri++
+			}
+		}
+
+		// Removed items followed by added items at the same position
+		// are reported as changes of the corresponding items.
+		for i < ri && j < rj {
+			stack = appendIndexTask(stack, diffValues, pathLen, k, a[i], b[j])
+			i++
+			j++
+			k++
+		}
+		for i < ri {
+			stack = appendIndexTask(stack, ChangeRemoved, pathLen, k, a[i], nil)
+			i++
+		}
+		for j < rj {
+			stack = appendIndexTask(stack, ChangeAdded, pathLen, k, nil, b[j])
+			j++
+			k++
+		}
+	}
+	return stack
+}
+
+func appendKeyTask(stack []diffTask, kind ChangeKind, pathLen int, key *string, a, b *Value) []diffTask {
+	return append(stack, diffTask{
+		kind:    kind,
+		a:       a,
+		b:       b,
+		pathLen: pathLen,
+		key:     key,
+	})
+}
+
+func appendIndexTask(stack []diffTask, kind ChangeKind, pathLen, index int, a, b *Value) []diffTask {
+	return append(stack, diffTask{
+		kind:    kind,
+		a:       a,
+		b:       b,
+		pathLen: pathLen,
+		index:   index,
+	})
+}
+
+func newChange(kind ChangeKind, path []byte, oldV, newV *Value) Change {
+	return Change{
+		Kind: kind,
+		Path: string(path),
+		Old:  oldV,
+		New:  newV,
+	}
+}
+
+// appendPointerToken appends JSON Pointer reference token for the given
+// object key to dst.
+func appendPointerToken(dst []byte, key string) []byte {
+	dst = append(dst, '/')
+	if strings.IndexByte(key, '~') < 0 && strings.IndexByte(key, '/') < 0 {
+		// Fast path - nothing to escape.
+		return append(dst, key...)
+	}
+	for i := 0; i < len(key); i++ {
+		switch key[i] {
+		case '~':
+			dst = append(dst, '~', '0')
+		case '/':
+			dst = append(dst, '~', '1')
+		default:
+			dst = append(dst, key[i])
+		}
+	}
+	return dst
+}
+
+func appendPointerIndex(dst []byte, n int) []byte {
+	dst = append(dst, '/')
+	return strconv.AppendInt(dst, int64(n), 10)
+}
+
+// equalValues returns true if a and b contain equal JSON values.
+func equalValues(a, b *Value) bool {
+	if a == b {
+		return true
+	}
+	t := a.Type()
+	if t != b.Type() {
+		return false
+	}
+	if t != TypeObject && t != TypeArray {
+		return equalScalars(a, b)
+	}
+
+	// Compare nested values without recursion,
+	// so deeply nested values don't overflow the stack.
+	stack := []*Value{a, b}
+	for len(stack) > 0 {
+		a, b = stack[len(stack)-2], stack[len(stack)-1]
+		stack = stack[:len(stack)-2]
+		if a == b {
+			continue
+		}
+		t = a.Type()
+		if t != b.Type() {
+			return false
+		}
+		switch t {
+		case TypeObject:
+			if len(a.o.kvs) != len(b.o.kvs) {
+				return false
+			}
+			a.o.unescapeKeys()
+			for _, kv := range a.o.kvs {
+				bv := b.o.Get(kv.k)
+				if bv == nil {
+					return false
+				}
+				stack = append(stack, kv.v, bv)
+			}
+		case TypeArray:
+			if len(a.a) != len(b.a) {
+				return false
+			}
+			for i := range a.a {
+				stack = append(stack, a.a[i], b.a[i])
+			}
+		default:
+			if !equalScalars(a, b) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// equalScalars returns true if a and b with the same type, which isn't
+// object or array, are equal.
+func equalScalars(a, b *Value) bool {
+	switch a.t {
+	case TypeString:
+		return a.s == b.s
+	case TypeNumber:
+		return a.n == b.n
+	default:
+		return true
+	}
+}
+
+// Changes is a list of changes returned from Diff.
+type Changes []Change
+
+// MarshalPatchTo appends JSON Patch ( https://tools.ietf.org/html/rfc6902 )
+// for the cs to dst and returns the result.
+func (cs Changes) MarshalPatchTo(dst []byte) []byte {
+	dst = append(dst, '[')
+	for i := range cs {
+		c := &cs[i]
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = append(dst, `{"op":`...)
+		switch c.Kind {
+		case ChangeAdded:
+			dst = append(dst, `"add"`...)
+		case ChangeRemoved:
+			dst = append(dst, `"remove"`...)
+		case ChangeReplaced:
+			dst = append(dst, `"replace"`...)
+		default:
+			panic(fmt.Errorf("BUG: unknown ChangeKind: %d", c.Kind))
+		}
+		dst = append(dst, `,"path":`...)
+		dst = appendEscapedString(dst, c.Path)
+		if c.New != nil {
+			dst = append(dst, `,"value":`...)
+			dst = c.New.MarshalTo(dst)
+		}
+		dst = append(dst, '}')
+	}
+	return append(dst, ']')
+}
+
+// Report returns human-readable report for the cs.
+//
+// Every change is written on a separate line in the form "+ path: new"
+// for added values, "- path: old" for removed values and
+// "~ path: old -> new" for replaced values.
+func (cs Changes) Report() string {
+	// Use bytes.Buffer instead of strings.Builder,
+	// so it works on go 1.9 and below.
+	var bb bytes.Buffer
+	var b []byte
+	for i := range cs {
+		c := &cs[i]
+		b = b[:0]
+		switch c.Kind {
+		case ChangeAdded:
+			b = append(b, "+ "...)
+		case ChangeRemoved:
+			b = append(b, "- "...)
+		case ChangeReplaced:
+			b = append(b, "~ "...)
+		default:
+			panic(fmt.Errorf("BUG: unknown ChangeKind: %d", c.Kind))
+		}
+		b = append(b, c.Path...)
+		b = append(b, ": "...)
+		if c.Old != nil {
+			b = c.Old.MarshalTo(b)
+			if c.New != nil {
+				b = append(b, " -> "...)
+			}
+		}
+		if c.New != nil {
+			b = c.New.MarshalTo(b)
+		}
+		b = append(b, '\n')
+		bb.Write(b)
+	}
+	return bb.String()
+}
@@ -0,0 +1,31 @@
+package fastjson_test
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/valyala/fastjson"
+)
+
+func ExampleDiff() {
+	var pa, pb fastjson.Parser
+	a, err := pa.Parse(`{"name":"foo","tags":["x","y"],"size":3}`)
+	if err != nil {
+		log.Fatalf("cannot parse json: %s", err)
+	}
+	b, err := pb.Parse(`{"name":"bar","tags":["x"],"color":"red"}`)
+	if err != nil {
+		log.Fatalf("cannot parse json: %s", err)
+	}
+
+	cs := fastjson.Diff(a, b)
+	fmt.Printf("%s", cs.Report())
+	fmt.Printf("%s\n", cs.MarshalPatchTo(nil))
+
+	// Output:
+	// ~ /name: "foo" -> "bar"
+	// - /tags/1: "y"
+	// - /size: 3
+	// + /color: "red"
+	// [{"op":"replace","path":"/name","value":"bar"},{"op":"remove","path":"/tags/1"},{"op":"remove","path":"/size"},{"op":"add","path":"/color","value":"red"}]
+}
@@ -0,0 +1,184 @@
+package fastjson
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	f := func(a, b, expectedReport string) {
+		t.Helper()
+		testDiff(t, ArrayDiffIndex, a, b, expectedReport)
+	}
+
+	f(`{}`, `{}`, ``)
+	f(`123`, `123.0`, ``)
+	f(`"foo"`, `"foo"`, ``)
+	f(`{"a":1,"b":[1,2]}`, `{"b":[1,2],"a":1}`, ``)
+	f(`1`, `2`, "~ : 1 -> 2\n")
+	f(`1`, `"1"`, "~ : 1 -> \"1\"\n")
+	f(`{"a":1,"b":2}`, `{"b":3,"c":4}`, "- /a: 1\n~ /b: 2 -> 3\n+ /c: 4\n")
+	f(`{"a/b":{"c~d":null}}`, `{"a/b":{"c~d":true}}`, "~ /a~1b/c~0d: null -> true\n")
+	f(`[1,2,3]`, `[1,5]`, "~ /1: 2 -> 5\n- /2: 3\n")
+	f(`[1,2,3]`, `[1,2,3,4,5]`, "+ /3: 4\n+ /4: 5\n")
+	f(`[1,2,3,4]`, `[1,2]`, "- /3: 4\n- /2: 3\n")
+	f(`[0,1,2]`, `[1,2]`, "~ /0: 0 -> 1\n~ /1: 1 -> 2\n- /2: 2\n")
+	f(`{"a":{"x":1,"y":2},"b":[1,{"c":3}]}`, `{"a":{"x":2},"b":[1,{"c":4},5],"d":0}`, "~ /a/x: 1 -> 2\n- /a/y: 2\n~ /b/1/c: 3 -> 4\n+ /b/2: 5\n+ /d: 0\n")
+}
+
+func TestDiffDeeplyNested(t *testing.T) {
+	f := func(strategy ArrayDiffStrategy, depth int) {
+		t.Helper()
+		var pa, pb Parser
+		va, err := pa.Parse(strings.Repeat(`[{"a":`, depth) + `1` + strings.Repeat(`}]`, depth))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		vb, err := pb.Parse(strings.Repeat(`[{"a":`, depth) + `2` + strings.Repeat(`}]`, depth))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		d := &Differ{
+			ArrayStrategy: strategy,
+		}
+		expectedReport := "~ " + strings.Repeat("/0/a", depth) + ": 1 -> 2\n"
+		if report := d.Diff(va, vb).Report(); report != expectedReport {
+			t.Fatalf("unexpected report of %d bytes; want %d bytes", len(report), len(expectedReport))
+		}
+		if cs := d.Diff(va, va); len(cs) != 0 {
+			t.Fatalf("unexpected changes for equal values: %d", len(cs))
+		}
+		if equalValues(va, vb) {
+			t.Fatalf("deeply nested values mustn't be equal")
+		}
+	}
+
+	f(ArrayDiffIndex, 100000)
+
+	// ArrayDiffLCS compares array items before diffing them,
+	// so it takes quadratic time on nesting depth.
+	f(ArrayDiffLCS, 1000)
+}
+
+func TestDiffLCS(t *testing.T) {
+	f := func(a, b, expectedReport string) {
+		t.Helper()
+		testDiff(t, ArrayDiffLCS, a, b, expectedReport)
+	}
+
+	f(`[]`, `[]`, ``)
+	f(`[1,2,3]`, `[1,2,3]`, ``)
+	f(`[0,1,2]`, `[1,2]`, "- /0: 0\n")
+	f(`[1,2]`, `[0,1,2]`, "+ /0: 0\n")
+	f(`[1,2,3,4]`, `[1,4]`, "- /1: 2\n- /1: 3\n")
+	f(`[1,2,3]`, `[1,"x",3,4]`, "~ /1: 2 -> \"x\"\n+ /3: 4\n")
+	f(`[{"a":1},5,{"b":2}]`, `[5,{"b":3}]`, "- /0: {\"a\":1}\n~ /1/b: 2 -> 3\n")
+	f(`{"x":[1,2,3,4,5]}`, `{"x":[0,1,3,5,6]}`, "+ /x/0: 0\n- /x/2: 2\n- /x/3: 4\n+ /x/4: 6\n")
+}
+
+func TestDiffLCSMaxSize(t *testing.T) {
+	var pa, pb Parser
+	va, err := pa.Parse(`[9,1,2,3,8]`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	vb, err := pb.Parse(`[9,0,1,2,3,7,8]`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	d := &Differ{
+		ArrayStrategy: ArrayDiffLCS,
+		MaxLCSSize:    15,
+	}
+	report := d.Diff(va, vb).Report()
+	expectedReport := "+ /1: 0\n+ /5: 7\n"
+	if report != expectedReport {
+		t.Fatalf("unexpected report; got\n%s\nwant\n%s", report, expectedReport)
+	}
+
+	// Arrays exceeding MaxLCSSize after stripping the common prefix
+	// and suffix are compared index-wise.
+	d.MaxLCSSize = 14
+	report = d.Diff(va, vb).Report()
+	expectedReport = "~ /1: 1 -> 0\n~ /2: 2 -> 1\n~ /3: 3 -> 2\n+ /4: 3\n+ /5: 7\n"
+	if report != expectedReport {
+		t.Fatalf("unexpected report; got\n%s\nwant\n%s", report, expectedReport)
+	}
+
+	// Big arrays don't require len(a)*len(b) memory by default.
+	const n = 50000
+	var bb []byte
+	for _, offset := range []int{0, 1} {
+		bb = append(bb[:0], '[')
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				bb = append(bb, ',')
+			}
+			bb = strconv.AppendInt(bb, int64(i*2+offset), 10)
+		}
+		bb = append(bb, ']')
+		if offset == 0 {
+			va, err = pa.ParseBytes(bb)
+		} else {
+			vb, err = pb.ParseBytes(bb)
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	d.MaxLCSSize = 0
+	if cs := d.Diff(va, vb); len(cs) != n {
+		t.Fatalf("unexpected number of changes; got %d; want %d", len(cs), n)
+	}
+}
+
+func testDiff(t *testing.T, strategy ArrayDiffStrategy, a, b, expectedReport string) {
+	t.Helper()
+
+	var pa, pb Parser
+	va, err := pa.Parse(a)
+	if err != nil {
+		t.Fatalf("cannot parse %q: %s", a, err)
+	}
+	vb, err := pb.Parse(b)
+	if err != nil {
+		t.Fatalf("cannot parse %q: %s", b, err)
+	}
+	d := &Differ{
+		ArrayStrategy: strategy,
+	}
+	cs := d.Diff(va, vb)
+	report := cs.Report()
+	if report != expectedReport {
+		t.Fatalf("unexpected report for diff(%s, %s); got\n%s\nwant\n%s", a, b, report, expectedReport)
+	}
+}
+
+func TestChangesMarshalPatchTo(t *testing.T) {
+	var pa, pb Parser
+	va, err := pa.Parse(`{"foo":[1,2],"bar":"baz","x":{"y":null}}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	vb, err := pb.Parse(`{"foo":[1],"bar":"qux\n","z":[true]}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	cs := Diff(va, vb)
+	patch := cs.MarshalPatchTo(nil)
+	expectedPatch := `[{"op":"remove","path":"/foo/1"},{"op":"replace","path":"/bar","value":"qux\n"},` +
+		`{"op":"remove","path":"/x"},{"op":"add","path":"/z","value":[true]}]`
+	if string(patch) != expectedPatch {
+		t.Fatalf("unexpected patch; got\n%s\nwant\n%s", patch, expectedPatch)
+	}
+	if err := ValidateStrictBytes(patch); err != nil {
+		t.Fatalf("patch must be valid JSON: %s", err)
+	}
+
+	cs = Diff(va, va)
+	patch = cs.MarshalPatchTo(nil)
+	if string(patch) != "[]" {
+		t.Fatalf("unexpected patch for equal values; got %s; want []", patch)
+	}
+}
@@ -0,0 +1,95 @@
+package fastjson
+
+// DuplicateKeys is the policy for handling duplicate keys in JSON objects.
+//
+// JSON parsers disagree on which value to use for duplicate keys,
+// so passing objects with duplicate keys between different parsers
+// may lead to security issues such as request smuggling.
+type DuplicateKeys int
+
+const (
+	// DuplicateKeysAllow keeps all the duplicate keys in the object.
+	// Object.Get returns the value for the first key, while Object.Visit
+	// visits all the keys.
+	//
+	// This is the fastest policy, since keys aren't checked for duplicates.
+	DuplicateKeysAllow DuplicateKeys = 0
+
+	// DuplicateKeysReject makes the parser returning an error
+	// for objects with duplicate keys.
+	DuplicateKeysReject DuplicateKeys = 1
+
+	// DuplicateKeysKeepFirst keeps the first value for duplicate keys
+	// and drops the subsequent values.
+	DuplicateKeysKeepFirst DuplicateKeys = 2
+
+	// DuplicateKeysKeepLast keeps the last value for duplicate keys.
+	// The key remains at the position of its first occurrence.
+	//
+	// This matches the behavior of the majority of other JSON parsers.
+	DuplicateKeysKeepLast DuplicateKeys = 3
+)
+
+// duplicateKeysIndexThreshold is the number of object keys
+// after which a hash index is used for detecting duplicate keys
+// instead of linear search.
+const duplicateKeysIndexThreshold = 16
+
+type objectKey struct {
+	o *Object
+	k string
+}
+
+// duplicateKey returns the index of the key in o, which equals
+// to the last key in o.
+//
+// -1 is returned if the last key in o is unique.
+//
+// Keys in o must be unescaped.
+func (c *cache) duplicateKey(o *Object) int {
+	kvs := o.kvs
+	n := len(kvs) - 1
+	k := kvs[n].k
+	if n < duplicateKeysIndexThreshold {
+		for i := 0; i < n; i++ {
+			if kvs[i].k == k {
+				return i
+			}
+		}
+		return -1
+	}
+
+	if c.keys == nil {
+		c.keys = make(map[objectKey]int)
+	}
+	if n == duplicateKeysIndexThreshold {
+		// Index the preceding keys, which are known to be unique.
+		for i := 0; i < n; i++ {
+			c.keys[objectKey{o, kvs[i].k}] = i
+		}
+	}
+	ok := objectKey{o, k}
+	if i, found := c.keys[ok]; found {
+		return i
+	}
+	c.keys[ok] = n
+	if len(c.keys) > c.maxKeys {
+		c.retained += (len(c.keys) - c.maxKeys) * keysEntrySize
+		c.maxKeys = len(c.keys)
+	}
+	return -1
+}
+
+// dropDuplicateKey removes the last item from o, which has the same key
+// as the item at index i.
+func (c *cache) dropDuplicateKey(o *Object, i int) {
+	n := len(o.kvs) - 1
+	if c.duplicateKeys == DuplicateKeysKeepLast {
+		o.kvs[i].v = o.kvs[n].v
+	}
+	o.kvs = o.kvs[:n]
+	if o.pos != nil {
+		// The position of the key at index i is kept.
+		o.pos.keys = o.pos.keys[:n]
+	}
+}
@@ -0,0 +1,131 @@
+package fastjson
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParserDuplicateKeys(t *testing.T) {
+	f := func(dk DuplicateKeys, relaxed bool, s, expected string) {
+		t.Helper()
+		p := &Parser{
+			DuplicateKeys: dk,
+			Relaxed:       relaxed,
+		}
+		v, err := p.Parse(s)
+		if err != nil {
+			t.Fatalf("unexpected error when parsing %q: %s", s, err)
+		}
+		result := string(v.MarshalTo(nil))
+		if result != expected {
+			t.Fatalf("unexpected result for %q;\ngot\n%s\nwant\n%s", s, result, expected)
+		}
+	}
+
+	for _, relaxed := range []bool{false, true} {
+		s := `{"a":1,"b":{"x":1,"x":2},"a":2,"a":3,"c":[{"y":1,"y":2}]}`
+		f(DuplicateKeysAllow, relaxed, s, `{"a":1,"b":{"x":1,"x":2},"a":2,"a":3,"c":[{"y":1,"y":2}]}`)
+		f(DuplicateKeysKeepFirst, relaxed, s, `{"a":1,"b":{"x":1},"c":[{"y":1}]}`)
+		f(DuplicateKeysKeepLast, relaxed, s, `{"a":3,"b":{"x":2},"c":[{"y":2}]}`)
+
+		s = `{"a":1,"b":2}`
+		f(DuplicateKeysKeepFirst, relaxed, s, s)
+		f(DuplicateKeysKeepLast, relaxed, s, s)
+		f(DuplicateKeysReject, relaxed, s, s)
+		f(DuplicateKeysReject, relaxed, `{}`, `{}`)
+	}
+
+	// Escaped keys.
+	f(DuplicateKeysKeepLast, false, `{"a":1,"\u0061":2}`, `{"a":2}`)
+
+	// Unquoted keys in relaxed mode.
+	f(DuplicateKeysKeepLast, true, `{a:1,'a':2,"a":3,}`, `{"a":3}`)
+}
+
+func TestParserDuplicateKeysBigObject(t *testing.T) {
+	var keys []string
+	for i := 0; i < 3*duplicateKeysIndexThreshold; i++ {
+		keys = append(keys, fmt.Sprintf(`"k%d":%d`, i, i))
+	}
+	unique := "{" + strings.Join(keys, ",") + "}"
+
+	// Duplicate every key, so duplicates are detected by both
+	// linear search and hash index.
+	var dupKeys []string
+	for i := range keys {
+		dupKeys = append(dupKeys, keys[i], fmt.Sprintf(`"k%d":"dup"`, i))
+	}
+	dup := "{" + strings.Join(dupKeys, ",") + "}"
+
+	// Nest big objects in order to verify that keys
+	// from distinct objects don't clash.
+	s := fmt.Sprintf(`[%s,{"x":%s,"y":%s}]`, dup, dup, unique)
+
+	var p Parser
+	p.DuplicateKeys = DuplicateKeysKeepFirst
+	for i := 0; i < 3; i++ {
+		v, err := p.Parse(s)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		result := string(v.MarshalTo(nil))
+		expected := fmt.Sprintf(`[%s,{"x":%s,"y":%s}]`, unique, unique, unique)
+		if result != expected {
+			t.Fatalf("unexpected result;\ngot\n%s\nwant\n%s", result, expected)
+		}
+	}
+
+	p.DuplicateKeys = DuplicateKeysKeepLast
+	v, err := p.Parse(dup)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	o := v.GetObject()
+	if o.Len() != len(keys) {
+		t.Fatalf("unexpected number of keys; got %d; want %d", o.Len(), len(keys))
+	}
+	o.Visit(func(k []byte, v *Value) {
+		if string(v.GetStringBytes()) != "dup" {
+			t.Fatalf("unexpected value for key %q: %s", k, v)
+		}
+	})
+
+	p.DuplicateKeys = DuplicateKeysReject
+	if _, err := p.Parse(unique); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	s = unique[:len(unique)-1] + `,"k40":1}`
+	_, err = p.Parse(s)
+	if err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+	if !strings.Contains(err.Error(), `unparsed tail: "\"k40\":1}"`) {
+		t.Fatalf("error must point to the duplicate key; got %s", err)
+	}
+}
+
+func TestParserDuplicateKeysReject(t *testing.T) {
+	f := func(relaxed bool, s, expectedTail string) {
+		t.Helper()
+		p := &Parser{
+			DuplicateKeys: DuplicateKeysReject,
+			Relaxed:       relaxed,
+		}
+		_, err := p.Parse(s)
+		if err == nil {
+			t.Fatalf("expecting non-nil error when parsing %q", s)
+		}
+		if !strings.Contains(err.Error(), "duplicate key") {
+			t.Fatalf("unexpected error when parsing %q: %s", s, err)
+		}
+		if !strings.HasSuffix(err.Error(), fmt.Sprintf("unparsed tail: %q", expectedTail)) {
+			t.Fatalf("unexpected tail in the error when parsing %q: %s; want %q", s, err, expectedTail)
+		}
+	}
+
+	f(false, `{"a":1,"a":2}`, `"a":2}`)
+	f(false, `{"a":1, "a":2}`, `"a":2}`)
+	f(false, `[{"a":{"b":1,"c":2,"b":3}}]`, `"b":3}}]`)
+	f(true, `{a:1,'a':2}`, `'a':2}`)
+}
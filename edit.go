@@ -0,0 +1,296 @@
+package fastjson
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SetBytes sets value for the field identified by keys path in JSON data
+// and returns the result.
+//
+// value must contain valid JSON. Missing fields in keys path are created
+// as nested objects. Array indexes may be represented as decimal numbers
+// in keys. The index equal to the array length appends value to the array.
+// The whole data is replaced with value if keys path is empty.
+//
+// Only the values on keys path are parsed and the result is obtained
+// by splicing value into data, so SetBytes is faster than parsing data,
+// modifying it and marshaling it back. Formatting outside the replaced
+// value is preserved. The parts of data outside keys path aren't validated.
+//
+// data isn't modified.
+func SetBytes(data, value []byte, keys ...string) ([]byte, error) {
+	vs := b2s(value)
+	if err := validate(vs, true); err != nil {
+		return nil, fmt.Errorf("cannot set invalid value: %s", err)
+	}
+	vs = skipWS(vs)
+	tail, _ := validateValue(vs, true)
+	vs = vs[:len(vs)-len(tail)]
+
+	s := b2s(data)
+	offset := len(s) - len(skipWS(s))
+	for i, key := range keys {
+		var ri rawItem
+		if err := ri.find(s, offset, key); err != nil {
+			return nil, fmt.Errorf("cannot set value for keys %q: %s", keys[:i+1], err)
+		}
+		if !ri.found {
+			return ri.insert(s, keys[i:], vs)
+		}
+		offset = ri.valueStart
+	}
+
+	tail, err := validateValue(s[offset:], true)
+	if err != nil {
+		return nil, fmt.Errorf("cannot set value for keys %q: cannot parse JSON at offset %d: %s", keys, len(s)-len(tail), err)
+	}
+	end := len(s) - len(tail)
+	dst := make([]byte, 0, len(s)-(end-offset)+len(vs))
+	dst = append(dst, s[:offset]...)
+	dst = append(dst, vs...)
+	return append(dst, s[end:]...), nil
+}
+
+// DeleteBytes deletes the field identified by keys path from JSON data
+// and returns the result.
+//
+// Array indexes may be represented as decimal numbers in keys.
+//
+// Only the values on keys path are parsed and the result is obtained
+// by cutting the field from data, so DeleteBytes is faster than parsing
+// data, modifying it and marshaling it back. Formatting outside
+// the deleted field is preserved. The parts of data outside keys path
+// aren't validated.
+//
+// data is returned as is if keys path doesn't exist. Otherwise data
+// isn't modified and a new slice is returned.
+func DeleteBytes(data []byte, keys ...string) ([]byte, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("cannot delete value for empty keys path")
+	}
+	s := b2s(data)
+	offset := len(s) - len(skipWS(s))
+	var ri rawItem
+	for i, key := range keys {
+		ri = rawItem{}
+		if err := ri.find(s, offset, key); err != nil {
+			if _, ok := err.(*notContainerError); ok {
+				// The keys path is missing.
+				return data, nil
+			}
+			return nil, fmt.Errorf("cannot delete value for keys %q: %s", keys[:i+1], err)
+		}
+		if !ri.found {
+			return data, nil
+		}
+		offset = ri.valueStart
+	}
+
+	// Determine the span to cut.
+	start := ri.start
+	end := ri.valueEnd
+	tail := skipWS(s[end:])
+	switch {
+	case len(tail) > 0 && tail[0] == ',':
+		// Cut the item together with the trailing comma.
+		end = len(s) - len(skipWS(tail[1:]))
+	case ri.prevEnd >= 0:
+		// Cut the last item together with the preceding comma.
+		start = ri.prevEnd
+	default:
+		// Cut the only item together with the surrounding whitespace.
+		start = ri.containerStart + 1
+		end = len(s) - len(tail)
+	}
+	dst := make([]byte, 0, len(s)-(end-start))
+	dst = append(dst, s[:start]...)
+	return append(dst, s[end:]...), nil
+}
+
+// rawItem describes object member or array item in raw JSON.
+type rawItem struct {
+	// found is set if the item has been found.
+	found bool
+
+	// containerStart is the offset of the '{' or '[' of the container.
+	containerStart int
+
+	// start is the offset of the item. It points to the key
+	// for object members.
+	start int
+
+	// valueStart and valueEnd are the offsets of the item value.
+	valueStart int
+	valueEnd   int
+
+	// prevEnd is the end offset of the preceding item value.
+	// It is -1 for the first item. It is the end offset of the last item
+	// value in the container if the item isn't found.
+	prevEnd int
+
+	// The following fields are set only if the item isn't found.
+
+	// items is the number of items in the container.
+	items int
+
+	// itemPrefix is the whitespace between the first and the second items
+	// in the container or before the first item if the container
+	// has a single item.
+	itemPrefix string
+
+	// colon is the ':' with the surrounding whitespace after the first key
+	// in the object.
+	colon string
+
+	// isObject is set if the container is object.
+	isObject bool
+}
+
+// notContainerError is returned from rawItem.find if the value
+// isn't object or array.
+type notContainerError struct {
+	offset int
+}
+
+func (e *notContainerError) Error() string {
+	return fmt.Sprintf("value at offset %d isn't object or array", e.offset)
+}
+
+// find finds the item for the given key in the container starting
+// at the given offset in s.
+func (ri *rawItem) find(s string, offset int, key string) error {
+	if offset >= len(s) || (s[offset] != '{' && s[offset] != '[') {
+		return &notContainerError{
+			offset: offset,
+		}
+	}
+	ri.containerStart = offset
+	ri.isObject = s[offset] == '{'
+	ri.prevEnd = -1
+
+	idx := -1
+	if !ri.isObject {
+		n, err := strconv.Atoi(key)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid array index %q", key)
+		}
+		idx = n
+	}
+
+	tail := skipWS(s[offset+1:])
+	if len(tail) > 0 && (tail[0] == '}' || tail[0] == ']') {
+		return nil
+	}
+	ri.itemPrefix = s[offset+1 : len(s)-len(tail)]
+
+	var keyBuf []byte
+	var err error
+	for {
+		ri.start = len(s) - len(tail)
+		match := ri.items == idx
+		if ri.isObject {
+			if len(tail) == 0 || tail[0] != '"' {
+				return fmt.Errorf(`missing opening '"' for object key at offset %d`, ri.start)
+			}
+			tail, err = validateString(tail[1:])
+			if err != nil {
+				return fmt.Errorf("cannot parse object key at offset %d: %s", ri.start, err)
+			}
+			k := s[ri.start+1 : len(s)-len(tail)-1]
+			keyEnd := len(s) - len(tail)
+			tail = skipWS(tail)
+			if len(tail) == 0 || tail[0] != ':' {
+				return fmt.Errorf("missing ':' after object key at offset %d", len(s)-len(tail))
+			}
+			tail = skipWS(tail[1:])
+			if ri.items == 0 {
+				ri.colon = s[keyEnd : len(s)-len(tail)]
+			}
+			if strings.IndexByte(k, '\\') < 0 {
+				match = k == key
+			} else {
+				// Unescape the copy of k, since unescaping is performed in place.
+				keyBuf = append(keyBuf[:0], k...)
+				match = unescapeStringBestEffort(b2s(keyBuf)) == key
+			}
+		}
+
+		ri.valueStart = len(s) - len(tail)
+		tail, err = validateValue(tail, true)
+		if err != nil {
+			return fmt.Errorf("cannot parse JSON at offset %d: %s", len(s)-len(tail), err)
+		}
+		ri.valueEnd = len(s) - len(tail)
+		if match {
+			ri.found = true
+			return nil
+		}
+		ri.prevEnd = ri.valueEnd
+		ri.items++
+
+		tail = skipWS(tail)
+		if len(tail) == 0 {
+			return fmt.Errorf("unexpected end of JSON")
+		}
+		switch tail[0] {
+		case ',':
+			n := len(tail) - 1
+			tail = skipWS(tail[1:])
+			if ri.items == 1 {
+				// Use the whitespace between items for the inserted item.
+				ri.itemPrefix = s[len(s)-n : len(s)-len(tail)]
+			}
+		case '}', ']':
+			return nil
+		default:
+			return fmt.Errorf("missing ',' at offset %d", len(s)-len(tail))
+		}
+	}
+}
+
+// insert inserts value for the missing keys into the container described
+// by ri and returns the result.
+//
+// keys[0] is the key for the missing item.
+func (ri *rawItem) insert(s string, keys []string, value string) ([]byte, error) {
+	key := keys[0]
+	if !ri.isObject {
+		n, _ := strconv.Atoi(key)
+		if n != ri.items {
+			return nil, fmt.Errorf("cannot set value for array index %d, since the array contains %d items", n, ri.items)
+		}
+	}
+
+	offset := ri.containerStart + 1
+	dst := make([]byte, 0, len(s)+len(value)+len(ri.itemPrefix)+32)
+	if ri.items > 0 {
+		offset = ri.prevEnd
+		dst = append(dst, s[:offset]...)
+		dst = append(dst, ',')
+		dst = append(dst, ri.itemPrefix...)
+	} else {
+		dst = append(dst, s[:offset]...)
+	}
+	if ri.isObject {
+		dst = appendEscapedString(dst, key)
+		if ri.items > 0 {
+			dst = append(dst, ri.colon...)
+		} else {
+			dst = append(dst, ':')
+		}
+	}
+
+	// Create nested objects for the remaining keys.
+	for _, k := range keys[1:] {
+		dst = append(dst, '{')
+		dst = appendEscapedString(dst, k)
+		dst = append(dst, ':')
+	}
+	dst = append(dst, value...)
+	for range keys[1:] {
+		dst = append(dst, '}')
+	}
+	return append(dst, s[offset:]...), nil
+}
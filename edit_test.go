@@ -0,0 +1,140 @@
+package fastjson
+
+import (
+	"testing"
+)
+
+func TestSetBytes(t *testing.T) {
+	f := func(data, value string, keys []string, expected string) {
+		t.Helper()
+		dataCopy := data
+		result, err := SetBytes([]byte(data), []byte(value), keys...)
+		if err != nil {
+			t.Fatalf("unexpected error when setting %s at %q in %s: %s", value, keys, data, err)
+		}
+		if string(result) != expected {
+			t.Fatalf("unexpected result when setting %s at %q in %s;\ngot\n%s\nwant\n%s", value, keys, data, result, expected)
+		}
+		if data != dataCopy {
+			t.Fatalf("data has been modified")
+		}
+		if err := ValidateStrict(string(result)); err != nil {
+			t.Fatalf("invalid result %s: %s", result, err)
+		}
+	}
+
+	// Replace the whole value.
+	f(` 123 `, `"foo"`, nil, ` "foo" `)
+
+	// Replace existing values.
+	f(`{"a":1,"b":2}`, `[3]`, []string{"a"}, `{"a":[3],"b":2}`)
+	f(`{"a":1,"b":2}`, ` {"x": null} `, []string{"b"}, `{"a":1,"b":{"x": null}}`)
+	f(`{ "a" : { "b" : [ 1 , 2 ] } , "c" : 3 }`, `"x"`, []string{"a", "b", "1"}, `{ "a" : { "b" : [ 1 , "x" ] } , "c" : 3 }`)
+	f(`[{"a":"b"},{"a":"c"}]`, `true`, []string{"1", "a"}, `[{"a":"b"},{"a":true}]`)
+	f(`{"a\"b":1,"ab":2}`, `3`, []string{"ab"}, `{"a\"b":1,"ab":3}`)
+	f(`{"a\\\"":1}`, `3`, []string{`a\"`}, `{"a\\\"":3}`)
+	f(`{"a":1,"a":2}`, `3`, []string{"a"}, `{"a":3,"a":2}`)
+
+	// Insert missing keys.
+	f(`{}`, `1`, []string{"a"}, `{"a":1}`)
+	f(`{ }`, `1`, []string{"a"}, `{"a":1 }`)
+	f(`{"a":1}`, `2`, []string{"b"}, `{"a":1,"b":2}`)
+	f(`{"a":{"b":1}}`, `2`, []string{"a", "c", "d", "e"}, `{"a":{"b":1,"c":{"d":{"e":2}}}}`)
+	f("{\n  \"a\": 1\n}", `2`, []string{"b"}, "{\n  \"a\": 1,\n  \"b\": 2\n}")
+	f("{\n\t\"a\" : {\n\t\t\"x\": 1\n\t}\n}", `2`, []string{"a", "y/z"}, "{\n\t\"a\" : {\n\t\t\"x\": 1,\n\t\t\"y/z\": 2\n\t}\n}")
+	f(`{"a":1}`, `2`, []string{"b\n\"c"}, `{"a":1,"b\n\"c":2}`)
+
+	// Append to arrays.
+	f(`[]`, `1`, []string{"0"}, `[1]`)
+	f(`[1, 2]`, `3`, []string{"2"}, `[1, 2, 3]`)
+	f(`[ 1,2 ]`, `3`, []string{"2"}, `[ 1,2,3 ]`)
+	f("[\n  1\n]", `{}`, []string{"1"}, "[\n  1,\n  {}\n]")
+	f(`[1]`, `2`, []string{"1", "a"}, `[1,{"a":2}]`)
+}
+
+func TestSetBytesError(t *testing.T) {
+	f := func(data, value string, keys ...string) {
+		t.Helper()
+		result, err := SetBytes([]byte(data), []byte(value), keys...)
+		if err == nil {
+			t.Fatalf("expecting non-nil error when setting %s at %q in %s; got %s", value, keys, data, result)
+		}
+	}
+
+	// Invalid value.
+	f(`{}`, ``, "a")
+	f(`{}`, `foo`, "a")
+	f(`{}`, `1 2`, "a")
+	f(`{}`, `{"a":`, "a")
+
+	// Invalid data on keys path.
+	f(``, `1`, "a")
+	f(`{"a":`, `1`, "a")
+	f(`{"a" 1}`, `1`, "a")
+	f(`{"a":1 "b":2}`, `1`, "b")
+	f(`{"a":1,`, `1`, "b")
+	f(`[1,2`, `1`, "3")
+	f(`{"a":[1,2}`, `1`, "a")
+
+	// Scalar values on keys path.
+	f(`123`, `1`, "a")
+	f(`{"a":"b"}`, `1`, "a", "b")
+
+	// Invalid array indexes.
+	f(`[1,2]`, `1`, "a")
+	f(`[1,2]`, `1`, "-1")
+	f(`[1,2]`, `1`, "3")
+}
+
+func TestDeleteBytes(t *testing.T) {
+	f := func(data string, keys []string, expected string) {
+		t.Helper()
+		dataCopy := data
+		result, err := DeleteBytes([]byte(data), keys...)
+		if err != nil {
+			t.Fatalf("unexpected error when deleting %q from %s: %s", keys, data, err)
+		}
+		if string(result) != expected {
+			t.Fatalf("unexpected result when deleting %q from %s;\ngot\n%s\nwant\n%s", keys, data, result, expected)
+		}
+		if data != dataCopy {
+			t.Fatalf("data has been modified")
+		}
+	}
+
+	f(`{"a":1,"b":2,"c":3}`, []string{"a"}, `{"b":2,"c":3}`)
+	f(`{"a":1,"b":2,"c":3}`, []string{"b"}, `{"a":1,"c":3}`)
+	f(`{"a":1,"b":2,"c":3}`, []string{"c"}, `{"a":1,"b":2}`)
+	f(`{"a":1}`, []string{"a"}, `{}`)
+	f(`{ "a" : 1 }`, []string{"a"}, `{}`)
+	f("{\n  \"a\": 1,\n  \"b\": [1, 2],\n  \"c\": 3\n}", []string{"b"}, "{\n  \"a\": 1,\n  \"c\": 3\n}")
+	f("{\n  \"a\": 1,\n  \"b\": 2\n}", []string{"b"}, "{\n  \"a\": 1\n}")
+	f(`{"a":{"b":[1,{"c":2,"d":3}]}}`, []string{"a", "b", "1", "c"}, `{"a":{"b":[1,{"d":3}]}}`)
+	f(`[1, 2, 3]`, []string{"0"}, `[2, 3]`)
+	f(`[1, 2, 3]`, []string{"1"}, `[1, 3]`)
+	f(`[1, 2, 3]`, []string{"2"}, `[1, 2]`)
+	f(`[ [] ]`, []string{"0"}, `[]`)
+	f(`{"ab":1,"c":2}`, []string{"ab"}, `{"c":2}`)
+
+	// Missing keys path.
+	f(`{"a":1}`, []string{"b"}, `{"a":1}`)
+	f(`{"a":1}`, []string{"a", "b"}, `{"a":1}`)
+	f(`{}`, []string{"a"}, `{}`)
+	f(`[1]`, []string{"1"}, `[1]`)
+	f(`123`, []string{"a"}, `123`)
+}
+
+func TestDeleteBytesError(t *testing.T) {
+	f := func(data string, keys ...string) {
+		t.Helper()
+		result, err := DeleteBytes([]byte(data), keys...)
+		if err == nil {
+			t.Fatalf("expecting non-nil error when deleting %q from %s; got %s", keys, data, result)
+		}
+	}
+
+	f(`{"a":1}`)
+	f(`{"a":`, "a")
+	f(`{"a":1 "b":2}`, "b")
+	f(`[1,2]`, "a")
+}
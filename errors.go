@@ -0,0 +1,51 @@
+package fastjson
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ParseError is returned from Parser, Scanner, ParallelScanner
+// and Tokenizer on invalid JSON.
+type ParseError struct {
+	// Offset is the byte offset in the input where the error
+	// has been detected.
+	Offset int
+
+	// Err describes the error.
+	//
+	// It is *LimitError if a limit from Parser.Limits or Scanner.Limits
+	// is exceeded.
+	Err error
+
+	// msg is the error message. It is built on ParseError creation,
+	// since the unparsed tail points to Parser buffer, which may change
+	// on the next Parse call.
+	msg string
+}
+
+// Error implements error interface.
+func (e *ParseError) Error() string {
+	return e.msg
+}
+
+var errUnexpectedTail = errors.New("unexpected tail")
+
+// newParseError returns ParseError for err detected at the given tail
+// of the input.
+func (c *cache) newParseError(err error, tail string) *ParseError {
+	var msg string
+	if err == errUnexpectedTail {
+		msg = fmt.Sprintf("unexpected tail: %q", tail)
+	} else {
+		msg = fmt.Sprintf("cannot parse JSON: %s; unparsed tail: %q", err, tail)
+	}
+	if c.limitErr != nil {
+		err = c.limitErr
+	}
+	return &ParseError{
+		Offset: c.inputLen - len(tail),
+		Err:    err,
+		msg:    msg,
+	}
+}
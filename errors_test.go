@@ -0,0 +1,41 @@
+package fastjson
+
+import (
+	"testing"
+)
+
+func TestParseError(t *testing.T) {
+	f := func(relaxed bool, s string, expectedOffset int, expectedMsg string) {
+		t.Helper()
+		p := &Parser{
+			Relaxed: relaxed,
+		}
+		_, err := p.Parse(s)
+		if err == nil {
+			t.Fatalf("expecting non-nil error when parsing %q", s)
+		}
+		pe, ok := err.(*ParseError)
+		if !ok {
+			t.Fatalf("unexpected error type when parsing %q; got %T; want *ParseError", s, err)
+		}
+		if pe.Offset != expectedOffset {
+			t.Fatalf("unexpected offset when parsing %q; got %d; want %d", s, pe.Offset, expectedOffset)
+		}
+
+		// The error message mustn't change on subsequent parsing.
+		if _, err := p.Parse(`{"foo":"bar"}`); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if pe.Error() != expectedMsg {
+			t.Fatalf("unexpected error message when parsing %q;\ngot\n%s\nwant\n%s", s, pe.Error(), expectedMsg)
+		}
+	}
+
+	for _, relaxed := range []bool{false, true} {
+		f(relaxed, ``, 0, `cannot parse JSON: cannot parse empty string; unparsed tail: ""`)
+		f(relaxed, `  [1,2 3]`, 7, `cannot parse JSON: cannot parse array: missing ',' after array value; unparsed tail: "3]"`)
+		f(relaxed, `{"a":1} x`, 8, `unexpected tail: "x"`)
+	}
+	f(false, `{"a":1,}`, 7, `cannot parse JSON: cannot parse object: cannot parse object key: missing opening '"'; unparsed tail: "}"`)
+	f(true, `/* x`, 0, `cannot parse JSON: missing '*/' for block comment; unparsed tail: "/* x"`)
+}
@@ -0,0 +1,230 @@
+// Package fastjsonhttp provides net/http helpers for parsing JSON
+// request bodies with fastjson.
+//
+// Request bodies are read into pooled buffers and parsed by pooled parsers,
+// so handlers don't allocate memory for parsing in the steady state.
+package fastjsonhttp
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/valyala/fastjson"
+)
+
+// DefaultMaxBodySize is the default limit on request body size.
+const DefaultMaxBodySize = 1024 * 1024
+
+// BodyParser reads and parses JSON request bodies.
+//
+// BodyParser may be used from concurrent goroutines.
+type BodyParser struct {
+	// MaxBodySize is the maximum request body size in bytes.
+	// Bigger bodies are rejected with 413 Request Entity Too Large.
+	//
+	// DefaultMaxBodySize is used by default.
+	MaxBodySize int
+
+	// ParserPool is used for obtaining parsers.
+	//
+	// A package-level pool is used by default.
+	ParserPool *fastjson.ParserPool
+}
+
+var (
+	defaultParserPool fastjson.ParserPool
+	bodyBufPool       sync.Pool
+)
+
+// Error is returned from BodyParser.Parse.
+type Error struct {
+	// StatusCode is the HTTP status code for the response.
+	//
+	// It is http.StatusBadRequest for invalid JSON and
+	// http.StatusRequestEntityTooLarge for too big request body.
+	StatusCode int
+
+	// Err is the underlying error.
+	//
+	// It is *fastjson.ParseError for invalid JSON.
+	Err error
+}
+
+// Error implements error interface.
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Parse reads and parses r body.
+//
+// release must be called when the returned value is no longer used.
+// The value cannot be used after release call. release is nil on error.
+//
+// The returned error is *Error. Use WriteError for sending it to the client.
+func (bp *BodyParser) Parse(r *http.Request) (v *fastjson.Value, release func(), err error) {
+	maxBodySize := bp.MaxBodySize
+	if maxBodySize <= 0 {
+		maxBodySize = DefaultMaxBodySize
+	}
+	if r.ContentLength > int64(maxBodySize) {
+		return nil, nil, tooBigBodyError(maxBodySize)
+	}
+
+	var bb *[]byte
+	if x := bodyBufPool.Get(); x != nil {
+		bb = x.(*[]byte)
+	} else {
+		bb = new([]byte)
+	}
+	defer bodyBufPool.Put(bb)
+	b, err := readBody((*bb)[:0], r.Body, maxBodySize)
+	*bb = b
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pp := bp.ParserPool
+	if pp == nil {
+		pp = &defaultParserPool
+	}
+	p := pp.Get()
+
+	// The parser holds a copy of the body, so the body buffer
+	// may be returned to the pool right after parsing.
+	v, err = p.ParseBytes(b)
+	if err != nil {
+		pp.Put(p)
+		return nil, nil, &Error{
+			StatusCode: http.StatusBadRequest,
+			Err:        err,
+		}
+	}
+	return v, func() { pp.Put(p) }, nil
+}
+
+// Handler returns http.Handler, which parses JSON request body
+// and passes the parsed value to h.
+//
+// Error response is sent to the client if the body cannot be parsed.
+// See WriteError for details.
+//
+// h cannot hold v after returning.
+func (bp *BodyParser) Handler(h func(w http.ResponseWriter, r *http.Request, v *fastjson.Value)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		v, release, err := bp.Parse(r)
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+		defer release()
+		h(w, r, v)
+	})
+}
+
+// WriteError sends JSON response with err to the client.
+//
+// The response status code is taken from *Error. The response contains
+// the offset of the error in the request body for *fastjson.ParseError.
+// Error messages are truncated to maxErrorMessageLen bytes, so big
+// request bodies aren't echoed back to the client.
+//
+// 500 Internal Server Error with a generic message is sent for other errors,
+// since they may contain internal details. Such errors are logged
+// with the standard logger.
+func WriteError(w http.ResponseWriter, err error) {
+	statusCode := http.StatusInternalServerError
+	msg := "internal server error"
+	offset := -1
+	if e, ok := err.(*Error); ok {
+		statusCode = e.StatusCode
+		msg = truncateMessage(e.Error(), maxErrorMessageLen)
+		if pe, ok := e.Err.(*fastjson.ParseError); ok {
+			offset = pe.Offset
+		}
+	} else {
+		log.Printf("fastjsonhttp: cannot process request: %s", err)
+	}
+
+	var jw fastjson.Writer
+	jw.BeginObject()
+	jw.Key("error")
+	jw.String(msg)
+	if offset >= 0 {
+		jw.Key("offset")
+		jw.Int64(int64(offset))
+	}
+	jw.EndObject()
+	jw.Close()
+
+	h := w.Header()
+	h.Set("Content-Type", "application/json")
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(statusCode)
+	w.Write(jw.Bytes())
+}
+
+// readBody appends the body to dst.
+//
+// *Error is returned if the body exceeds maxBodySize.
+func readBody(dst []byte, body io.Reader, maxBodySize int) ([]byte, error) {
+	if body == nil {
+		return dst, nil
+	}
+
+	// Read up to maxBodySize+1 bytes, so too big bodies are detected
+	// without reading and buffering them in full.
+	r := io.LimitReader(body, int64(maxBodySize)+1)
+	for {
+		if len(dst) == cap(dst) {
+			n := 2*cap(dst) + 512
+			if n > maxBodySize+1 {
+				n = maxBodySize + 1
+			}
+			b := make([]byte, len(dst), n)
+			copy(b, dst)
+			dst = b
+		}
+		n, err := r.Read(dst[len(dst):cap(dst)])
+		dst = dst[:len(dst)+n]
+		if len(dst) > maxBodySize {
+			return dst, tooBigBodyError(maxBodySize)
+		}
+		if err == io.EOF {
+			return dst, nil
+		}
+		if err != nil {
+			return dst, &Error{
+				StatusCode: http.StatusBadRequest,
+				Err:        fmt.Errorf("cannot read request body: %s", err),
+			}
+		}
+	}
+}
+
+// maxErrorMessageLen is the maximum length of error message
+// sent to the client by WriteError.
+const maxErrorMessageLen = 256
+
+// truncateMessage truncates msg to maxLen bytes without splitting
+// multi-byte chars.
+func truncateMessage(msg string, maxLen int) string {
+	if len(msg) <= maxLen {
+		return msg
+	}
+	n := maxLen
+	for n > 0 && !utf8.RuneStart(msg[n]) {
+		n--
+	}
+	return msg[:n] + "..."
+}
+
+func tooBigBodyError(maxBodySize int) *Error {
+	return &Error{
+		StatusCode: http.StatusRequestEntityTooLarge,
+		Err:        fmt.Errorf("request body exceeds %d bytes", maxBodySize),
+	}
+}
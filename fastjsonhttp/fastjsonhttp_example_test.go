@@ -0,0 +1,31 @@
+package fastjsonhttp_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/valyala/fastjson"
+	"github.com/valyala/fastjson/fastjsonhttp"
+)
+
+func ExampleBodyParser_Handler() {
+	bp := &fastjsonhttp.BodyParser{
+		MaxBodySize: 4096,
+	}
+	h := bp.Handler(func(w http.ResponseWriter, r *http.Request, v *fastjson.Value) {
+		fmt.Fprintf(w, "hello, %s", v.GetStringBytes("name"))
+	})
+
+	for _, body := range []string{`{"name":"gopher"}`, `{"name":`} {
+		r := httptest.NewRequest("POST", "/greet", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		fmt.Printf("%d %s\n", w.Code, w.Body)
+	}
+
+	// Output:
+	// 200 hello, gopher
+	// 400 {"error":"cannot parse JSON: cannot parse object: cannot parse object value: cannot parse empty string; unparsed tail: \"\"","offset":8}
+}
@@ -0,0 +1,182 @@
+package fastjsonhttp
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/valyala/fastjson"
+)
+
+func TestBodyParserHandler(t *testing.T) {
+	bp := &BodyParser{
+		MaxBodySize: 64,
+	}
+	h := bp.Handler(func(w http.ResponseWriter, r *http.Request, v *fastjson.Value) {
+		w.Write(v.GetStringBytes("name"))
+	})
+
+	f := func(body string, expectedStatusCode int, expectedBody string) {
+		t.Helper()
+		r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != expectedStatusCode {
+			t.Fatalf("unexpected status code for %q; got %d; want %d", body, w.Code, expectedStatusCode)
+		}
+		if w.Body.String() != expectedBody {
+			t.Fatalf("unexpected response body for %q;\ngot\n%s\nwant\n%s", body, w.Body.String(), expectedBody)
+		}
+		if expectedStatusCode != http.StatusOK {
+			if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+				t.Fatalf("unexpected Content-Type; got %q; want %q", ct, "application/json")
+			}
+			if err := fastjson.ValidateStrict(w.Body.String()); err != nil {
+				t.Fatalf("invalid JSON in error response: %s", err)
+			}
+		}
+	}
+
+	f(`{"name":"foo"}`, http.StatusOK, "foo")
+	f(`{"name":"<bar>"}`, http.StatusOK, "<bar>")
+	f(``, http.StatusBadRequest, `{"error":"cannot parse JSON: cannot parse empty string; unparsed tail: \"\"","offset":0}`)
+	f(`{"name":}`, http.StatusBadRequest, `{"error":"cannot parse JSON: cannot parse object: cannot parse object value: cannot parse number: unexpected char: \"}\"; unparsed tail: \"}\"","offset":8}`)
+	f(`{"name":"`+strings.Repeat("x", 64)+`"}`, http.StatusRequestEntityTooLarge, `{"error":"request body exceeds 64 bytes"}`)
+}
+
+func TestBodyParserParse(t *testing.T) {
+	var bp BodyParser
+	var pp fastjson.ParserPool
+	bp.ParserPool = &pp
+
+	// The body must be read in full even if it is bigger than the pooled buffer.
+	body := `[` + strings.Repeat(`"xxxxxxxx",`, 10000) + `1]`
+	for i := 0; i < 3; i++ {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		v, release, err := bp.Parse(r)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if n := len(v.GetArray()); n != 10001 {
+			t.Fatalf("unexpected number of items; got %d; want %d", n, 10001)
+		}
+		release()
+	}
+
+	// Content-Length exceeding the limit.
+	bp.MaxBodySize = 10
+	r := httptest.NewRequest("POST", "/", strings.NewReader(`[1,2,3,4,5]`))
+	_, release, err := bp.Parse(r)
+	if err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+	if release != nil {
+		t.Fatalf("release must be nil on error")
+	}
+	if e, ok := err.(*Error); !ok || e.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	// Unknown Content-Length.
+	r = httptest.NewRequest("POST", "/", strings.NewReader(`[1,2,3,4,5]`))
+	r.ContentLength = -1
+	_, _, err = bp.Parse(r)
+	if e, ok := err.(*Error); !ok || e.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+}
+
+type errorReader struct{}
+
+func (er errorReader) Read(p []byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func TestBodyParserReadError(t *testing.T) {
+	var bp BodyParser
+	r := httptest.NewRequest("POST", "/", errorReader{})
+	_, _, err := bp.Parse(r)
+	e, ok := err.(*Error)
+	if !ok || e.StatusCode != http.StatusBadRequest {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+	if !strings.Contains(e.Error(), "connection reset") {
+		t.Fatalf("unexpected error message: %s", e)
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	var logBuf bytes.Buffer
+	log.SetOutput(&logBuf)
+	defer log.SetOutput(os.Stderr)
+
+	w := httptest.NewRecorder()
+	WriteError(w, errors.New("cannot open /secret/db"))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("unexpected status code; got %d; want %d", w.Code, http.StatusInternalServerError)
+	}
+	if s := w.Body.String(); s != `{"error":"internal server error"}` {
+		t.Fatalf("unexpected response body: %s", s)
+	}
+	if !strings.Contains(logBuf.String(), "cannot open /secret/db") {
+		t.Fatalf("the error must be logged; got %q", logBuf.String())
+	}
+}
+
+func TestWriteErrorTruncate(t *testing.T) {
+	var bp BodyParser
+	body := `[1,` + strings.Repeat("\u00e9x", 1000) + `]`
+	r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+	_, _, err := bp.Parse(r)
+	if err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+	w := httptest.NewRecorder()
+	WriteError(w, err)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("unexpected status code; got %d; want %d", w.Code, http.StatusBadRequest)
+	}
+	var p fastjson.Parser
+	v, err := p.ParseBytes(w.Body.Bytes())
+	if err != nil {
+		t.Fatalf("cannot parse response: %s", err)
+	}
+	msg := v.GetStringBytes("error")
+	if len(msg) > maxErrorMessageLen+len("...") {
+		t.Fatalf("too long error message; got %d bytes; want up to %d bytes", len(msg), maxErrorMessageLen+len("..."))
+	}
+	if !utf8.Valid(msg) {
+		t.Fatalf("invalid UTF-8 in error message %q", msg)
+	}
+	if offset := v.GetInt("offset"); offset != 3 {
+		t.Fatalf("unexpected offset; got %d; want %d", offset, 3)
+	}
+}
+
+type endlessReader struct{}
+
+func (er endlessReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = ' '
+	}
+	return len(p), nil
+}
+
+func TestReadBodyMaxSize(t *testing.T) {
+	for _, maxBodySize := range []int{1, 100, 1000, 12345} {
+		b, err := readBody(nil, endlessReader{}, maxBodySize)
+		e, ok := err.(*Error)
+		if !ok || e.StatusCode != http.StatusRequestEntityTooLarge {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+		if cap(b) > maxBodySize+1 {
+			t.Fatalf("too big buffer for maxBodySize=%d; got %d bytes; want up to %d bytes", maxBodySize, cap(b), maxBodySize+1)
+		}
+	}
+}
@@ -0,0 +1,344 @@
+package fastjson
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Follower follows a growing file with JSON lines ( http://jsonlines.org/ )
+// such as an application log and parses the appended values.
+//
+// Only complete lines are parsed. A partial line at the end of the file
+// is parsed after the rest of the line is appended.
+//
+// Follower detects file truncation and rotation. Truncated file
+// is scanned from the start. Rotated file is scanned until the end,
+// then the new file at the same path is opened and scanned from the start.
+//
+// Follower may be re-used after Follow returns.
+//
+// Follower cannot be used from concurrent goroutines.
+type Follower struct {
+	// PollInterval is the interval for checking the file for new data,
+	// truncation and rotation when the end of the file is reached.
+	//
+	// 200ms is used by default.
+	PollInterval time.Duration
+
+	// SkipInvalid enables skipping invalid lines.
+	// See Scanner.SkipInvalid for details.
+	//
+	// Follow stops on the first invalid line by default.
+	SkipInvalid bool
+
+	// ErrorHandler is called for each invalid line skipped
+	// if SkipInvalid is set.
+	//
+	// Offsets in err are counted from the start of the current file.
+	//
+	// err cannot be held after returning from ErrorHandler.
+	ErrorHandler func(err *ScanError)
+
+	// ParserPool is used by FollowChan for obtaining parsers
+	// for the sent values.
+	//
+	// A package-level pool is used by default.
+	ParserPool *ParserPool
+
+	sc Scanner
+
+	// p is the parser for the next value sent by FollowChan.
+	p *Parser
+
+	// f is the currently followed file.
+	f *os.File
+
+	// offset is the offset in f after the last complete line.
+	offset int
+
+	// index is the number of records read from f.
+	index int
+
+	// b contains the data read from f after offset.
+	b []byte
+
+	// parsing is set while the lines from b are parsed.
+	parsing bool
+}
+
+const defaultFollowPollInterval = 200 * time.Millisecond
+
+const followReadSize = 64 * 1024
+
+// Follow calls callback for each JSON value parsed from f until ctx
+// is cancelled.
+//
+// Scanning starts at the current position of f, so f may be positioned
+// at the end of the file for following only the new lines or at the offset
+// obtained via Offset for resuming after restart.
+//
+// Follow stops on the first error returned from callback, on read error
+// and on invalid line unless SkipInvalid is set. The error is returned
+// from Follow. ctx.Err() is returned after ctx is cancelled.
+//
+// Files opened by Follow after rotation are closed before returning,
+// while f remains open.
+//
+// callback cannot hold v after returning.
+func (fl *Follower) Follow(ctx context.Context, f *os.File, callback func(v *Value) error) error {
+	fl.sc.newCache = nil
+	return fl.follow(ctx, f, callback)
+}
+
+func (fl *Follower) follow(ctx context.Context, f *os.File, callback func(v *Value) error) error {
+	pos, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("cannot determine the current position in %q: %s", f.Name(), err)
+	}
+	fl.f = f
+	fl.offset = int(pos)
+	fl.index = 0
+	fl.b = fl.b[:0]
+	defer func() {
+		if fl.f != f {
+			fl.f.Close()
+		}
+		fl.f = nil
+	}()
+
+	pollInterval := fl.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = defaultFollowPollInterval
+	}
+	var t *time.Timer
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n, err := fl.read()
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			if err := fl.parseLines(false, callback); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// The end of the file is reached.
+		rotated, err := fl.checkFile()
+		if err != nil {
+			return err
+		}
+		if rotated != nil {
+			// Read the data appended to the rotated file before the rotation
+			// has been detected. Then parse the last line in the rotated file,
+			// which may miss the trailing newline.
+			for {
+				n, err := fl.read()
+				if err != nil {
+					rotated.Close()
+					return err
+				}
+				if n == 0 {
+					break
+				}
+			}
+			if err := fl.parseLines(true, callback); err != nil {
+				rotated.Close()
+				return err
+			}
+			if fl.f != f {
+				fl.f.Close()
+			}
+			fl.f = rotated
+			fl.offset = 0
+			fl.index = 0
+			fl.b = fl.b[:0]
+			continue
+		}
+
+		if t == nil {
+			t = time.NewTimer(pollInterval)
+			defer t.Stop()
+		} else {
+			t.Reset(pollInterval)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// FollowedValue is a JSON value sent by Follower.FollowChan.
+type FollowedValue struct {
+	// Value is the parsed value.
+	//
+	// It remains valid until Release call.
+	Value *Value
+
+	p  *Parser
+	pp *ParserPool
+}
+
+// Release returns the memory occupied by fv.Value to the pool,
+// so it may be re-used for parsing subsequent values.
+//
+// fv.Value cannot be used after Release call.
+func (fv *FollowedValue) Release() {
+	fv.pp.Put(fv.p)
+	fv.Value = nil
+	fv.p = nil
+	fv.pp = nil
+}
+
+var followParserPool ParserPool
+
+// FollowChan sends JSON values parsed from f to ch until ctx is cancelled.
+//
+// See Follow for details.
+//
+// Each value is parsed into a distinct Parser obtained from ParserPool,
+// so it remains valid after being received from ch. The receiver must call
+// FollowedValue.Release after the value is no longer needed.
+func (fl *Follower) FollowChan(ctx context.Context, f *os.File, ch chan<- *FollowedValue) error {
+	pp := fl.ParserPool
+	if pp == nil {
+		pp = &followParserPool
+	}
+	fl.sc.newCache = func() *cache {
+		// Re-use the parser if the previous value hasn't been sent,
+		// e.g. because it is invalid.
+		if fl.p == nil {
+			fl.p = pp.Get()
+		}
+		return &fl.p.c
+	}
+	defer func() {
+		if fl.p != nil {
+			pp.Put(fl.p)
+			fl.p = nil
+		}
+		fl.sc.newCache = nil
+	}()
+	return fl.follow(ctx, f, func(v *Value) error {
+		fv := &FollowedValue{
+			Value: v,
+			p:     fl.p,
+			pp:    pp,
+		}
+		select {
+		case ch <- fv:
+			fl.p = nil
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+// Offset returns the offset in the followed file after the last parsed line.
+//
+// The offset is reset to zero on file truncation and rotation.
+//
+// Offset may be called from the callback passed to Follow. In this case
+// it returns the offset after the value passed to the callback, so it may
+// be stored for resuming after restart. See Scanner.Offset for details.
+func (fl *Follower) Offset() int {
+	if fl.parsing {
+		return fl.sc.Offset()
+	}
+	return fl.offset
+}
+
+// read reads the next portion of data from fl.f into fl.b.
+//
+// It returns zero at the end of the file.
+func (fl *Follower) read() (int, error) {
+	if cap(fl.b)-len(fl.b) < followReadSize {
+		b := make([]byte, len(fl.b), 2*cap(fl.b)+followReadSize)
+		copy(b, fl.b)
+		fl.b = b
+	}
+	n, err := fl.f.Read(fl.b[len(fl.b):cap(fl.b)])
+	fl.b = fl.b[:len(fl.b)+n]
+	if err != nil && err != io.EOF {
+		return n, fmt.Errorf("cannot read %q: %s", fl.f.Name(), err)
+	}
+	return n, nil
+}
+
+// parseLines parses complete lines in fl.b and passes the parsed values
+// to callback.
+//
+// The partial line at the end of fl.b is parsed too if all is set.
+func (fl *Follower) parseLines(all bool, callback func(v *Value) error) error {
+	n := len(fl.b)
+	if !all {
+		n = bytes.LastIndexByte(fl.b, '\n') + 1
+		if n == 0 {
+			// Wait for the rest of the line.
+			return nil
+		}
+	}
+
+	sc := &fl.sc
+	sc.SkipInvalid = fl.SkipInvalid
+	sc.ErrorHandler = fl.ErrorHandler
+	sc.InitAt(b2s(fl.b[:n]), fl.offset, fl.index)
+	fl.parsing = true
+	for sc.Next() {
+		if err := callback(sc.Value()); err != nil {
+			fl.parsing = false
+			return err
+		}
+	}
+	fl.parsing = false
+	if err := sc.Error(); err != nil {
+		return fmt.Errorf("cannot parse %q at offset %d: %s", fl.f.Name(), sc.Offset(), err)
+	}
+	fl.offset = sc.Offset()
+	fl.index = sc.Index()
+	fl.b = append(fl.b[:0], fl.b[n:]...)
+	return nil
+}
+
+// checkFile checks whether fl.f has been truncated or rotated.
+//
+// The file is re-positioned to the start on truncation. The new file
+// is returned on rotation.
+func (fl *Follower) checkFile() (*os.File, error) {
+	fi, err := fl.f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("cannot stat %q: %s", fl.f.Name(), err)
+	}
+	if fi.Size() < int64(fl.offset+len(fl.b)) {
+		// The file has been truncated.
+		if _, err := fl.f.Seek(0, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("cannot seek to the start of truncated %q: %s", fl.f.Name(), err)
+		}
+		fl.offset = 0
+		fl.index = 0
+		fl.b = fl.b[:0]
+		return nil, nil
+	}
+
+	pathFi, err := os.Stat(fl.f.Name())
+	if err != nil || os.SameFile(fi, pathFi) {
+		// The file isn't rotated or the new file isn't created yet.
+		return nil, nil
+	}
+	f, err := os.Open(fl.f.Name())
+	if err != nil {
+		// The new file may be removed or renamed again. Try opening it later.
+		return nil, nil
+	}
+	return f, nil
+}
@@ -0,0 +1,315 @@
+package fastjson
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type followTest struct {
+	t    *testing.T
+	path string
+
+	mu     sync.Mutex
+	values []string
+	errs   []string
+}
+
+func newFollowTest(t *testing.T) (*followTest, func()) {
+	dir, err := ioutil.TempDir("", "fastjson-follow")
+	if err != nil {
+		t.Fatalf("cannot create temporary dir: %s", err)
+	}
+	ft := &followTest{
+		t:    t,
+		path: filepath.Join(dir, "log.json"),
+	}
+	ft.create("")
+	return ft, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func (ft *followTest) create(data string) {
+	ft.t.Helper()
+	if err := ioutil.WriteFile(ft.path, []byte(data), 0644); err != nil {
+		ft.t.Fatalf("cannot write %q: %s", ft.path, err)
+	}
+}
+
+func (ft *followTest) append(data string) {
+	ft.t.Helper()
+	f, err := os.OpenFile(ft.path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		ft.t.Fatalf("cannot open %q: %s", ft.path, err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(data); err != nil {
+		ft.t.Fatalf("cannot write to %q: %s", ft.path, err)
+	}
+}
+
+func (ft *followTest) follow(ctx context.Context, fl *Follower, f *os.File) error {
+	fl.PollInterval = time.Millisecond
+	fl.ErrorHandler = func(err *ScanError) {
+		ft.mu.Lock()
+		ft.errs = append(ft.errs, fmt.Sprintf("%d:%d", err.Index, err.Offset))
+		ft.mu.Unlock()
+	}
+	return fl.Follow(ctx, f, func(v *Value) error {
+		ft.mu.Lock()
+		ft.values = append(ft.values, fmt.Sprintf("%s@%d", v, fl.Offset()))
+		ft.mu.Unlock()
+		return nil
+	})
+}
+
+func (ft *followTest) waitValues(expected string) {
+	ft.t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		ft.mu.Lock()
+		s := strings.Join(ft.values, " ")
+		ft.mu.Unlock()
+		if s == expected {
+			return
+		}
+		if time.Now().After(deadline) {
+			ft.t.Fatalf("unexpected values;\ngot\n%s\nwant\n%s", s, expected)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestFollower(t *testing.T) {
+	ft, cleanup := newFollowTest(t)
+	defer cleanup()
+
+	ft.create("{\"a\":1}\n")
+	f, err := os.Open(ft.path)
+	if err != nil {
+		t.Fatalf("cannot open %q: %s", ft.path, err)
+	}
+	defer f.Close()
+
+	fl := &Follower{
+		SkipInvalid: true,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan error, 1)
+	go func() {
+		ch <- ft.follow(ctx, fl, f)
+	}()
+	ft.waitValues(`{"a":1}@7`)
+
+	// Partial lines must be parsed only after they are complete.
+	ft.append("[2]\n[3")
+	ft.waitValues(`{"a":1}@7 [2]@11`)
+	ft.append(",4]\nbad\n5\n")
+	ft.waitValues(`{"a":1}@7 [2]@11 [3,4]@17 5@23`)
+
+	// Truncation.
+	ft.create("")
+	time.Sleep(10 * time.Millisecond)
+	ft.append("6\n")
+	ft.waitValues(`{"a":1}@7 [2]@11 [3,4]@17 5@23 6@1`)
+
+	// Rotation. The last line of the rotated file has no trailing newline.
+	ft.append("7")
+	if err := os.Rename(ft.path, ft.path+".1"); err != nil {
+		t.Fatalf("cannot rename %q: %s", ft.path, err)
+	}
+	ft.create("8\n")
+	ft.waitValues(`{"a":1}@7 [2]@11 [3,4]@17 5@23 6@1 7@3 8@1`)
+	ft.append("9\n")
+	ft.waitValues(`{"a":1}@7 [2]@11 [3,4]@17 5@23 6@1 7@3 8@1 9@3`)
+
+	cancel()
+	select {
+	case err := <-ch:
+		if err != context.Canceled {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout")
+	}
+
+	ft.mu.Lock()
+	errs := strings.Join(ft.errs, " ")
+	ft.mu.Unlock()
+	if errs != "3:18" {
+		t.Fatalf("unexpected errors; got %q; want %q", errs, "3:18")
+	}
+}
+
+func TestFollowerResume(t *testing.T) {
+	ft, cleanup := newFollowTest(t)
+	defer cleanup()
+
+	ft.create("1\n2\n3\n")
+	f, err := os.Open(ft.path)
+	if err != nil {
+		t.Fatalf("cannot open %q: %s", ft.path, err)
+	}
+	defer f.Close()
+
+	// Resume after the first line.
+	if _, err := f.Seek(2, 0); err != nil {
+		t.Fatalf("cannot seek: %s", err)
+	}
+	var fl Follower
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan error, 1)
+	go func() {
+		ch <- ft.follow(ctx, &fl, f)
+	}()
+	ft.waitValues(`2@3 3@5`)
+	cancel()
+	if err := <-ch; err != context.Canceled {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestFollowerError(t *testing.T) {
+	ft, cleanup := newFollowTest(t)
+	defer cleanup()
+
+	ft.create("1\nbad\n2\n")
+	f, err := os.Open(ft.path)
+	if err != nil {
+		t.Fatalf("cannot open %q: %s", ft.path, err)
+	}
+	defer f.Close()
+
+	var fl Follower
+	err = ft.follow(context.Background(), &fl, f)
+	if err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+	if !strings.Contains(err.Error(), "at offset 2") {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	ft.waitValues(`1@1`)
+
+	// Callback errors must be returned from Follow.
+	if _, err := f.Seek(0, 0); err != nil {
+		t.Fatalf("cannot seek: %s", err)
+	}
+	errCallback := fmt.Errorf("callback error")
+	err = fl.Follow(context.Background(), f, func(v *Value) error {
+		return errCallback
+	})
+	if err != errCallback {
+		t.Fatalf("unexpected error; got %v; want %v", err, errCallback)
+	}
+}
+
+func TestFollowerFollowChan(t *testing.T) {
+	ft, cleanup := newFollowTest(t)
+	defer cleanup()
+
+	ft.create("{\"a\":1}\n{\"a\":2}\n")
+	f, err := os.Open(ft.path)
+	if err != nil {
+		t.Fatalf("cannot open %q: %s", ft.path, err)
+	}
+	defer f.Close()
+
+	fl := &Follower{
+		PollInterval: time.Millisecond,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan *FollowedValue)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- fl.FollowChan(ctx, f, ch)
+	}()
+
+	// Values must remain valid after being received from ch
+	// until they are released.
+	var values []*FollowedValue
+	for i := 0; i < 3; i++ {
+		if i == 2 {
+			ft.append("{\"a\":3}\n")
+		}
+		select {
+		case fv := <-ch:
+			values = append(values, fv)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout")
+		}
+	}
+	for i, fv := range values {
+		if n := fv.Value.GetInt("a"); n != i+1 {
+			t.Fatalf("unexpected value #%d: %s", i, fv.Value)
+		}
+		fv.Release()
+	}
+	cancel()
+	if err := <-errCh; err != context.Canceled {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestFollowerFollowChanSkipInvalid(t *testing.T) {
+	ft, cleanup := newFollowTest(t)
+	defer cleanup()
+
+	ft.create("{\"a\":\"x\\ny\"}\nfoo\n")
+	f, err := os.Open(ft.path)
+	if err != nil {
+		t.Fatalf("cannot open %q: %s", ft.path, err)
+	}
+	defer f.Close()
+
+	var pp ParserPool
+	fl := &Follower{
+		PollInterval: time.Millisecond,
+		SkipInvalid:  true,
+		ParserPool:   &pp,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan *FollowedValue)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- fl.FollowChan(ctx, f, ch)
+	}()
+
+	var values []*FollowedValue
+	for i := 0; i < 3; i++ {
+		if i == 1 {
+			ft.append("[1,2]\n{\"a\":\"z\\tw\"}\n")
+		}
+		select {
+		case fv := <-ch:
+			values = append(values, fv)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout")
+		}
+	}
+	cancel()
+	if err := <-errCh; err != context.Canceled {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Values must remain valid after subsequent lines are read.
+	expected := []string{`{"a":"x\ny"}`, `[1,2]`, `{"a":"z\tw"}`}
+	for i, fv := range values {
+		if s := fv.Value.String(); s != expected[i] {
+			t.Fatalf("unexpected value #%d; got %s; want %s", i, s, expected[i])
+		}
+		fv.Release()
+	}
+
+	// All the parsers must be returned to the pool.
+	stats := pp.Stats()
+	if stats.Gets != stats.Puts {
+		t.Fatalf("unexpected number of Put calls; got %d; want %d", stats.Puts, stats.Gets)
+	}
+}
@@ -3,19 +3,41 @@ package fastjson
 var handyPool ParserPool
 
 // Validate validates JSON s.
+//
+// Validate accepts the same input as Parser with the default settings,
+// i.e. Validate(s) returns nil if and only if Parser.Parse(s) succeeds.
+// Use ValidateStrict for checking s is valid JSON according to RFC 8259.
+//
+// Validate doesn't build Value tree and doesn't allocate memory
+// for JSON with reasonable nesting depth.
 func Validate(s string) error {
-	p := handyPool.Get()
-	_, err := p.Parse(s)
-	handyPool.Put(p)
-	return err
+	return validate(s, false)
 }
 
 // ValidateBytes validates JSON b.
+//
+// See Validate for details.
 func ValidateBytes(b []byte) error {
-	p := handyPool.Get()
-	_, err := p.ParseBytes(b)
-	handyPool.Put(p)
-	return err
+	return Validate(b2s(b))
+}
+
+// ValidateStrict validates s is valid JSON according to RFC 8259.
+//
+// Unlike Validate, it rejects input accepted by Parser such as numbers
+// with leading zeros or missing digits, unknown escape sequences
+// and unescaped control chars in strings.
+//
+// ValidateStrict doesn't build Value tree and doesn't allocate memory
+// for JSON with reasonable nesting depth.
+func ValidateStrict(s string) error {
+	return validate(s, true)
+}
+
+// ValidateStrictBytes validates b is valid JSON according to RFC 8259.
+//
+// See ValidateStrict for details.
+func ValidateStrictBytes(b []byte) error {
+	return ValidateStrict(b2s(b))
 }
 
 // GetString returns string value for the field identified by keys path
@@ -2,6 +2,7 @@ package fastjson_test
 
 import (
 	"fmt"
+	"log"
 
 	"github.com/valyala/fastjson"
 )
@@ -46,3 +47,24 @@ func ExampleExists() {
 	// exists(data.foobar) = false
 	// exists(data.foo.bar) = false
 }
+
+func ExampleSetBytes() {
+	data := []byte(`{"imp": [{"id": "1", "bidfloor": 0.5}], "at": 1}`)
+
+	data, err := fastjson.SetBytes(data, []byte(`1.5`), "imp", "0", "bidfloor")
+	if err != nil {
+		log.Fatalf("cannot set bidfloor: %s", err)
+	}
+	data, err = fastjson.SetBytes(data, []byte(`"USD"`), "cur")
+	if err != nil {
+		log.Fatalf("cannot set cur: %s", err)
+	}
+	data, err = fastjson.DeleteBytes(data, "at")
+	if err != nil {
+		log.Fatalf("cannot delete at: %s", err)
+	}
+	fmt.Printf("%s\n", data)
+
+	// Output:
+	// {"imp": [{"id": "1", "bidfloor": 1.5}], "cur": "USD"}
+}
@@ -22,6 +22,9 @@ func benchmarkValidate(b *testing.B, s string) {
 	b.Run("stdjson", func(b *testing.B) {
 		benchmarkValidateStdJSON(b, s)
 	})
+	b.Run("fastjson-parser", func(b *testing.B) {
+		benchmarkValidateFastJSONParser(b, s)
+	})
 	b.Run("fastjson", func(b *testing.B) {
 		benchmarkValidateFastJSON(b, s)
 	})
@@ -52,3 +55,16 @@ func benchmarkValidateFastJSON(b *testing.B, s string) {
 		}
 	})
 }
+
+func benchmarkValidateFastJSONParser(b *testing.B, s string) {
+	b.ReportAllocs()
+	b.SetBytes(int64(len(s)))
+	b.RunParallel(func(pb *testing.PB) {
+		var p Parser
+		for pb.Next() {
+			if _, err := p.Parse(s); err != nil {
+				panic(fmt.Errorf("unexpected error: %s", err))
+			}
+		}
+	})
+}
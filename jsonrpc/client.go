@@ -0,0 +1,308 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/valyala/fastjson"
+	"github.com/valyala/fastjson/fastjsonhttp"
+)
+
+// AppendRequest appends JSON-RPC request for the given method call to dst.
+//
+// params must contain JSON array or object. params may be nil if the method
+// has no params.
+func AppendRequest(dst []byte, method string, params []byte, id uint64) []byte {
+	dst = appendRequestPrefix(dst, method, params)
+	dst = append(dst, `,"id":`...)
+	dst = strconv.AppendUint(dst, id, 10)
+	return append(dst, '}')
+}
+
+// AppendNotification appends JSON-RPC notification for the given method
+// call to dst.
+//
+// params must contain JSON array or object. params may be nil if the method
+// has no params.
+func AppendNotification(dst []byte, method string, params []byte) []byte {
+	dst = appendRequestPrefix(dst, method, params)
+	return append(dst, '}')
+}
+
+func appendRequestPrefix(dst []byte, method string, params []byte) []byte {
+	var jw fastjson.Writer
+	jw.String(method)
+	dst = append(dst, `{"jsonrpc":"2.0","method":`...)
+	dst = append(dst, jw.Bytes()...)
+	if len(params) > 0 {
+		dst = append(dst, `,"params":`...)
+		dst = append(dst, params...)
+	}
+	return dst
+}
+
+// ParseResponse parses JSON-RPC response object v.
+//
+// It returns the response id and the call result. *Error is returned
+// for error responses. id may be non-nil on error.
+func ParseResponse(v *fastjson.Value) (id, result *fastjson.Value, err error) {
+	o, err := v.Object()
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid JSON-RPC response: %s", err)
+	}
+	if version := o.Get("jsonrpc"); version == nil || string(version.GetStringBytes()) != "2.0" {
+		return nil, nil, fmt.Errorf(`invalid JSON-RPC response: missing "jsonrpc":"2.0"`)
+	}
+	id = o.Get("id")
+	if id == nil || !isValidID(id) {
+		return nil, nil, fmt.Errorf("invalid JSON-RPC response: missing or invalid id")
+	}
+	if ev := o.Get("error"); ev != nil {
+		cv := ev.Get("code")
+		if cv == nil {
+			return id, nil, fmt.Errorf("invalid JSON-RPC response: missing error code")
+		}
+		code, err := cv.Int()
+		if err != nil {
+			return id, nil, fmt.Errorf("invalid JSON-RPC response: invalid error code: %s", err)
+		}
+		e := &Error{
+			Code:    code,
+			Message: string(ev.GetStringBytes("message")),
+		}
+		if data := ev.Get("data"); data != nil {
+			e.Data = data.MarshalTo(nil)
+		}
+		return id, nil, e
+	}
+	result = o.Get("result")
+	if result == nil {
+		return id, nil, fmt.Errorf(`invalid JSON-RPC response: missing "result" and "error"`)
+	}
+	return id, result, nil
+}
+
+// Client is JSON-RPC 2.0 client for stream transport with newline-delimited
+// messages such as Server.ServeConn.
+//
+// Use HTTPClient for HTTP transport.
+//
+// Calls are sent sequentially.
+//
+// Client may be used from concurrent goroutines.
+type Client struct {
+	// MaxResponseSize is the maximum size in bytes of a response line.
+	//
+	// fastjsonhttp.DefaultMaxBodySize is used by default.
+	MaxResponseSize int
+
+	mu   sync.Mutex
+	conn io.ReadWriter
+	br   *bufio.Reader
+	p    fastjson.Parser
+	b    []byte
+	id   uint64
+}
+
+// NewClient returns new client for conn.
+func NewClient(conn io.ReadWriter) *Client {
+	return &Client{
+		conn: conn,
+		br:   bufio.NewReader(conn),
+	}
+}
+
+// Call calls the given method with params and passes the result to f.
+//
+// params must contain JSON array or object. params may be nil if the method
+// has no params.
+//
+// *Error is returned for error responses. The error returned from f
+// is returned from Call.
+//
+// f cannot hold result after returning.
+func (c *Client) Call(method string, params []byte, f func(result *fastjson.Value) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	maxLineSize := c.MaxResponseSize
+	if maxLineSize <= 0 {
+		maxLineSize = fastjsonhttp.DefaultMaxBodySize
+	}
+	c.id++
+	id := c.id
+	c.b = AppendRequest(c.b[:0], method, params, id)
+	if err := c.write(); err != nil {
+		return err
+	}
+	for {
+		var err error
+		c.b, err = readLine(c.br, c.b[:0], maxLineSize)
+		if err != nil {
+			return fmt.Errorf("cannot read response for method %q: %s", method, err)
+		}
+		if len(skipWS(c.b)) == 0 {
+			continue
+		}
+		v, err := c.p.ParseBytes(c.b)
+		if err != nil {
+			return fmt.Errorf("cannot parse response for method %q: %s", method, err)
+		}
+		respID, result, err := ParseResponse(v)
+		if respID != nil && respID.Type() == fastjson.TypeNumber {
+			if n, _ := respID.Float64(); n < float64(id) {
+				// Skip the response for the previous call, which has
+				// been interrupted by an error.
+				continue
+			}
+		}
+		if err != nil {
+			return err
+		}
+		return f(result)
+	}
+}
+
+// Notify sends notification for the given method with params.
+//
+// params must contain JSON array or object. params may be nil if the method
+// has no params.
+func (c *Client) Notify(method string, params []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.b = AppendNotification(c.b[:0], method, params)
+	return c.write()
+}
+
+func (c *Client) write() error {
+	c.b = append(c.b, '\n')
+	if _, err := c.conn.Write(c.b); err != nil {
+		return fmt.Errorf("cannot send request: %s", err)
+	}
+	return nil
+}
+
+// HTTPClient is JSON-RPC 2.0 client for HTTP transport such as
+// Server.ServeHTTP.
+//
+// Every call is sent in a separate HTTP POST request.
+//
+// HTTPClient may be used from concurrent goroutines.
+type HTTPClient struct {
+	// URL is the JSON-RPC endpoint URL.
+	URL string
+
+	// Client is used for sending HTTP requests.
+	//
+	// http.DefaultClient is used by default.
+	Client *http.Client
+
+	// MaxResponseSize is the maximum size in bytes of a response body.
+	//
+	// fastjsonhttp.DefaultMaxBodySize is used by default.
+	MaxResponseSize int
+
+	// ParserPool is used for obtaining parsers.
+	//
+	// A package-level pool is used by default.
+	ParserPool *fastjson.ParserPool
+
+	id uint64
+}
+
+// Call calls the given method with params and passes the result to f.
+//
+// params must contain JSON array or object. params may be nil if the method
+// has no params.
+//
+// *Error is returned for error responses. The error returned from f
+// is returned from Call.
+//
+// f cannot hold result after returning.
+func (c *HTTPClient) Call(method string, params []byte, f func(result *fastjson.Value) error) error {
+	id := atomic.AddUint64(&c.id, 1)
+	req := AppendRequest(nil, method, params, id)
+	body, err := c.post(req)
+	if err != nil {
+		return fmt.Errorf("cannot call method %q: %s", method, err)
+	}
+
+	pp := c.ParserPool
+	if pp == nil {
+		pp = &defaultParserPool
+	}
+	p := pp.Get()
+	defer pp.Put(p)
+	v, err := p.ParseBytes(body)
+	if err != nil {
+		return fmt.Errorf("cannot parse response for method %q: %s", method, err)
+	}
+	respID, result, err := ParseResponse(v)
+	if err != nil {
+		return err
+	}
+	if n, err := respID.Float64(); err != nil || n != float64(id) {
+		return fmt.Errorf("unexpected id in response for method %q; got %s; want %d", method, respID, id)
+	}
+	return f(result)
+}
+
+// Notify sends notification for the given method with params.
+//
+// params must contain JSON array or object. params may be nil if the method
+// has no params.
+func (c *HTTPClient) Notify(method string, params []byte) error {
+	req := AppendNotification(nil, method, params)
+	if _, err := c.post(req); err != nil {
+		return fmt.Errorf("cannot send notification for method %q: %s", method, err)
+	}
+	return nil
+}
+
+// post sends req to c.URL and returns the response body.
+func (c *HTTPClient) post(req []byte) ([]byte, error) {
+	hc := c.Client
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Post(c.URL, "application/json", bytes.NewReader(req))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	maxBodySize := c.MaxResponseSize
+	if maxBodySize <= 0 {
+		maxBodySize = fastjsonhttp.DefaultMaxBodySize
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, int64(maxBodySize)+1))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read response body: %s", err)
+	}
+	if len(body) > maxBodySize {
+		return nil, fmt.Errorf("too big response body; it exceeds %d bytes", maxBodySize)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent:
+		return body, nil
+	default:
+		return nil, fmt.Errorf("unexpected status code %d; response body: %q", resp.StatusCode, truncateBody(body))
+	}
+}
+
+// truncateBody truncates response body b for error messages.
+func truncateBody(b []byte) []byte {
+	const maxLen = 256
+	if len(b) > maxLen {
+		return b[:maxLen]
+	}
+	return b
+}
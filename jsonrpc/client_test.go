@@ -0,0 +1,212 @@
+package jsonrpc
+
+import (
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fastjson"
+)
+
+func TestAppendRequest(t *testing.T) {
+	f := func(result []byte, expected string) {
+		t.Helper()
+		if string(result) != expected {
+			t.Fatalf("unexpected request;\ngot\n%s\nwant\n%s", result, expected)
+		}
+		if err := fastjson.ValidateStrictBytes(result); err != nil {
+			t.Fatalf("invalid JSON in request: %s", err)
+		}
+	}
+
+	f(AppendRequest(nil, "sum", []byte(`[1,2]`), 1), `{"jsonrpc":"2.0","method":"sum","params":[1,2],"id":1}`)
+	f(AppendRequest([]byte("x"), "a\"b", nil, 2)[1:], `{"jsonrpc":"2.0","method":"a\"b","id":2}`)
+	f(AppendNotification(nil, "update", []byte(`{"a":1}`)), `{"jsonrpc":"2.0","method":"update","params":{"a":1}}`)
+	f(AppendNotification(nil, "ping", nil), `{"jsonrpc":"2.0","method":"ping"}`)
+}
+
+func TestParseResponse(t *testing.T) {
+	var p fastjson.Parser
+	parse := func(s string) *fastjson.Value {
+		t.Helper()
+		v, err := p.Parse(s)
+		if err != nil {
+			t.Fatalf("cannot parse %s: %s", s, err)
+		}
+		return v
+	}
+
+	id, result, err := ParseResponse(parse(`{"jsonrpc":"2.0","result":{"a":1},"id":"x"}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if string(id.GetStringBytes()) != "x" || result.GetInt("a") != 1 {
+		t.Fatalf("unexpected response; id=%s, result=%s", id, result)
+	}
+
+	_, _, err = ParseResponse(parse(`{"jsonrpc":"2.0","error":{"code":-32000,"message":"foo","data":[1]},"id":1}`))
+	e, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("unexpected error type; got %T; want *Error", err)
+	}
+	if e.Code != -32000 || e.Message != "foo" || string(e.Data) != "[1]" {
+		t.Fatalf("unexpected error: %#v", e)
+	}
+	if e.Error() != "jsonrpc error -32000: foo; data: [1]" {
+		t.Fatalf("unexpected error message: %s", e)
+	}
+
+	// Invalid responses.
+	for _, s := range []string{
+		`[]`,
+		`{"result":1,"id":1}`,
+		`{"jsonrpc":"2.0","result":1}`,
+		`{"jsonrpc":"2.0","result":1,"id":[]}`,
+		`{"jsonrpc":"2.0","id":1}`,
+		`{"jsonrpc":"2.0","error":{"message":"foo"},"id":1}`,
+		`{"jsonrpc":"2.0","error":{"code":"x","message":"foo"},"id":1}`,
+	} {
+		_, _, err := ParseResponse(parse(s))
+		if err == nil {
+			t.Fatalf("expecting non-nil error for %s", s)
+		}
+		if _, ok := err.(*Error); ok {
+			t.Fatalf("unexpected *Error for invalid response %s: %s", s, err)
+		}
+	}
+}
+
+func TestClient(t *testing.T) {
+	s := newTestServer()
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	go func() {
+		s.ServeConn(serverConn)
+		serverConn.Close()
+	}()
+
+	c := NewClient(clientConn)
+	for i := 0; i < 3; i++ {
+		var n float64
+		err := c.Call("subtract", []byte(`{"minuend":10,"subtrahend":3}`), func(result *fastjson.Value) error {
+			n = result.GetFloat64()
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if n != 7 {
+			t.Fatalf("unexpected result; got %v; want %v", n, 7)
+		}
+	}
+
+	if err := c.Notify("update", []byte(`[1]`)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	err := c.Call("missing", nil, func(result *fastjson.Value) error {
+		t.Fatalf("unexpected result: %s", result)
+		return nil
+	})
+	e, ok := err.(*Error)
+	if !ok || e.Code != CodeMethodNotFound {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err = c.Call("get_data", nil, func(result *fastjson.Value) error {
+		if s := result.String(); s != `["hello",5]` {
+			t.Fatalf("unexpected result: %s", s)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestClientMaxResponseSize(t *testing.T) {
+	s := newTestServer()
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	go func() {
+		s.ServeConn(serverConn)
+		serverConn.Close()
+	}()
+
+	c := NewClient(clientConn)
+	c.MaxResponseSize = 40
+	err := c.Call("sum", []byte(`[1,2]`), func(result *fastjson.Value) error {
+		if n := result.GetInt(); n != 3 {
+			t.Fatalf("unexpected result; got %d; want %d", n, 3)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	err = c.Call("get_data", nil, func(result *fastjson.Value) error {
+		t.Fatalf("unexpected result: %s", result)
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "too long line") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestHTTPClient(t *testing.T) {
+	s := newTestServer()
+	s.MaxRequestSize = 1024
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	c := &HTTPClient{
+		URL: srv.URL,
+	}
+	for i := 0; i < 3; i++ {
+		var n float64
+		err := c.Call("subtract", []byte(`{"minuend":10,"subtrahend":3}`), func(result *fastjson.Value) error {
+			n = result.GetFloat64()
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if n != 7 {
+			t.Fatalf("unexpected result; got %v; want %v", n, 7)
+		}
+	}
+
+	if err := c.Notify("update", []byte(`[1]`)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	err := c.Call("missing", nil, func(result *fastjson.Value) error {
+		t.Fatalf("unexpected result: %s", result)
+		return nil
+	})
+	e, ok := err.(*Error)
+	if !ok || e.Code != CodeMethodNotFound {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Too big request.
+	err = c.Call("sum", []byte(`[`+strings.Repeat(`1,`, 1024)+`1]`), func(result *fastjson.Value) error {
+		t.Fatalf("unexpected result: %s", result)
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "unexpected status code 413") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Too big response.
+	c.MaxResponseSize = 40
+	err = c.Call("get_data", nil, func(result *fastjson.Value) error {
+		t.Fatalf("unexpected result: %s", result)
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "too big response body") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
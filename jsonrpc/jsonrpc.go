@@ -0,0 +1,118 @@
+// Package jsonrpc implements JSON-RPC 2.0 ( https://www.jsonrpc.org/specification )
+// server and client on top of fastjson.
+//
+// Server supports HTTP transport and stream transport with newline-delimited
+// messages over net.Conn. Use HTTPClient for HTTP transport and Client
+// for stream transport on the client side.
+package jsonrpc
+
+import (
+	"fmt"
+
+	"github.com/valyala/fastjson"
+)
+
+// Error codes defined by JSON-RPC 2.0.
+//
+// Codes from -32000 to -32099 are reserved for implementation-defined
+// server errors.
+const (
+	// CodeParseError means invalid JSON.
+	CodeParseError = -32700
+
+	// CodeInvalidRequest means that the JSON isn't a valid request object.
+	CodeInvalidRequest = -32600
+
+	// CodeMethodNotFound means that the method doesn't exist.
+	CodeMethodNotFound = -32601
+
+	// CodeInvalidParams means invalid method params.
+	CodeInvalidParams = -32602
+
+	// CodeInternalError means internal server error.
+	CodeInternalError = -32603
+)
+
+// Error is JSON-RPC error object.
+//
+// Handlers may return Error for sending the error with the given code
+// to the client. Client returns Error for error responses.
+type Error struct {
+	// Code is the error code. See Code* constants.
+	Code int
+
+	// Message is a short description of the error.
+	Message string
+
+	// Data is optional raw JSON with additional information about the error.
+	Data []byte
+}
+
+// Error implements error interface.
+func (e *Error) Error() string {
+	if len(e.Data) == 0 {
+		return fmt.Sprintf("jsonrpc error %d: %s", e.Code, e.Message)
+	}
+	return fmt.Sprintf("jsonrpc error %d: %s; data: %s", e.Code, e.Message, e.Data)
+}
+
+func newError(code int, message string) *Error {
+	return &Error{
+		Code:    code,
+		Message: message,
+	}
+}
+
+var (
+	errParse          = newError(CodeParseError, "Parse error")
+	errInvalidRequest = newError(CodeInvalidRequest, "Invalid Request")
+	errMethodNotFound = newError(CodeMethodNotFound, "Method not found")
+	errInternal       = newError(CodeInternalError, "Internal error")
+)
+
+// writeError writes error object for e to jw.
+func writeError(jw *fastjson.Writer, e *Error) {
+	jw.BeginObject()
+	jw.Key("code")
+	jw.Int64(int64(e.Code))
+	jw.Key("message")
+	jw.String(e.Message)
+	if len(e.Data) > 0 {
+		jw.Key("data")
+		jw.Raw(e.Data)
+	}
+	jw.EndObject()
+}
+
+// writeResponse writes response object with the given id to jw.
+//
+// The response contains e if it isn't nil. Otherwise it contains raw result.
+func writeResponse(jw *fastjson.Writer, id *fastjson.Value, result []byte, e *Error) {
+	jw.BeginObject()
+	jw.Key("jsonrpc")
+	jw.String("2.0")
+	if e != nil {
+		jw.Key("error")
+		writeError(jw, e)
+	} else {
+		jw.Key("result")
+		jw.Raw(result)
+	}
+	jw.Key("id")
+	if id == nil {
+		jw.Null()
+	} else {
+		jw.Value(id)
+	}
+	jw.EndObject()
+}
+
+// isValidID returns true if id has valid type for request id.
+func isValidID(id *fastjson.Value) bool {
+	switch id.Type() {
+	case fastjson.TypeString, fastjson.TypeNumber, fastjson.TypeNull:
+		return true
+	default:
+		return false
+	}
+}
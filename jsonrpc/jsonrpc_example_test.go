@@ -0,0 +1,45 @@
+package jsonrpc_test
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/valyala/fastjson"
+	"github.com/valyala/fastjson/jsonrpc"
+)
+
+func ExampleServer() {
+	var s jsonrpc.Server
+	s.Register("sum", func(params *fastjson.Value, w *fastjson.Writer) error {
+		a, err := params.Array()
+		if err != nil {
+			return &jsonrpc.Error{
+				Code:    jsonrpc.CodeInvalidParams,
+				Message: "params must be an array of numbers",
+			}
+		}
+		var sum float64
+		for _, v := range a {
+			sum += v.GetFloat64()
+		}
+		w.Float64(sum)
+		return nil
+	})
+
+	for _, body := range []string{
+		`{"jsonrpc":"2.0","method":"sum","params":[1,2,3],"id":1}`,
+		`{"jsonrpc":"2.0","method":"sum","params":{"a":1},"id":2}`,
+		`[{"jsonrpc":"2.0","method":"sum","params":[4,5],"id":3},{"jsonrpc":"2.0","method":"foo","id":4}]`,
+	} {
+		r := httptest.NewRequest("POST", "/rpc", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		fmt.Println(w.Body)
+	}
+
+	// Output:
+	// {"jsonrpc":"2.0","result":6,"id":1}
+	// {"jsonrpc":"2.0","error":{"code":-32602,"message":"params must be an array of numbers"},"id":2}
+	// [{"jsonrpc":"2.0","result":9,"id":3},{"jsonrpc":"2.0","error":{"code":-32601,"message":"Method not found"},"id":4}]
+}
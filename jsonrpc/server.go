@@ -0,0 +1,363 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"sync"
+
+	"github.com/valyala/fastjson"
+	"github.com/valyala/fastjson/fastjsonhttp"
+)
+
+// HandlerFunc handles JSON-RPC method call.
+//
+// params contains the request params. It is nil if params are missing.
+// params cannot be held after returning.
+//
+// The handler must write exactly one JSON value with the call result to w.
+// Return *Error for sending error object with the given code to the client.
+// Other errors are logged with the standard logger, while internal error
+// without error details is sent to the client.
+//
+// Panics in the handler are recovered and logged with the standard logger.
+// Internal error without panic details is sent to the client in this case.
+type HandlerFunc func(params *fastjson.Value, w *fastjson.Writer) error
+
+// Server is JSON-RPC 2.0 server.
+//
+// Register methods with Register before serving requests.
+//
+// Server may be used from concurrent goroutines.
+type Server struct {
+	// MaxRequestSize is the maximum size in bytes of HTTP request body
+	// or a line in stream transport.
+	//
+	// fastjsonhttp.DefaultMaxBodySize is used by default.
+	MaxRequestSize int
+
+	// ParserPool is used for obtaining parsers.
+	//
+	// A package-level pool is used by default.
+	ParserPool *fastjson.ParserPool
+
+	mu      sync.RWMutex
+	methods map[string]HandlerFunc
+
+	writerPool sync.Pool
+}
+
+var defaultParserPool fastjson.ParserPool
+
+// Register registers h for the given method.
+//
+// It panics if the method is already registered.
+func (s *Server) Register(method string, h HandlerFunc) {
+	if h == nil {
+		panic(fmt.Errorf("BUG: nil handler for method %q", method))
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.methods == nil {
+		s.methods = make(map[string]HandlerFunc)
+	}
+	if _, ok := s.methods[method]; ok {
+		panic(fmt.Errorf("BUG: method %q is already registered", method))
+	}
+	s.methods[method] = h
+}
+
+// AppendResponse handles JSON-RPC request or batch req and appends
+// the response to dst.
+//
+// Nothing is appended if req contains only notifications.
+//
+// AppendResponse may be used for implementing custom transports.
+func (s *Server) AppendResponse(dst []byte, req *fastjson.Value) []byte {
+	jw := s.getWriter()
+	defer s.putWriter(jw)
+	if !s.handle(jw, req) {
+		return dst
+	}
+	return append(dst, jw.Bytes()...)
+}
+
+// ServeHTTP serves JSON-RPC requests sent via HTTP POST.
+//
+// 204 No Content is returned for notifications.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "JSON-RPC requests must be sent via POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	bp := fastjsonhttp.BodyParser{
+		MaxBodySize: s.MaxRequestSize,
+		ParserPool:  s.ParserPool,
+	}
+	v, release, err := bp.Parse(r)
+	if err != nil {
+		if e, ok := err.(*fastjsonhttp.Error); !ok || e.StatusCode != http.StatusBadRequest {
+			fastjsonhttp.WriteError(w, err)
+			return
+		}
+	}
+
+	jw := s.getWriter()
+	defer s.putWriter(jw)
+	if err != nil {
+		writeResponse(jw, nil, nil, errParse)
+	} else {
+		hasResponse := s.handle(jw, v)
+		release()
+		if !hasResponse {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(jw.Bytes())
+}
+
+// ServeConn serves JSON-RPC requests read from conn.
+//
+// Requests and responses are delimited by '\n'. Requests are processed
+// sequentially in the order they are read.
+//
+// nil is returned when conn is closed by the client.
+func (s *Server) ServeConn(conn io.ReadWriter) error {
+	maxLineSize := s.MaxRequestSize
+	if maxLineSize <= 0 {
+		maxLineSize = fastjsonhttp.DefaultMaxBodySize
+	}
+	pp := s.ParserPool
+	if pp == nil {
+		pp = &defaultParserPool
+	}
+	p := pp.Get()
+	defer pp.Put(p)
+	jw := s.getWriter()
+	defer s.putWriter(jw)
+
+	br := bufio.NewReader(conn)
+	bw := bufio.NewWriter(conn)
+	var line []byte
+	for {
+		var err error
+		line, err = readLine(br, line[:0], maxLineSize)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(skipWS(line)) == 0 {
+			continue
+		}
+
+		jw.Reset(nil)
+		v, err := p.ParseBytes(line)
+		if err != nil {
+			writeResponse(jw, nil, nil, errParse)
+		} else if !s.handle(jw, v) {
+			continue
+		}
+		bw.Write(jw.Bytes())
+		bw.WriteByte('\n')
+		if err := bw.Flush(); err != nil {
+			return fmt.Errorf("cannot write response: %s", err)
+		}
+	}
+}
+
+// Serve accepts connections from ln and serves them with ServeConn.
+//
+// Connections are closed after serving.
+//
+// Serve returns the error returned from ln.Accept.
+func (s *Server) Serve(ln net.Listener) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			s.ServeConn(conn)
+			conn.Close()
+		}()
+	}
+}
+
+// handle handles JSON-RPC request or batch req and writes the response to jw.
+//
+// It returns false if req contains only notifications, so no response
+// is written.
+func (s *Server) handle(jw *fastjson.Writer, req *fastjson.Value) bool {
+	if req.Type() != fastjson.TypeArray {
+		return s.handleRequest(jw, req)
+	}
+
+	// Batch request.
+	a, _ := req.Array()
+	if len(a) == 0 {
+		writeResponse(jw, nil, nil, errInvalidRequest)
+		return true
+	}
+	hasResponses := false
+	for _, v := range a {
+		if !isNotification(v) {
+			hasResponses = true
+			break
+		}
+	}
+	if !hasResponses {
+		for _, v := range a {
+			s.handleRequest(jw, v)
+		}
+		return false
+	}
+	jw.BeginArray()
+	for _, v := range a {
+		s.handleRequest(jw, v)
+	}
+	jw.EndArray()
+	return true
+}
+
+// handleRequest handles request object v and writes the response to jw.
+//
+// It returns false if v is notification, so no response is written.
+func (s *Server) handleRequest(jw *fastjson.Writer, v *fastjson.Value) bool {
+	method, params, id, e := parseRequest(v)
+	if e != nil {
+		writeResponse(jw, id, nil, e)
+		return true
+	}
+
+	s.mu.RLock()
+	h := s.methods[string(method)]
+	s.mu.RUnlock()
+	if h == nil {
+		if id == nil {
+			return false
+		}
+		writeResponse(jw, id, nil, errMethodNotFound)
+		return true
+	}
+
+	rw := s.getWriter()
+	defer s.putWriter(rw)
+	err := callHandler(h, method, params, rw)
+	if err == nil {
+		err = rw.Close()
+		if err != nil {
+			err = fmt.Errorf("cannot write result for method %q: %s", method, err)
+		}
+	}
+	e, ok := err.(*Error)
+	if err != nil && !ok {
+		// Do not expose internal error details to the client.
+		log.Printf("jsonrpc: error in method %q: %s", method, err)
+		e = errInternal
+	}
+	if id == nil {
+		return false
+	}
+	if e != nil {
+		writeResponse(jw, id, nil, e)
+		return true
+	}
+	writeResponse(jw, id, rw.Bytes(), nil)
+	return true
+}
+
+// callHandler calls h and converts its panic to errInternal.
+func callHandler(h HandlerFunc, method []byte, params *fastjson.Value, w *fastjson.Writer) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("jsonrpc: panic in method %q: %v\n%s", method, r, debug.Stack())
+			err = errInternal
+		}
+	}()
+	return h(params, w)
+}
+
+// parseRequest parses request object v.
+//
+// id is nil for notifications. id may be non-nil on error
+// if it is valid.
+func parseRequest(v *fastjson.Value) (method []byte, params, id *fastjson.Value, e *Error) {
+	o, err := v.Object()
+	if err != nil {
+		return nil, nil, nil, errInvalidRequest
+	}
+	id = o.Get("id")
+	if id != nil && !isValidID(id) {
+		return nil, nil, nil, errInvalidRequest
+	}
+	if version := o.Get("jsonrpc"); version == nil || string(version.GetStringBytes()) != "2.0" {
+		return nil, nil, id, errInvalidRequest
+	}
+	m := o.Get("method")
+	if m == nil || m.Type() != fastjson.TypeString {
+		return nil, nil, id, errInvalidRequest
+	}
+	params = o.Get("params")
+	if params != nil && params.Type() != fastjson.TypeArray && params.Type() != fastjson.TypeObject {
+		return nil, nil, id, errInvalidRequest
+	}
+	return m.GetStringBytes(), params, id, nil
+}
+
+// isNotification returns true if v is a valid notification.
+func isNotification(v *fastjson.Value) bool {
+	_, _, id, e := parseRequest(v)
+	return e == nil && id == nil
+}
+
+func (s *Server) getWriter() *fastjson.Writer {
+	v := s.writerPool.Get()
+	if v == nil {
+		return &fastjson.Writer{}
+	}
+	return v.(*fastjson.Writer)
+}
+
+func (s *Server) putWriter(jw *fastjson.Writer) {
+	jw.Reset(nil)
+	s.writerPool.Put(jw)
+}
+
+// readLine appends the next line from br without the trailing '\n' to dst.
+func readLine(br *bufio.Reader, dst []byte, maxLineSize int) ([]byte, error) {
+	for {
+		b, err := br.ReadSlice('\n')
+		dst = append(dst, b...)
+		if len(dst) > maxLineSize+1 {
+			return dst, fmt.Errorf("too long line; it exceeds %d bytes", maxLineSize)
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == io.EOF && len(dst) > 0 {
+			// The last line without the trailing '\n'.
+			return dst, nil
+		}
+		if err != nil {
+			return dst, err
+		}
+		return dst[:len(dst)-1], nil
+	}
+}
+
+func skipWS(b []byte) []byte {
+	for len(b) > 0 && (b[0] == ' ' || b[0] == '\t' || b[0] == '\r' || b[0] == '\n') {
+		b = b[1:]
+	}
+	return b
+}
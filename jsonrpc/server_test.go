@@ -0,0 +1,259 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fastjson"
+)
+
+func newTestServer() *Server {
+	var s Server
+	s.Register("subtract", func(params *fastjson.Value, w *fastjson.Writer) error {
+		var a, b float64
+		switch params.Type() {
+		case fastjson.TypeArray:
+			a = params.GetFloat64("0")
+			b = params.GetFloat64("1")
+		case fastjson.TypeObject:
+			a = params.GetFloat64("minuend")
+			b = params.GetFloat64("subtrahend")
+		}
+		w.Float64(a - b)
+		return nil
+	})
+	s.Register("sum", func(params *fastjson.Value, w *fastjson.Writer) error {
+		var sum float64
+		for _, v := range params.GetArray() {
+			sum += v.GetFloat64()
+		}
+		w.Float64(sum)
+		return nil
+	})
+	s.Register("get_data", func(params *fastjson.Value, w *fastjson.Writer) error {
+		w.BeginArray()
+		w.String("hello")
+		w.Int64(5)
+		w.EndArray()
+		return nil
+	})
+	s.Register("notify_hello", func(params *fastjson.Value, w *fastjson.Writer) error {
+		w.Null()
+		return nil
+	})
+	s.Register("update", func(params *fastjson.Value, w *fastjson.Writer) error {
+		w.Null()
+		return nil
+	})
+	s.Register("fail", func(params *fastjson.Value, w *fastjson.Writer) error {
+		if params == nil {
+			return &Error{
+				Code:    CodeInvalidParams,
+				Message: "Invalid params",
+				Data:    []byte(`{"missing":"params"}`),
+			}
+		}
+		return errors.New("unexpected error")
+	})
+	s.Register("no_result", func(params *fastjson.Value, w *fastjson.Writer) error {
+		return nil
+	})
+	return &s
+}
+
+func TestServerAppendResponse(t *testing.T) {
+	var logBuf bytes.Buffer
+	log.SetOutput(&logBuf)
+	defer log.SetOutput(os.Stderr)
+
+	s := newTestServer()
+	f := func(req, expected string) {
+		t.Helper()
+		var p fastjson.Parser
+		v, err := p.Parse(req)
+		if err != nil {
+			t.Fatalf("cannot parse %s: %s", req, err)
+		}
+		resp := string(s.AppendResponse(nil, v))
+		if resp != expected {
+			t.Fatalf("unexpected response for %s;\ngot\n%s\nwant\n%s", req, resp, expected)
+		}
+	}
+
+	// Examples from the specification.
+	f(`{"jsonrpc": "2.0", "method": "subtract", "params": [42, 23], "id": 1}`, `{"jsonrpc":"2.0","result":19,"id":1}`)
+	f(`{"jsonrpc": "2.0", "method": "subtract", "params": [23, 42], "id": 2}`, `{"jsonrpc":"2.0","result":-19,"id":2}`)
+	f(`{"jsonrpc": "2.0", "method": "subtract", "params": {"subtrahend": 23, "minuend": 42}, "id": 3}`, `{"jsonrpc":"2.0","result":19,"id":3}`)
+	f(`{"jsonrpc": "2.0", "method": "update", "params": [1,2,3,4,5]}`, ``)
+	f(`{"jsonrpc": "2.0", "method": "foobar"}`, ``)
+	f(`{"jsonrpc": "2.0", "method": "foobar", "id": "1"}`, `{"jsonrpc":"2.0","error":{"code":-32601,"message":"Method not found"},"id":"1"}`)
+	f(`{"jsonrpc": "2.0", "method": 1, "params": "bar"}`, `{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}`)
+	f(`[]`, `{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}`)
+	f(`[1]`, `[{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}]`)
+	f(`[1,2]`, `[{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null},{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}]`)
+	f(`[
+		{"jsonrpc": "2.0", "method": "sum", "params": [1,2,4], "id": "1"},
+		{"jsonrpc": "2.0", "method": "notify_hello", "params": [7]},
+		{"jsonrpc": "2.0", "method": "subtract", "params": [42,23], "id": "2"},
+		{"foo": "boo"},
+		{"jsonrpc": "2.0", "method": "foo.get", "params": {"name": "myself"}, "id": "5"},
+		{"jsonrpc": "2.0", "method": "get_data", "id": "9"}
+	]`, `[{"jsonrpc":"2.0","result":7,"id":"1"},{"jsonrpc":"2.0","result":19,"id":"2"},{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null},{"jsonrpc":"2.0","error":{"code":-32601,"message":"Method not found"},"id":"5"},{"jsonrpc":"2.0","result":["hello",5],"id":"9"}]`)
+	f(`[
+		{"jsonrpc": "2.0", "method": "notify_sum", "params": [1,2,4]},
+		{"jsonrpc": "2.0", "method": "notify_hello", "params": [7]}
+	]`, ``)
+
+	// Invalid requests.
+	f(`{"method": "sum", "params": [1], "id": 1}`, `{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":1}`)
+	f(`{"jsonrpc": "1.0", "method": "sum", "id": 1}`, `{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":1}`)
+	f(`{"jsonrpc": "2.0", "method": "sum", "params": 1, "id": 1}`, `{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":1}`)
+	f(`{"jsonrpc": "2.0", "method": "sum", "id": {}}`, `{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}`)
+	f(`"foo"`, `{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}`)
+
+	// Null id isn't a notification.
+	f(`{"jsonrpc": "2.0", "method": "get_data", "id": null}`, `{"jsonrpc":"2.0","result":["hello",5],"id":null}`)
+
+	// Handler errors.
+	f(`{"jsonrpc": "2.0", "method": "fail", "id": 1}`, `{"jsonrpc":"2.0","error":{"code":-32602,"message":"Invalid params","data":{"missing":"params"}},"id":1}`)
+	f(`{"jsonrpc": "2.0", "method": "fail", "params": [], "id": 1}`, `{"jsonrpc":"2.0","error":{"code":-32603,"message":"Internal error"},"id":1}`)
+	f(`{"jsonrpc": "2.0", "method": "fail"}`, ``)
+	f(`{"jsonrpc": "2.0", "method": "no_result", "id": 1}`, `{"jsonrpc":"2.0","error":{"code":-32603,"message":"Internal error"},"id":1}`)
+
+	// Internal error details are logged instead of sending them to the client.
+	logs := logBuf.String()
+	for _, msg := range []string{
+		`error in method "fail": unexpected error`,
+		`error in method "no_result": cannot write result for method "no_result": cannot write JSON: missing JSON value`,
+	} {
+		if !strings.Contains(logs, msg) {
+			t.Fatalf("missing %q in the log:\n%s", msg, logs)
+		}
+	}
+}
+
+func TestServerHandlerPanic(t *testing.T) {
+	var logBuf bytes.Buffer
+	log.SetOutput(&logBuf)
+	defer log.SetOutput(os.Stderr)
+
+	var s Server
+	s.Register("panic", func(params *fastjson.Value, w *fastjson.Writer) error {
+		w.BeginArray()
+		panic("secret details")
+	})
+	s.Register("sum", newTestServer().methods["sum"])
+
+	var p fastjson.Parser
+	v, err := p.Parse(`[{"jsonrpc":"2.0","method":"panic","id":1},{"jsonrpc":"2.0","method":"sum","params":[1,2],"id":2},{"jsonrpc":"2.0","method":"panic"}]`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	resp := string(s.AppendResponse(nil, v))
+	expected := `[{"jsonrpc":"2.0","error":{"code":-32603,"message":"Internal error"},"id":1},{"jsonrpc":"2.0","result":3,"id":2}]`
+	if resp != expected {
+		t.Fatalf("unexpected response;\ngot\n%s\nwant\n%s", resp, expected)
+	}
+	if n := strings.Count(logBuf.String(), `panic in method "panic": secret details`); n != 2 {
+		t.Fatalf("unexpected number of logged panics; got %d; want 2; log:\n%s", n, logBuf.String())
+	}
+}
+
+func TestServerRegister(t *testing.T) {
+	var s Server
+	h := func(params *fastjson.Value, w *fastjson.Writer) error {
+		return nil
+	}
+	s.Register("foo", h)
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatalf("expecting panic on duplicate method registration")
+		}
+	}()
+	s.Register("foo", h)
+}
+
+func TestServerServeHTTP(t *testing.T) {
+	s := newTestServer()
+	s.MaxRequestSize = 1024
+	f := func(method, body string, expectedStatusCode int, expectedBody string) {
+		t.Helper()
+		r := httptest.NewRequest(method, "/rpc", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		if w.Code != expectedStatusCode {
+			t.Fatalf("unexpected status code for %s; got %d; want %d", body, w.Code, expectedStatusCode)
+		}
+		if expectedBody != "" && w.Body.String() != expectedBody {
+			t.Fatalf("unexpected response for %s;\ngot\n%s\nwant\n%s", body, w.Body.String(), expectedBody)
+		}
+	}
+
+	f("POST", `{"jsonrpc":"2.0","method":"sum","params":[1,2],"id":1}`, http.StatusOK, `{"jsonrpc":"2.0","result":3,"id":1}`)
+	f("POST", `{"jsonrpc":"2.0","method":"sum","params":[1,2]}`, http.StatusNoContent, ``)
+	f("POST", `{"jsonrpc": "2.0", "method": "foobar, "params": "bar", "baz]`, http.StatusOK, `{"jsonrpc":"2.0","error":{"code":-32700,"message":"Parse error"},"id":null}`)
+	f("POST", `[{"jsonrpc": "2.0", "method": "sum", "params": [1,2,4], "id": "1"},{"jsonrpc": "2.0", "method"]`, http.StatusOK, `{"jsonrpc":"2.0","error":{"code":-32700,"message":"Parse error"},"id":null}`)
+	f("POST", `[`+strings.Repeat(`1,`, 1024)+`1]`, http.StatusRequestEntityTooLarge, ``)
+	f("GET", ``, http.StatusMethodNotAllowed, ``)
+}
+
+func TestServerServeConn(t *testing.T) {
+	s := newTestServer()
+	s.MaxRequestSize = 1024
+	clientConn, serverConn := net.Pipe()
+	ch := make(chan error, 1)
+	go func() {
+		ch <- s.ServeConn(serverConn)
+		serverConn.Close()
+	}()
+
+	br := bufio.NewReader(clientConn)
+	f := func(req, expected string) {
+		t.Helper()
+		if _, err := clientConn.Write([]byte(req)); err != nil {
+			t.Fatalf("cannot write request: %s", err)
+		}
+		resp, err := br.ReadString('\n')
+		if err != nil {
+			t.Fatalf("cannot read response: %s", err)
+		}
+		if resp != expected {
+			t.Fatalf("unexpected response for %q;\ngot\n%q\nwant\n%q", req, resp, expected)
+		}
+	}
+
+	f("{\"jsonrpc\":\"2.0\",\"method\":\"sum\",\"params\":[1,2],\"id\":1}\n", "{\"jsonrpc\":\"2.0\",\"result\":3,\"id\":1}\n")
+
+	// Notifications and empty lines don't produce responses.
+	f("\n{\"jsonrpc\":\"2.0\",\"method\":\"sum\",\"params\":[1]}\n  \r\n[{\"jsonrpc\":\"2.0\",\"method\":\"sum\",\"id\":2}]\n", "[{\"jsonrpc\":\"2.0\",\"result\":0,\"id\":2}]\n")
+	f("{bad json\n", "{\"jsonrpc\":\"2.0\",\"error\":{\"code\":-32700,\"message\":\"Parse error\"},\"id\":null}\n")
+
+	clientConn.Close()
+	if err := <-ch; err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestServerServeConnTooLongLine(t *testing.T) {
+	s := newTestServer()
+	s.MaxRequestSize = 16
+	clientConn, serverConn := net.Pipe()
+	ch := make(chan error, 1)
+	go func() {
+		ch <- s.ServeConn(serverConn)
+		serverConn.Close()
+	}()
+	go clientConn.Write([]byte(`[` + strings.Repeat(`1,`, 100) + "1]\n"))
+	if err := <-ch; err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+	clientConn.Close()
+}
@@ -0,0 +1,138 @@
+package fastjson
+
+import (
+	"fmt"
+)
+
+// Limits limits resources used for parsing untrusted JSON.
+//
+// Parsing fails fast with *ParseError containing *LimitError
+// in ParseError.Err when a limit is exceeded.
+//
+// Zero fields mean no limit.
+type Limits struct {
+	// MaxInputSize is the maximum size of the input in bytes.
+	//
+	// Parser applies the limit to the input passed to Parse, while Scanner
+	// applies it to the input passed to Init.
+	MaxInputSize int
+
+	// MaxValues is the maximum number of values in a single parsed JSON
+	// including nested values. It limits memory usage, which is
+	// proportional to the number of values.
+	MaxValues int
+
+	// MaxStringLength is the maximum length in bytes of strings
+	// and object keys before unescaping.
+	MaxStringLength int
+
+	// MaxElements is the maximum number of items in a single array
+	// or object.
+	MaxElements int
+}
+
+// LimitCode identifies the exceeded limit.
+type LimitCode int
+
+const (
+	// LimitInputSize is Limits.MaxInputSize.
+	LimitInputSize LimitCode = iota + 1
+
+	// LimitValues is Limits.MaxValues.
+	LimitValues
+
+	// LimitStringLength is Limits.MaxStringLength.
+	LimitStringLength
+
+	// LimitElements is Limits.MaxElements.
+	LimitElements
+)
+
+// String returns string representation of lc.
+func (lc LimitCode) String() string {
+	switch lc {
+	case LimitInputSize:
+		return "MaxInputSize"
+	case LimitValues:
+		return "MaxValues"
+	case LimitStringLength:
+		return "MaxStringLength"
+	case LimitElements:
+		return "MaxElements"
+	default:
+		panic(fmt.Errorf("BUG: unknown LimitCode: %d", lc))
+	}
+}
+
+// LimitError is ParseError.Err when a limit from Limits is exceeded.
+type LimitError struct {
+	// Code is the exceeded limit.
+	Code LimitCode
+
+	// Limit is the value of the exceeded limit.
+	Limit int
+}
+
+// Error implements error interface.
+func (e *LimitError) Error() string {
+	switch e.Code {
+	case LimitInputSize:
+		return fmt.Sprintf("too big input; it exceeds %d bytes", e.Limit)
+	case LimitValues:
+		return fmt.Sprintf("too many values; the number of values exceeds %d", e.Limit)
+	case LimitStringLength:
+		return fmt.Sprintf("too long string; it exceeds %d bytes", e.Limit)
+	case LimitElements:
+		return fmt.Sprintf("too many items in array or object; the number of items exceeds %d", e.Limit)
+	default:
+		panic(fmt.Errorf("BUG: unknown LimitCode: %d", e.Code))
+	}
+}
+
+// newInputSizeError returns ParseError for the input exceeding
+// Limits.MaxInputSize.
+func newInputSizeError(maxInputSize int) *ParseError {
+	err := &LimitError{
+		Code:  LimitInputSize,
+		Limit: maxInputSize,
+	}
+	return &ParseError{
+		Offset: maxInputSize,
+		Err:    err,
+		msg:    fmt.Sprintf("cannot parse JSON: %s", err),
+	}
+}
+
+// limitError remembers and returns LimitError for the given code.
+func (c *cache) limitError(code LimitCode, limit int) error {
+	c.limitErr = &LimitError{
+		Code:  code,
+		Limit: limit,
+	}
+	return c.limitErr
+}
+
+// checkValue must be called before parsing each value.
+func (c *cache) checkValue() error {
+	c.values++
+	if c.values > c.limits.MaxValues {
+		return c.limitError(LimitValues, c.limits.MaxValues)
+	}
+	return nil
+}
+
+// checkString verifies the length of string or object key s.
+func (c *cache) checkString(s string) error {
+	if len(s) > c.limits.MaxStringLength {
+		return c.limitError(LimitStringLength, c.limits.MaxStringLength)
+	}
+	return nil
+}
+
+// checkElements verifies the number n of items in array or object.
+func (c *cache) checkElements(n int) error {
+	if n > c.limits.MaxElements {
+		return c.limitError(LimitElements, c.limits.MaxElements)
+	}
+	return nil
+}
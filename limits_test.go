@@ -0,0 +1,182 @@
+package fastjson
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParserLimits(t *testing.T) {
+	f := func(limits Limits, s string, expectedCode LimitCode) {
+		t.Helper()
+		for _, relaxed := range []bool{false, true} {
+			p := Parser{
+				Limits:  limits,
+				Relaxed: relaxed,
+			}
+			_, err := p.Parse(s)
+			if err == nil {
+				t.Fatalf("expecting non-nil error for %q", s)
+			}
+			pe, ok := err.(*ParseError)
+			if !ok {
+				t.Fatalf("unexpected error type for %q; got %T; want *ParseError", s, err)
+			}
+			le, ok := pe.Err.(*LimitError)
+			if !ok {
+				t.Fatalf("unexpected ParseError.Err type for %q; got %T; want *LimitError; error: %s", s, pe.Err, err)
+			}
+			if le.Code != expectedCode {
+				t.Fatalf("unexpected limit code for %q; got %s; want %s", s, le.Code, expectedCode)
+			}
+			if !strings.Contains(err.Error(), le.Error()) {
+				t.Fatalf("error message %q must contain %q", err, le)
+			}
+
+			// The same input must be parsed without limits.
+			p.Limits = Limits{}
+			if _, err := p.Parse(s); err != nil {
+				t.Fatalf("unexpected error for %q without limits: %s", s, err)
+			}
+		}
+	}
+
+	f(Limits{MaxInputSize: 3}, `1234`, LimitInputSize)
+	f(Limits{MaxInputSize: 3}, `[1] `, LimitInputSize)
+
+	f(Limits{MaxValues: 1}, `[1]`, LimitValues)
+	f(Limits{MaxValues: 3}, `[1,[2]]`, LimitValues)
+	f(Limits{MaxValues: 2}, `{"a":{"b":null}}`, LimitValues)
+	f(Limits{MaxValues: 10}, `[`+strings.Repeat(`true,`, 10)+`true]`, LimitValues)
+
+	f(Limits{MaxStringLength: 3}, `"abcd"`, LimitStringLength)
+	f(Limits{MaxStringLength: 3}, `["abc","abcd"]`, LimitStringLength)
+	f(Limits{MaxStringLength: 3}, `{"abcd":1}`, LimitStringLength)
+	f(Limits{MaxStringLength: 3}, `{"a":{"b":"abcd"}}`, LimitStringLength)
+
+	f(Limits{MaxElements: 2}, `[1,2,3]`, LimitElements)
+	f(Limits{MaxElements: 2}, `[[1,2,3]]`, LimitElements)
+	f(Limits{MaxElements: 2}, `{"a":1,"b":2,"c":3}`, LimitElements)
+	f(Limits{MaxElements: 2}, `{"a":{"a":1,"b":2,"c":3}}`, LimitElements)
+}
+
+func TestParserLimitsSuccess(t *testing.T) {
+	f := func(limits Limits, s string) {
+		t.Helper()
+		p := Parser{
+			Limits: limits,
+		}
+		for i := 0; i < 2; i++ {
+			v, err := p.Parse(s)
+			if err != nil {
+				t.Fatalf("unexpected error for %q: %s", s, err)
+			}
+			if v.String() != s {
+				t.Fatalf("unexpected value; got %s; want %s", v, s)
+			}
+		}
+	}
+
+	f(Limits{MaxInputSize: 3}, `123`)
+	f(Limits{MaxValues: 1}, `1`)
+	f(Limits{MaxValues: 3}, `[1,2]`)
+	f(Limits{MaxValues: 3}, `{"a":{"b":null}}`)
+	f(Limits{MaxStringLength: 3}, `{"abc":"abc"}`)
+	f(Limits{MaxElements: 2}, `[[1,2],{"a":1,"b":[]}]`)
+	f(Limits{MaxInputSize: 100, MaxValues: 100, MaxStringLength: 100, MaxElements: 100}, `{"a":[1,2,"foo"]}`)
+}
+
+func TestParserLimitsNonLimitError(t *testing.T) {
+	p := Parser{
+		Limits: Limits{MaxElements: 10},
+	}
+	_, err := p.Parse(`[1,2,]`)
+	if err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+	if _, ok := err.(*ParseError).Err.(*LimitError); ok {
+		t.Fatalf("unexpected *LimitError: %s", err)
+	}
+
+	// The limit error from the previous Parse call mustn't leak.
+	p.Limits = Limits{MaxElements: 1}
+	if _, err := p.Parse(`[1,2]`); err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+	p.Limits = Limits{}
+	_, err = p.Parse(`[1,2,]`)
+	if _, ok := err.(*ParseError).Err.(*LimitError); ok {
+		t.Fatalf("unexpected *LimitError: %s", err)
+	}
+}
+
+func TestScannerLimits(t *testing.T) {
+	var sc Scanner
+	sc.Limits = Limits{
+		MaxValues:       3,
+		MaxStringLength: 3,
+	}
+	sc.Init(`[1,2] "abc" [1,2,3] "x"`)
+	n := 0
+	for sc.Next() {
+		n++
+	}
+	if n != 2 {
+		t.Fatalf("unexpected number of values; got %d; want %d", n, 2)
+	}
+	err := sc.Error()
+	pe, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("unexpected error type; got %T; want *ParseError", err)
+	}
+	if le, ok := pe.Err.(*LimitError); !ok || le.Code != LimitValues || le.Limit != 3 {
+		t.Fatalf("unexpected ParseError.Err: %#v", pe.Err)
+	}
+	if pe.Offset != 17 {
+		t.Fatalf("unexpected offset; got %d; want %d", pe.Offset, 17)
+	}
+
+	// Limits are applied to each value in recovery mode.
+	sc.SkipInvalid = true
+	var codes []LimitCode
+	sc.ErrorHandler = func(err *ScanError) {
+		if le, ok := err.Err.(*ParseError).Err.(*LimitError); ok {
+			codes = append(codes, le.Code)
+		}
+	}
+	sc.Init("[1,2,3]\n\"abcd\"\n[1]\n\"abc\"\n")
+	n = 0
+	for sc.Next() {
+		n++
+	}
+	if err := sc.Error(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if n != 2 {
+		t.Fatalf("unexpected number of values; got %d; want %d", n, 2)
+	}
+	if len(codes) != 2 || codes[0] != LimitValues || codes[1] != LimitStringLength {
+		t.Fatalf("unexpected limit codes: %v", codes)
+	}
+
+	// Too big input.
+	sc.Limits = Limits{MaxInputSize: 4}
+	sc.Init(`1 2 3`)
+	if sc.Next() {
+		t.Fatalf("unexpected value %s", sc.Value())
+	}
+	pe, ok = sc.Error().(*ParseError)
+	if !ok {
+		t.Fatalf("unexpected error type; got %T; want *ParseError", sc.Error())
+	}
+	if le, ok := pe.Err.(*LimitError); !ok || le.Code != LimitInputSize {
+		t.Fatalf("unexpected ParseError.Err: %#v", pe.Err)
+	}
+	sc.Init(`1 2`)
+	n = 0
+	for sc.Next() {
+		n++
+	}
+	if err := sc.Error(); err != nil || n != 2 {
+		t.Fatalf("unexpected result; n=%d, err=%v", n, err)
+	}
+}
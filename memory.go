@@ -0,0 +1,62 @@
+package fastjson
+
+import (
+	"unsafe"
+)
+
+const (
+	valueSize  = int(unsafe.Sizeof(Value{}))
+	kvSize     = int(unsafe.Sizeof(kv{}))
+	ptrSize    = int(unsafe.Sizeof(uintptr(0)))
+	intSize    = int(unsafe.Sizeof(int(0)))
+	frameSize  = int(unsafe.Sizeof(parseFrame{}))
+	posSize    = int(unsafe.Sizeof(valuePos{}))
+	keyPosSize = int(unsafe.Sizeof(keyPos{}))
+
+	// keysEntrySize is the estimated size of cache.keys entry
+	// including the map overhead.
+	keysEntrySize = 2 * (int(unsafe.Sizeof(objectKey{})) + intSize)
+)
+
+// MemoryUsage returns the approximate number of bytes retained by p
+// for subsequent parsing.
+//
+// Parser retains memory proportional to the biggest parsed JSON.
+// Use Shrink for releasing memory after parsing big JSON.
+//
+// MemoryUsage is cheap, since the retained memory is tracked during parsing.
+// Memory allocated by modifying the parsed values isn't taken into account.
+func (p *Parser) MemoryUsage() int {
+	n := cap(p.b) + cap(p.lines)*intSize
+	return n + p.c.memoryUsage()
+}
+
+func (c *cache) memoryUsage() int {
+	n := len(c.slabs)*(valuesPerSlab*valueSize+ptrSize) + cap(c.stack)*frameSize
+	n += len(c.posSlabs) * (valuesPerSlab*posSize + ptrSize)
+	return n + c.retained
+}
+
+// Shrink releases memory retained by p if MemoryUsage exceeds
+// maxMemoryUsage bytes.
+//
+// It returns true if the memory has been released.
+//
+// Values obtained from p cannot be used after Shrink call.
+func (p *Parser) Shrink(maxMemoryUsage int) bool {
+	if p.MemoryUsage() <= maxMemoryUsage {
+		return false
+	}
+	p.b = nil
+	p.lines = nil
+	p.linesReady = false
+	p.c.slabs = nil
+	p.c.posSlabs = nil
+	p.c.retained = 0
+	p.c.n = 0
+	p.c.keys = nil
+	p.c.maxKeys = 0
+	p.c.stack = nil
+	p.c.input = ""
+	return true
+}
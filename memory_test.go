@@ -0,0 +1,213 @@
+package fastjson
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParserMemoryUsage(t *testing.T) {
+	var p Parser
+	if n := p.MemoryUsage(); n != 0 {
+		t.Fatalf("unexpected memory usage for new parser; got %d; want 0", n)
+	}
+	if _, err := p.Parse(`{"a":[1,2]}`); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	small := p.MemoryUsage()
+	if small <= 0 {
+		t.Fatalf("memory usage must be positive; got %d", small)
+	}
+
+	// Parse big JSON.
+	big := `[` + strings.Repeat(`{"foo":"bar","baz":[1,2,3]},`, 10000) + `{}]`
+	if _, err := p.Parse(big); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	bigUsage := p.MemoryUsage()
+	if bigUsage < len(big) || bigUsage < 10000*valueSize {
+		t.Fatalf("too small memory usage after parsing big JSON; got %d", bigUsage)
+	}
+
+	// Memory is retained after parsing small JSON.
+	if _, err := p.Parse(`{"a":[1,2]}`); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	bigUsage = p.MemoryUsage()
+	if bigUsage < len(big) {
+		t.Fatalf("memory must be retained after parsing small JSON; got %d bytes", bigUsage)
+	}
+
+	// Shrink doesn't release memory below the limit.
+	if p.Shrink(bigUsage) {
+		t.Fatalf("Shrink mustn't release memory below the limit")
+	}
+	if n := p.MemoryUsage(); n != bigUsage {
+		t.Fatalf("unexpected memory usage; got %d; want %d", n, bigUsage)
+	}
+
+	// Shrink releases memory above the limit.
+	if !p.Shrink(small) {
+		t.Fatalf("Shrink must release memory above the limit")
+	}
+	if n := p.MemoryUsage(); n != 0 {
+		t.Fatalf("unexpected memory usage after Shrink; got %d; want 0", n)
+	}
+
+	// The parser works after Shrink.
+	p.RecordPositions = true
+	v, err := p.Parse(`{"a":[1,2]}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if s := v.String(); s != `{"a":[1,2]}` {
+		t.Fatalf("unexpected value; got %s; want %s", s, `{"a":[1,2]}`)
+	}
+}
+
+func TestParserMemoryUsageRetained(t *testing.T) {
+	// memoryUsageSlow visits all the values in order to calculate
+	// the memory retained by them.
+	memoryUsageSlow := func(c *cache) int {
+		n := 0
+		for _, slab := range c.slabs {
+			for i := range slab {
+				v := &slab[i]
+				n += cap(v.a)*ptrSize + cap(v.o.kvs)*kvSize
+			}
+		}
+		for _, ps := range c.posSlabs {
+			for i := range ps {
+				n += cap(ps[i].keys) * keyPosSize
+			}
+		}
+		return n + c.maxKeys*keysEntrySize
+	}
+
+	var p Parser
+	f := func(s string) {
+		t.Helper()
+		if _, err := p.Parse(s); err != nil {
+			t.Fatalf("unexpected error when parsing %q: %s", s, err)
+		}
+		if n := memoryUsageSlow(&p.c); n != p.c.retained {
+			t.Fatalf("unexpected retained memory after parsing %q; got %d; want %d", s, p.c.retained, n)
+		}
+	}
+
+	f(`{"a":[1,2,3],"b":{"c":[]}}`)
+	f(`[` + strings.Repeat(`{"foo":"bar","baz":[1,2,3]},`, 1000) + `{}]`)
+	f(`{"a":[1,2,3,4,5,6,7,8,9,10],"b":{"c":[1],"d":2,"e":3}}`)
+	p.RecordPositions = true
+	f(`{"a":{"b":1,"c":2,"d":3,"e":4,"f":5}}`)
+	p.Relaxed = true
+	f(`{a: [1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11], b: {c: 1, d: 2, e: 3, f: 4, g: 5, h: 6}}`)
+	p.RecordPositions = false
+	f(`[` + strings.Repeat(`[1,2,3,4,5],`, 100) + `{}]`)
+	p.Relaxed = false
+	p.DuplicateKeys = DuplicateKeysReject
+	bigObject := func(n int) string {
+		var a []string
+		for i := 0; i < n; i++ {
+			a = append(a, fmt.Sprintf(`"k%d":%d`, i, i))
+		}
+		return "{" + strings.Join(a, ",") + "}"
+	}
+	f(bigObject(100))
+	f(`[` + bigObject(20) + `,` + bigObject(30) + `]`)
+	f(bigObject(50))
+	if p.c.maxKeys != 100 {
+		t.Fatalf("unexpected maxKeys; got %d; want %d", p.c.maxKeys, 100)
+	}
+
+	p.Shrink(0)
+	if p.c.retained != 0 {
+		t.Fatalf("unexpected retained memory after Shrink; got %d; want 0", p.c.retained)
+	}
+	if p.c.keys != nil || p.c.maxKeys != 0 {
+		t.Fatalf("keys index must be released after Shrink")
+	}
+}
+
+func TestCacheSlabs(t *testing.T) {
+	var c cache
+	first := c.getValue()
+	first.s = "first"
+	const n = 10 * valuesPerSlab
+	for i := 1; i < n; i++ {
+		c.getValue()
+	}
+	if len(c.slabs) != 10 {
+		t.Fatalf("unexpected number of slabs; got %d; want %d", len(c.slabs), 10)
+	}
+
+	// The cache growth mustn't move the already allocated values.
+	if first != &c.slabs[0][0] || first.s != "first" {
+		t.Fatalf("the first value has been moved on cache growth")
+	}
+
+	// Slabs are re-used after reset.
+	c.reset()
+	if v := c.getValue(); v != first || v.s != "" {
+		t.Fatalf("the first value must be re-used after reset")
+	}
+	for i := 1; i < n; i++ {
+		c.getValue()
+	}
+	if len(c.slabs) != 10 {
+		t.Fatalf("unexpected number of slabs after reset; got %d; want %d", len(c.slabs), 10)
+	}
+}
+
+func TestParserMemoryUsageProportional(t *testing.T) {
+	var p Parser
+	const items = 10000
+	s := `[` + strings.Repeat(`1,`, items) + `1]`
+	if _, err := p.Parse(s); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	values := items + 2
+	slabs := (values + valuesPerSlab - 1) / valuesPerSlab
+	if len(p.c.slabs) != slabs {
+		t.Fatalf("unexpected number of slabs; got %d; want %d", len(p.c.slabs), slabs)
+	}
+}
+
+func TestParserPoolShrink(t *testing.T) {
+	pp := ParserPool{
+		MaxParserMemoryUsage: 64 * 1024,
+	}
+	big := `[` + strings.Repeat(`"foobar",`, 10000) + `1]`
+	for i := 0; i < 10; i++ {
+		p := pp.Get()
+		s := fmt.Sprintf(`{"i":%d}`, i)
+		if i%2 == 0 {
+			s = big
+		}
+		if _, err := p.Parse(s); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		// p cannot be accessed after Put, so measure its memory usage before.
+		shrinks := pp.Stats().Shrinks
+		n := p.MemoryUsage()
+		pp.Put(p)
+		expectedShrinks := shrinks
+		if n > pp.MaxParserMemoryUsage {
+			expectedShrinks++
+		}
+		if shrinks := pp.Stats().Shrinks; shrinks != expectedShrinks {
+			t.Fatalf("unexpected number of shrinks after Put of parser with memory usage %d; got %d; want %d", n, shrinks, expectedShrinks)
+		}
+	}
+	stats := pp.Stats()
+	if stats.Gets != 10 || stats.Puts != 10 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if stats.News == 0 || stats.News > 10 {
+		t.Fatalf("unexpected number of new parsers: %d", stats.News)
+	}
+	if stats.Shrinks != 5 {
+		t.Fatalf("unexpected number of shrinks; got %d; want %d", stats.Shrinks, 5)
+	}
+}
@@ -0,0 +1,54 @@
+package openrtb_test
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/valyala/fastjson"
+	"github.com/valyala/fastjson/openrtb"
+)
+
+func Example() {
+	s := `{
+		"id": "req-1",
+		"imp": [{"id": "1", "bidfloor": 0.5, "banner": {"w": 300, "h": 250}}],
+		"site": {"domain": "example.com"},
+		"regs": {"ext": {"gdpr": 1}}
+	}`
+	var p fastjson.Parser
+	v, err := p.Parse(s)
+	if err != nil {
+		log.Fatalf("cannot parse bid request: %s", err)
+	}
+	req := openrtb.NewBidRequest(v)
+	if err := req.Validate(); err != nil {
+		log.Fatalf("invalid bid request: %s", err)
+	}
+	imp := req.Imp(0)
+	fmt.Printf("site=%s, gdpr=%v\n", req.Site().Domain(), req.GDPR())
+	fmt.Printf("imp=%s, size=%dx%d, floor=%v %s\n", imp.ID(), imp.Banner().W(), imp.Banner().H(), imp.BidFloor(), imp.BidFloorCur())
+
+	resp := openrtb.BidResponse{
+		ID: string(req.ID()),
+		SeatBids: []openrtb.SeatBid{{
+			Bids: []openrtb.Bid{{
+				ID:    "bid-1",
+				ImpID: string(imp.ID()),
+				Price: 1.5,
+				AdM:   "<div>ad</div>",
+				W:     imp.Banner().W(),
+				H:     imp.Banner().H(),
+			}},
+		}},
+	}
+	b, err := resp.MarshalTo(nil)
+	if err != nil {
+		log.Fatalf("cannot marshal bid response: %s", err)
+	}
+	fmt.Printf("%s\n", b)
+
+	// Output:
+	// site=example.com, gdpr=true
+	// imp=1, size=300x250, floor=0.5 USD
+	// {"id":"req-1","seatbid":[{"bid":[{"id":"bid-1","impid":"1","price":1.5,"adm":"<div>ad</div>","w":300,"h":250}]}]}
+}
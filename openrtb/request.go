@@ -0,0 +1,824 @@
+// Package openrtb provides typed views over OpenRTB 2.5 and 2.6 bid requests
+// parsed by fastjson and a serializer for bid responses.
+//
+// Views are thin wrappers around *fastjson.Value, so they don't allocate
+// memory. Views are valid until the next Parse call on the Parser returned
+// the underlying value. Missing fields are returned as zero values
+// or as defaults defined by the specification.
+//
+// Fields moved from ext objects to the main objects in OpenRTB 2.6
+// such as regs.gdpr and user.consent are read from both locations.
+//
+// Views cannot be used from concurrent goroutines unless
+// the underlying value is frozen with fastjson.Value.Freeze.
+//
+// See https://www.iab.com/guidelines/openrtb/ for the specification.
+package openrtb
+
+import (
+	"fmt"
+
+	"github.com/valyala/fastjson"
+)
+
+// Strings is a view over JSON array of strings.
+type Strings struct {
+	a []*fastjson.Value
+}
+
+// Len returns the number of items in s.
+func (s Strings) Len() int {
+	return len(s.a)
+}
+
+// At returns the item at index i.
+//
+// nil is returned if the item isn't a string.
+func (s Strings) At(i int) []byte {
+	return s.a[i].GetStringBytes()
+}
+
+// Contains returns true if s contains the given item.
+func (s Strings) Contains(item string) bool {
+	for _, v := range s.a {
+		if string(v.GetStringBytes()) == item {
+			return true
+		}
+	}
+	return false
+}
+
+// Ints is a view over JSON array of integers.
+type Ints struct {
+	a []*fastjson.Value
+}
+
+// Len returns the number of items in a.
+func (a Ints) Len() int {
+	return len(a.a)
+}
+
+// At returns the item at index i.
+//
+// 0 is returned if the item isn't a number.
+func (a Ints) At(i int) int {
+	return a.a[i].GetInt()
+}
+
+// Contains returns true if a contains the given item.
+func (a Ints) Contains(item int) bool {
+	for _, v := range a.a {
+		if v.Type() == fastjson.TypeNumber && v.GetInt() == item {
+			return true
+		}
+	}
+	return false
+}
+
+// BidRequest is a view over OpenRTB BidRequest object.
+type BidRequest struct {
+	v *fastjson.Value
+}
+
+// NewBidRequest returns BidRequest view over v.
+func NewBidRequest(v *fastjson.Value) BidRequest {
+	return BidRequest{v}
+}
+
+// Validate verifies that r contains the fields required
+// by the specification.
+func (r BidRequest) Validate() error {
+	if r.v.Type() != fastjson.TypeObject {
+		return fmt.Errorf("bid request must be an object; got %s", r.v.Type())
+	}
+	if len(r.ID()) == 0 {
+		return fmt.Errorf("missing bid request id")
+	}
+	n := r.ImpCount()
+	if n == 0 {
+		return fmt.Errorf("bid request must contain at least one imp")
+	}
+	for i := 0; i < n; i++ {
+		if len(r.Imp(i).ID()) == 0 {
+			return fmt.Errorf("missing id for imp #%d", i)
+		}
+	}
+	return nil
+}
+
+// Value returns the underlying value.
+func (r BidRequest) Value() *fastjson.Value {
+	return r.v
+}
+
+// ID returns the bid request id.
+func (r BidRequest) ID() []byte {
+	return r.v.GetStringBytes("id")
+}
+
+// ImpCount returns the number of imps in r.
+func (r BidRequest) ImpCount() int {
+	return len(r.v.GetArray("imp"))
+}
+
+// Imp returns the imp at index i.
+func (r BidRequest) Imp(i int) Imp {
+	return Imp{r.v.GetArray("imp")[i]}
+}
+
+// Site returns the site.
+func (r BidRequest) Site() Site {
+	return Site{r.v.Get("site")}
+}
+
+// App returns the app.
+func (r BidRequest) App() App {
+	return App{r.v.Get("app")}
+}
+
+// Device returns the device.
+func (r BidRequest) Device() Device {
+	return Device{r.v.Get("device")}
+}
+
+// User returns the user.
+func (r BidRequest) User() User {
+	return User{r.v.Get("user")}
+}
+
+// Test returns true if r is a test request.
+func (r BidRequest) Test() bool {
+	return r.v.GetInt("test") == 1
+}
+
+// AT returns the auction type. 1 is the first price auction,
+// 2 is the second price plus auction.
+//
+// 2 is returned if the auction type is missing.
+func (r BidRequest) AT() int {
+	if r.v.Get("at") == nil {
+		return 2
+	}
+	return r.v.GetInt("at")
+}
+
+// TMax returns the maximum time in milliseconds for the bid response.
+func (r BidRequest) TMax() int {
+	return r.v.GetInt("tmax")
+}
+
+// Cur returns allowed currencies for bids.
+func (r BidRequest) Cur() Strings {
+	return Strings{r.v.GetArray("cur")}
+}
+
+// BCat returns blocked advertiser categories.
+func (r BidRequest) BCat() Strings {
+	return Strings{r.v.GetArray("bcat")}
+}
+
+// BAdv returns blocked advertiser domains.
+func (r BidRequest) BAdv() Strings {
+	return Strings{r.v.GetArray("badv")}
+}
+
+// BApp returns blocked app bundles.
+func (r BidRequest) BApp() Strings {
+	return Strings{r.v.GetArray("bapp")}
+}
+
+// COPPA returns true if the request is subject to COPPA regulations.
+func (r BidRequest) COPPA() bool {
+	return r.v.GetInt("regs", "coppa") == 1
+}
+
+// GDPR returns true if the request is subject to GDPR regulations.
+//
+// regs.gdpr from OpenRTB 2.6 and regs.ext.gdpr from OpenRTB 2.5
+// are supported.
+func (r BidRequest) GDPR() bool {
+	if v := r.v.Get("regs", "gdpr"); v != nil {
+		return v.GetInt() == 1
+	}
+	return r.v.GetInt("regs", "ext", "gdpr") == 1
+}
+
+// USPrivacy returns CCPA US Privacy string.
+//
+// regs.us_privacy from OpenRTB 2.6 and regs.ext.us_privacy
+// from OpenRTB 2.5 are supported.
+func (r BidRequest) USPrivacy() []byte {
+	if v := r.v.Get("regs", "us_privacy"); v != nil {
+		return v.GetStringBytes()
+	}
+	return r.v.GetStringBytes("regs", "ext", "us_privacy")
+}
+
+// Ext returns the ext object.
+func (r BidRequest) Ext() *fastjson.Value {
+	return r.v.Get("ext")
+}
+
+// Imp is a view over OpenRTB Imp object.
+type Imp struct {
+	v *fastjson.Value
+}
+
+// Value returns the underlying value.
+func (imp Imp) Value() *fastjson.Value {
+	return imp.v
+}
+
+// ID returns the imp id.
+func (imp Imp) ID() []byte {
+	return imp.v.GetStringBytes("id")
+}
+
+// Banner returns the banner.
+func (imp Imp) Banner() Banner {
+	return Banner{imp.v.Get("banner")}
+}
+
+// Video returns the video.
+func (imp Imp) Video() Video {
+	return Video{imp.v.Get("video")}
+}
+
+// Native returns the native.
+func (imp Imp) Native() Native {
+	return Native{imp.v.Get("native")}
+}
+
+// PMP returns the private marketplace.
+func (imp Imp) PMP() PMP {
+	return PMP{imp.v.Get("pmp")}
+}
+
+// TagID returns the ad tag id.
+func (imp Imp) TagID() []byte {
+	return imp.v.GetStringBytes("tagid")
+}
+
+// BidFloor returns the minimum bid in BidFloorCur currency per 1000
+// impressions.
+func (imp Imp) BidFloor() float64 {
+	return imp.v.GetFloat64("bidfloor")
+}
+
+// BidFloorCur returns the currency for BidFloor.
+//
+// "USD" is returned if the currency is missing.
+func (imp Imp) BidFloorCur() []byte {
+	if cur := imp.v.GetStringBytes("bidfloorcur"); len(cur) > 0 {
+		return cur
+	}
+	return usd
+}
+
+var usd = []byte("USD")
+
+// Instl returns true for interstitial or full screen imp.
+func (imp Imp) Instl() bool {
+	return imp.v.GetInt("instl") == 1
+}
+
+// Secure returns true if the imp requires secure HTTPS creatives.
+func (imp Imp) Secure() bool {
+	return imp.v.GetInt("secure") == 1
+}
+
+// Ext returns the ext object.
+func (imp Imp) Ext() *fastjson.Value {
+	return imp.v.Get("ext")
+}
+
+// Banner is a view over OpenRTB Banner object.
+type Banner struct {
+	v *fastjson.Value
+}
+
+// Exists returns true if the banner exists.
+func (b Banner) Exists() bool {
+	return b.v != nil
+}
+
+// W returns the exact width in device independent pixels.
+func (b Banner) W() int {
+	return b.v.GetInt("w")
+}
+
+// H returns the exact height in device independent pixels.
+func (b Banner) H() int {
+	return b.v.GetInt("h")
+}
+
+// FormatCount returns the number of allowed banner sizes.
+func (b Banner) FormatCount() int {
+	return len(b.v.GetArray("format"))
+}
+
+// Format returns the allowed banner size at index i.
+func (b Banner) Format(i int) Format {
+	return Format{b.v.GetArray("format")[i]}
+}
+
+// Pos returns the ad position on screen.
+func (b Banner) Pos() int {
+	return b.v.GetInt("pos")
+}
+
+// BType returns blocked banner ad types.
+func (b Banner) BType() Ints {
+	return Ints{b.v.GetArray("btype")}
+}
+
+// BAttr returns blocked creative attributes.
+func (b Banner) BAttr() Ints {
+	return Ints{b.v.GetArray("battr")}
+}
+
+// API returns supported API frameworks.
+func (b Banner) API() Ints {
+	return Ints{b.v.GetArray("api")}
+}
+
+// Format is a view over OpenRTB Format object.
+type Format struct {
+	v *fastjson.Value
+}
+
+// W returns the width in device independent pixels.
+func (f Format) W() int {
+	return f.v.GetInt("w")
+}
+
+// H returns the height in device independent pixels.
+func (f Format) H() int {
+	return f.v.GetInt("h")
+}
+
+// Video is a view over OpenRTB Video object.
+type Video struct {
+	v *fastjson.Value
+}
+
+// Exists returns true if the video exists.
+func (vd Video) Exists() bool {
+	return vd.v != nil
+}
+
+// MIMEs returns supported content MIME types.
+func (vd Video) MIMEs() Strings {
+	return Strings{vd.v.GetArray("mimes")}
+}
+
+// MinDuration returns the minimum video ad duration in seconds.
+func (vd Video) MinDuration() int {
+	return vd.v.GetInt("minduration")
+}
+
+// MaxDuration returns the maximum video ad duration in seconds.
+func (vd Video) MaxDuration() int {
+	return vd.v.GetInt("maxduration")
+}
+
+// Protocols returns supported video protocols.
+func (vd Video) Protocols() Ints {
+	return Ints{vd.v.GetArray("protocols")}
+}
+
+// W returns the width of the video player in device independent pixels.
+func (vd Video) W() int {
+	return vd.v.GetInt("w")
+}
+
+// H returns the height of the video player in device independent pixels.
+func (vd Video) H() int {
+	return vd.v.GetInt("h")
+}
+
+// StartDelay returns the start delay in seconds for pre-roll, mid-roll
+// or post-roll placements.
+func (vd Video) StartDelay() int {
+	return vd.v.GetInt("startdelay")
+}
+
+// Linearity returns the linearity of the imp.
+func (vd Video) Linearity() int {
+	return vd.v.GetInt("linearity")
+}
+
+// Placement returns the video placement type.
+//
+// plcmt from OpenRTB 2.6 and placement from OpenRTB 2.5 are supported.
+func (vd Video) Placement() int {
+	if v := vd.v.Get("plcmt"); v != nil {
+		return v.GetInt()
+	}
+	return vd.v.GetInt("placement")
+}
+
+// Native is a view over OpenRTB Native object.
+type Native struct {
+	v *fastjson.Value
+}
+
+// Exists returns true if the native exists.
+func (n Native) Exists() bool {
+	return n.v != nil
+}
+
+// Request returns the native ad request payload.
+func (n Native) Request() []byte {
+	return n.v.GetStringBytes("request")
+}
+
+// Ver returns the version of the native ad specification.
+func (n Native) Ver() []byte {
+	return n.v.GetStringBytes("ver")
+}
+
+// PMP is a view over OpenRTB Pmp object.
+type PMP struct {
+	v *fastjson.Value
+}
+
+// Exists returns true if the private marketplace exists.
+func (pmp PMP) Exists() bool {
+	return pmp.v != nil
+}
+
+// PrivateAuction returns true if bids are restricted to the deals.
+func (pmp PMP) PrivateAuction() bool {
+	return pmp.v.GetInt("private_auction") == 1
+}
+
+// DealCount returns the number of deals.
+func (pmp PMP) DealCount() int {
+	return len(pmp.v.GetArray("deals"))
+}
+
+// Deal returns the deal at index i.
+func (pmp PMP) Deal(i int) Deal {
+	return Deal{pmp.v.GetArray("deals")[i]}
+}
+
+// Deal is a view over OpenRTB Deal object.
+type Deal struct {
+	v *fastjson.Value
+}
+
+// ID returns the deal id.
+func (d Deal) ID() []byte {
+	return d.v.GetStringBytes("id")
+}
+
+// BidFloor returns the minimum bid for the deal.
+func (d Deal) BidFloor() float64 {
+	return d.v.GetFloat64("bidfloor")
+}
+
+// BidFloorCur returns the currency for BidFloor.
+//
+// "USD" is returned if the currency is missing.
+func (d Deal) BidFloorCur() []byte {
+	if cur := d.v.GetStringBytes("bidfloorcur"); len(cur) > 0 {
+		return cur
+	}
+	return usd
+}
+
+// AT returns the auction type for the deal. 1 is the first price auction,
+// 2 is the second price plus auction, 3 is the fixed price.
+//
+// 0 is returned if the auction type is missing, so the auction type
+// of the bid request must be used.
+func (d Deal) AT() int {
+	return d.v.GetInt("at")
+}
+
+// WSeat returns the buyer seats allowed to bid on the deal.
+func (d Deal) WSeat() Strings {
+	return Strings{d.v.GetArray("wseat")}
+}
+
+// Site is a view over OpenRTB Site object.
+type Site struct {
+	v *fastjson.Value
+}
+
+// Exists returns true if the site exists.
+func (s Site) Exists() bool {
+	return s.v != nil
+}
+
+// ID returns the site id.
+func (s Site) ID() []byte {
+	return s.v.GetStringBytes("id")
+}
+
+// Name returns the site name.
+func (s Site) Name() []byte {
+	return s.v.GetStringBytes("name")
+}
+
+// Domain returns the site domain.
+func (s Site) Domain() []byte {
+	return s.v.GetStringBytes("domain")
+}
+
+// Page returns the URL of the page where the imp is shown.
+func (s Site) Page() []byte {
+	return s.v.GetStringBytes("page")
+}
+
+// Ref returns the referrer URL.
+func (s Site) Ref() []byte {
+	return s.v.GetStringBytes("ref")
+}
+
+// Cat returns the site content categories.
+func (s Site) Cat() Strings {
+	return Strings{s.v.GetArray("cat")}
+}
+
+// Publisher returns the site publisher.
+func (s Site) Publisher() Publisher {
+	return Publisher{s.v.Get("publisher")}
+}
+
+// Ext returns the ext object.
+func (s Site) Ext() *fastjson.Value {
+	return s.v.Get("ext")
+}
+
+// App is a view over OpenRTB App object.
+type App struct {
+	v *fastjson.Value
+}
+
+// Exists returns true if the app exists.
+func (a App) Exists() bool {
+	return a.v != nil
+}
+
+// ID returns the app id.
+func (a App) ID() []byte {
+	return a.v.GetStringBytes("id")
+}
+
+// Name returns the app name.
+func (a App) Name() []byte {
+	return a.v.GetStringBytes("name")
+}
+
+// Bundle returns the app bundle or package name.
+func (a App) Bundle() []byte {
+	return a.v.GetStringBytes("bundle")
+}
+
+// Domain returns the app domain.
+func (a App) Domain() []byte {
+	return a.v.GetStringBytes("domain")
+}
+
+// StoreURL returns the app store URL.
+func (a App) StoreURL() []byte {
+	return a.v.GetStringBytes("storeurl")
+}
+
+// Ver returns the app version.
+func (a App) Ver() []byte {
+	return a.v.GetStringBytes("ver")
+}
+
+// Cat returns the app content categories.
+func (a App) Cat() Strings {
+	return Strings{a.v.GetArray("cat")}
+}
+
+// Publisher returns the app publisher.
+func (a App) Publisher() Publisher {
+	return Publisher{a.v.Get("publisher")}
+}
+
+// Ext returns the ext object.
+func (a App) Ext() *fastjson.Value {
+	return a.v.Get("ext")
+}
+
+// Publisher is a view over OpenRTB Publisher object.
+type Publisher struct {
+	v *fastjson.Value
+}
+
+// ID returns the publisher id.
+func (p Publisher) ID() []byte {
+	return p.v.GetStringBytes("id")
+}
+
+// Name returns the publisher name.
+func (p Publisher) Name() []byte {
+	return p.v.GetStringBytes("name")
+}
+
+// Domain returns the publisher domain.
+func (p Publisher) Domain() []byte {
+	return p.v.GetStringBytes("domain")
+}
+
+// Device is a view over OpenRTB Device object.
+type Device struct {
+	v *fastjson.Value
+}
+
+// Exists returns true if the device exists.
+func (d Device) Exists() bool {
+	return d.v != nil
+}
+
+// UA returns the browser user agent.
+func (d Device) UA() []byte {
+	return d.v.GetStringBytes("ua")
+}
+
+// IP returns IPv4 address.
+func (d Device) IP() []byte {
+	return d.v.GetStringBytes("ip")
+}
+
+// IPv6 returns IPv6 address.
+func (d Device) IPv6() []byte {
+	return d.v.GetStringBytes("ipv6")
+}
+
+// Geo returns the device location.
+func (d Device) Geo() Geo {
+	return Geo{d.v.Get("geo")}
+}
+
+// DNT returns true if "Do Not Track" is set.
+func (d Device) DNT() bool {
+	return d.v.GetInt("dnt") == 1
+}
+
+// Lmt returns true if "Limit Ad Tracking" is set.
+func (d Device) Lmt() bool {
+	return d.v.GetInt("lmt") == 1
+}
+
+// DeviceType returns the device type.
+func (d Device) DeviceType() int {
+	return d.v.GetInt("devicetype")
+}
+
+// Make returns the device make.
+func (d Device) Make() []byte {
+	return d.v.GetStringBytes("make")
+}
+
+// Model returns the device model.
+func (d Device) Model() []byte {
+	return d.v.GetStringBytes("model")
+}
+
+// OS returns the device operating system.
+func (d Device) OS() []byte {
+	return d.v.GetStringBytes("os")
+}
+
+// OSV returns the device operating system version.
+func (d Device) OSV() []byte {
+	return d.v.GetStringBytes("osv")
+}
+
+// Language returns the browser language as ISO-639-1-alpha-2 code.
+func (d Device) Language() []byte {
+	return d.v.GetStringBytes("language")
+}
+
+// Carrier returns the carrier or ISP.
+func (d Device) Carrier() []byte {
+	return d.v.GetStringBytes("carrier")
+}
+
+// ConnectionType returns the network connection type.
+func (d Device) ConnectionType() int {
+	return d.v.GetInt("connectiontype")
+}
+
+// IFA returns the advertising id.
+func (d Device) IFA() []byte {
+	return d.v.GetStringBytes("ifa")
+}
+
+// Ext returns the ext object.
+func (d Device) Ext() *fastjson.Value {
+	return d.v.Get("ext")
+}
+
+// Geo is a view over OpenRTB Geo object.
+type Geo struct {
+	v *fastjson.Value
+}
+
+// Exists returns true if the location exists.
+func (g Geo) Exists() bool {
+	return g.v != nil
+}
+
+// Lat returns the latitude.
+func (g Geo) Lat() float64 {
+	return g.v.GetFloat64("lat")
+}
+
+// Lon returns the longitude.
+func (g Geo) Lon() float64 {
+	return g.v.GetFloat64("lon")
+}
+
+// Type returns the location source.
+func (g Geo) Type() int {
+	return g.v.GetInt("type")
+}
+
+// Country returns the country as ISO-3166-1-alpha-3 code.
+func (g Geo) Country() []byte {
+	return g.v.GetStringBytes("country")
+}
+
+// Region returns the region as ISO-3166-2 code.
+func (g Geo) Region() []byte {
+	return g.v.GetStringBytes("region")
+}
+
+// Metro returns the metro code.
+func (g Geo) Metro() []byte {
+	return g.v.GetStringBytes("metro")
+}
+
+// City returns the city name.
+func (g Geo) City() []byte {
+	return g.v.GetStringBytes("city")
+}
+
+// Zip returns the zip or postal code.
+func (g Geo) Zip() []byte {
+	return g.v.GetStringBytes("zip")
+}
+
+// User is a view over OpenRTB User object.
+type User struct {
+	v *fastjson.Value
+}
+
+// Exists returns true if the user exists.
+func (u User) Exists() bool {
+	return u.v != nil
+}
+
+// ID returns the exchange-specific user id.
+func (u User) ID() []byte {
+	return u.v.GetStringBytes("id")
+}
+
+// BuyerUID returns the buyer-specific user id.
+func (u User) BuyerUID() []byte {
+	return u.v.GetStringBytes("buyeruid")
+}
+
+// YOB returns the year of birth.
+func (u User) YOB() int {
+	return u.v.GetInt("yob")
+}
+
+// Gender returns the gender. "M" is male, "F" is female, "O" is other.
+func (u User) Gender() []byte {
+	return u.v.GetStringBytes("gender")
+}
+
+// Keywords returns comma separated list of keywords.
+func (u User) Keywords() []byte {
+	return u.v.GetStringBytes("keywords")
+}
+
+// Geo returns the user home location.
+func (u User) Geo() Geo {
+	return Geo{u.v.Get("geo")}
+}
+
+// Consent returns GDPR consent string.
+//
+// user.consent from OpenRTB 2.6 and user.ext.consent from OpenRTB 2.5
+// are supported.
+func (u User) Consent() []byte {
+	if v := u.v.Get("consent"); v != nil {
+		return v.GetStringBytes()
+	}
+	return u.v.GetStringBytes("ext", "consent")
+}
+
+// Ext returns the ext object.
+func (u User) Ext() *fastjson.Value {
+	return u.v.Get("ext")
+}
@@ -0,0 +1,298 @@
+package openrtb
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/valyala/fastjson"
+)
+
+func parseFixture(t *testing.T, p *fastjson.Parser, name string) BidRequest {
+	t.Helper()
+	data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("cannot read fixture: %s", err)
+	}
+	v, err := p.ParseBytes(data)
+	if err != nil {
+		t.Fatalf("cannot parse %s: %s", name, err)
+	}
+	r := NewBidRequest(v)
+	if err := r.Validate(); err != nil {
+		t.Fatalf("invalid bid request in %s: %s", name, err)
+	}
+	return r
+}
+
+func expectString(t *testing.T, name string, b []byte, expected string) {
+	t.Helper()
+	if string(b) != expected {
+		t.Fatalf("unexpected %s; got %q; want %q", name, b, expected)
+	}
+}
+
+func expectInt(t *testing.T, name string, n, expected int) {
+	t.Helper()
+	if n != expected {
+		t.Fatalf("unexpected %s; got %d; want %d", name, n, expected)
+	}
+}
+
+func expectFloat64(t *testing.T, name string, f, expected float64) {
+	t.Helper()
+	if f != expected {
+		t.Fatalf("unexpected %s; got %v; want %v", name, f, expected)
+	}
+}
+
+func expectBool(t *testing.T, name string, b, expected bool) {
+	t.Helper()
+	if b != expected {
+		t.Fatalf("unexpected %s; got %v; want %v", name, b, expected)
+	}
+}
+
+func TestBidRequestBanner(t *testing.T) {
+	var p fastjson.Parser
+	r := parseFixture(t, &p, "banner.json")
+
+	expectString(t, "id", r.ID(), "80ce30c53c16e6ede735f123ef6e32361bfc7b22")
+	expectInt(t, "at", r.AT(), 1)
+	expectInt(t, "cur count", r.Cur().Len(), 1)
+	expectString(t, "cur", r.Cur().At(0), "USD")
+	expectBool(t, "test", r.Test(), false)
+	expectBool(t, "gdpr", r.GDPR(), false)
+
+	expectInt(t, "imp count", r.ImpCount(), 1)
+	imp := r.Imp(0)
+	expectString(t, "imp.id", imp.ID(), "1")
+	expectFloat64(t, "imp.bidfloor", imp.BidFloor(), 0.03)
+	expectString(t, "imp.bidfloorcur", imp.BidFloorCur(), "USD")
+	expectBool(t, "imp.banner exists", imp.Banner().Exists(), true)
+	expectBool(t, "imp.video exists", imp.Video().Exists(), false)
+	expectBool(t, "imp.native exists", imp.Native().Exists(), false)
+	expectBool(t, "imp.pmp exists", imp.PMP().Exists(), false)
+	expectInt(t, "imp.banner.w", imp.Banner().W(), 300)
+	expectInt(t, "imp.banner.h", imp.Banner().H(), 250)
+	expectInt(t, "imp.banner.format count", imp.Banner().FormatCount(), 0)
+
+	site := r.Site()
+	expectBool(t, "site exists", site.Exists(), true)
+	expectBool(t, "app exists", r.App().Exists(), false)
+	expectString(t, "site.id", site.ID(), "102855")
+	expectString(t, "site.domain", site.Domain(), "www.foobar.com")
+	expectBool(t, "site.cat contains", site.Cat().Contains("IAB3-1"), true)
+	expectBool(t, "site.cat contains", site.Cat().Contains("IAB3"), false)
+	expectString(t, "site.publisher.id", site.Publisher().ID(), "8953")
+	expectString(t, "site.publisher.name", site.Publisher().Name(), "foobar.com")
+
+	expectString(t, "device.ip", r.Device().IP(), "123.145.167.10")
+	expectBool(t, "device.geo exists", r.Device().Geo().Exists(), false)
+	expectString(t, "user.id", r.User().ID(), "55816b39711f9b5acf3b90e313ed29e51665623f")
+	expectString(t, "user.consent", r.User().Consent(), "")
+}
+
+func TestBidRequestApp(t *testing.T) {
+	var p fastjson.Parser
+	r := parseFixture(t, &p, "app.json")
+
+	expectInt(t, "at", r.AT(), 2)
+	expectInt(t, "bcat count", r.BCat().Len(), 5)
+	expectBool(t, "bcat contains", r.BCat().Contains("IAB8-18"), true)
+	expectBool(t, "badv contains", r.BAdv().Contains("heywire.com"), true)
+	expectInt(t, "bapp count", r.BApp().Len(), 0)
+
+	imp := r.Imp(0)
+	expectString(t, "imp.tagid", imp.TagID(), "agltb3B1Yi1pbmNyDQsSBFNpdGUY7fD0FAw")
+	expectBool(t, "imp.instl", imp.Instl(), false)
+	b := imp.Banner()
+	expectInt(t, "imp.banner.pos", b.Pos(), 1)
+	expectBool(t, "imp.banner.btype contains", b.BType().Contains(4), true)
+	expectBool(t, "imp.banner.battr contains", b.BAttr().Contains(13), false)
+	expectInt(t, "imp.banner.api", b.API().At(0), 3)
+
+	app := r.App()
+	expectBool(t, "site exists", r.Site().Exists(), false)
+	expectString(t, "app.name", app.Name(), "Yahoo Weather")
+	expectString(t, "app.bundle", app.Bundle(), "12345")
+	expectString(t, "app.ver", app.Ver(), "1.0.2")
+	expectString(t, "app.storeurl", app.StoreURL(), "https://itunes.apple.com/id628677149")
+	expectString(t, "app.publisher.domain", app.Publisher().Domain(), "www.yahoo.com")
+
+	d := r.Device()
+	expectString(t, "device.ifa", d.IFA(), "AA000DFE74168477C70D291f574D344790E0BB11")
+	expectString(t, "device.make", d.Make(), "Apple")
+	expectString(t, "device.model", d.Model(), "iPhone")
+	expectString(t, "device.os", d.OS(), "iOS")
+	expectString(t, "device.osv", d.OSV(), "6.1")
+	expectString(t, "device.carrier", d.Carrier(), "VERIZON")
+	expectString(t, "device.language", d.Language(), "en")
+	expectInt(t, "device.connectiontype", d.ConnectionType(), 3)
+	expectInt(t, "device.devicetype", d.DeviceType(), 1)
+	expectBool(t, "device.dnt", d.DNT(), false)
+	g := d.Geo()
+	expectFloat64(t, "device.geo.lat", g.Lat(), 35.012345)
+	expectFloat64(t, "device.geo.lon", g.Lon(), -115.12345)
+	expectString(t, "device.geo.country", g.Country(), "USA")
+	expectString(t, "device.geo.region", g.Region(), "CA")
+	expectString(t, "device.geo.metro", g.Metro(), "803")
+	expectString(t, "device.geo.city", g.City(), "Los Angeles")
+	expectString(t, "device.geo.zip", g.Zip(), "90049")
+
+	u := r.User()
+	expectInt(t, "user.yob", u.YOB(), 1984)
+	expectString(t, "user.gender", u.Gender(), "M")
+}
+
+func TestBidRequestVideo(t *testing.T) {
+	var p fastjson.Parser
+	r := parseFixture(t, &p, "video.json")
+
+	expectInt(t, "tmax", r.TMax(), 120)
+	expectBool(t, "gdpr", r.GDPR(), true)
+
+	vd := r.Imp(0).Video()
+	expectBool(t, "imp.video exists", vd.Exists(), true)
+	expectBool(t, "imp.banner exists", r.Imp(0).Banner().Exists(), false)
+	expectInt(t, "imp.video.w", vd.W(), 640)
+	expectInt(t, "imp.video.h", vd.H(), 480)
+	expectInt(t, "imp.video.minduration", vd.MinDuration(), 5)
+	expectInt(t, "imp.video.maxduration", vd.MaxDuration(), 30)
+	expectInt(t, "imp.video.linearity", vd.Linearity(), 1)
+	expectInt(t, "imp.video.startdelay", vd.StartDelay(), 0)
+	expectInt(t, "imp.video.placement", vd.Placement(), 1)
+	expectInt(t, "imp.video.mimes count", vd.MIMEs().Len(), 4)
+	expectBool(t, "imp.video.mimes contains", vd.MIMEs().Contains("video/mp4"), true)
+	expectBool(t, "imp.video.protocols contains", vd.Protocols().Contains(3), true)
+
+	s := r.Site()
+	expectString(t, "site.name", s.Name(), "Site ABCD")
+	expectString(t, "site.ref", s.Ref(), "http://referringsite.com/referringpage.htm")
+	expectString(t, "site.page", s.Page(), "http://siteabcd.com/page.htm")
+
+	expectString(t, "user.buyeruid", r.User().BuyerUID(), "545678765467876567898765678987654")
+}
+
+func TestBidRequestPMP(t *testing.T) {
+	var p fastjson.Parser
+	r := parseFixture(t, &p, "pmp.json")
+
+	pmp := r.Imp(0).PMP()
+	expectBool(t, "imp.pmp exists", pmp.Exists(), true)
+	expectBool(t, "imp.pmp.private_auction", pmp.PrivateAuction(), true)
+	expectInt(t, "imp.pmp.deals count", pmp.DealCount(), 2)
+	d := pmp.Deal(0)
+	expectString(t, "deal.id", d.ID(), "AB-Agency1-0001")
+	expectInt(t, "deal.at", d.AT(), 1)
+	expectFloat64(t, "deal.bidfloor", d.BidFloor(), 2.5)
+	expectString(t, "deal.bidfloorcur", d.BidFloorCur(), "USD")
+	expectBool(t, "deal.wseat contains", d.WSeat().Contains("Agency1"), true)
+	d = pmp.Deal(1)
+	expectString(t, "deal.id", d.ID(), "XY-Agency2-0001")
+	expectFloat64(t, "deal.bidfloor", d.BidFloor(), 2)
+
+	// OpenRTB 2.5 consent in user.ext.
+	expectString(t, "user.consent", r.User().Consent(), "BOEFEAyOEFEAyAHABDENAI4AAAB9vABAASA")
+}
+
+func TestBidRequestOpenRTB26(t *testing.T) {
+	var p fastjson.Parser
+	r := parseFixture(t, &p, "native_2_6.json")
+
+	expectInt(t, "at", r.AT(), 2)
+	expectInt(t, "tmax", r.TMax(), 100)
+
+	// OpenRTB 2.6 fields take precedence over the ext fields.
+	expectBool(t, "gdpr", r.GDPR(), true)
+	expectString(t, "us_privacy", r.USPrivacy(), "1YNN")
+	expectString(t, "user.consent", r.User().Consent(), "CPXxRfAPXxRfAAfKABENB-CgAAAAAAAAAAYgAAAAAAAA")
+
+	expectInt(t, "imp count", r.ImpCount(), 2)
+	imp := r.Imp(0)
+	expectString(t, "imp.bidfloorcur", imp.BidFloorCur(), "EUR")
+	expectBool(t, "imp.secure", imp.Secure(), true)
+	n := imp.Native()
+	expectBool(t, "imp.native exists", n.Exists(), true)
+	expectString(t, "imp.native.ver", n.Ver(), "1.2")
+	expectString(t, "imp.native.request", n.Request(), `{"ver":"1.2","assets":[{"id":1,"required":1,"title":{"len":90}}]}`)
+	var pn fastjson.Parser
+	if _, err := pn.ParseBytes(n.Request()); err != nil {
+		t.Fatalf("cannot parse native request: %s", err)
+	}
+
+	vd := r.Imp(1).Video()
+	expectInt(t, "imp.video.placement", vd.Placement(), 1)
+	expectInt(t, "imp.video.w", vd.W(), 1920)
+
+	expectString(t, "app.bundle", r.App().Bundle(), "com.example.news")
+	expectBool(t, "device.lmt", r.Device().Lmt(), true)
+	expectInt(t, "device.devicetype", r.Device().DeviceType(), 4)
+}
+
+func TestBidRequestMissingFields(t *testing.T) {
+	var p fastjson.Parser
+	v, err := p.Parse(`{}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	r := NewBidRequest(v)
+
+	// Views over missing objects return zero values.
+	expectString(t, "id", r.ID(), "")
+	expectInt(t, "at", r.AT(), 2)
+	expectInt(t, "imp count", r.ImpCount(), 0)
+	expectString(t, "site.publisher.id", r.Site().Publisher().ID(), "")
+	expectString(t, "device.geo.country", r.Device().Geo().Country(), "")
+	expectString(t, "user.consent", r.User().Consent(), "")
+	expectInt(t, "cur count", r.Cur().Len(), 0)
+	expectBool(t, "gdpr", r.GDPR(), false)
+	if r.Ext() != nil {
+		t.Fatalf("expecting nil ext")
+	}
+}
+
+func TestBidRequestValidate(t *testing.T) {
+	f := func(s string, expectError bool) {
+		t.Helper()
+		var p fastjson.Parser
+		v, err := p.Parse(s)
+		if err != nil {
+			t.Fatalf("cannot parse %s: %s", s, err)
+		}
+		err = NewBidRequest(v).Validate()
+		if expectError && err == nil {
+			t.Fatalf("expecting non-nil error for %s", s)
+		}
+		if !expectError && err != nil {
+			t.Fatalf("unexpected error for %s: %s", s, err)
+		}
+	}
+
+	f(`{"id":"x","imp":[{"id":"1"}]}`, false)
+	f(`[]`, true)
+	f(`{"imp":[{"id":"1"}]}`, true)
+	f(`{"id":"","imp":[{"id":"1"}]}`, true)
+	f(`{"id":1,"imp":[{"id":"1"}]}`, true)
+	f(`{"id":"x"}`, true)
+	f(`{"id":"x","imp":[]}`, true)
+	f(`{"id":"x","imp":[{"id":"1"},{}]}`, true)
+}
+
+func TestBidRequestZeroAlloc(t *testing.T) {
+	var p fastjson.Parser
+	r := parseFixture(t, &p, "app.json")
+	n := testing.AllocsPerRun(100, func() {
+		imp := r.Imp(0)
+		imp.ID()
+		imp.BidFloorCur()
+		imp.Banner().BType().Contains(4)
+		r.App().Publisher().Name()
+		r.Device().Geo().Lat()
+		r.User().Consent()
+		r.GDPR()
+	})
+	if n != 0 {
+		t.Fatalf("unexpected number of memory allocations; got %v; want 0", n)
+	}
+}
@@ -0,0 +1,244 @@
+package openrtb
+
+import (
+	"sync"
+
+	"github.com/valyala/fastjson"
+)
+
+// BidResponse is OpenRTB BidResponse object.
+//
+// Empty optional fields are omitted during serialization.
+type BidResponse struct {
+	// ID is the id of the bid request.
+	ID string
+
+	// SeatBids contains bids grouped by seats.
+	SeatBids []SeatBid
+
+	// BidID is the bidder generated response id.
+	BidID string
+
+	// Cur is the bid currency. USD is assumed if it is empty.
+	Cur string
+
+	// CustomData is optional data for storing in the exchange cookie.
+	CustomData string
+
+	// NBR is the reason for not bidding.
+	NBR int
+
+	// Ext is optional raw JSON with the ext object.
+	Ext []byte
+}
+
+// SeatBid is OpenRTB SeatBid object.
+type SeatBid struct {
+	// Bids contains bids for the seat.
+	Bids []Bid
+
+	// Seat is the id of the buyer seat.
+	Seat string
+
+	// Group must be set if imps must be won as a group.
+	Group bool
+
+	// Ext is optional raw JSON with the ext object.
+	Ext []byte
+}
+
+// Bid is OpenRTB Bid object.
+type Bid struct {
+	// ID is the bidder generated bid id.
+	ID string
+
+	// ImpID is the id of the imp the bid is related to.
+	ImpID string
+
+	// Price is the bid price per 1000 impressions.
+	Price float64
+
+	// NURL is the win notice URL.
+	NURL string
+
+	// BURL is the billing notice URL.
+	BURL string
+
+	// LURL is the loss notice URL.
+	LURL string
+
+	// AdM is the ad markup.
+	AdM string
+
+	// AdID is the id of a preloaded ad.
+	AdID string
+
+	// ADomain contains advertiser domains.
+	ADomain []string
+
+	// Bundle is the app bundle of the advertised app.
+	Bundle string
+
+	// IURL is the URL of an image representing the campaign content.
+	IURL string
+
+	// CID is the campaign id.
+	CID string
+
+	// CrID is the creative id.
+	CrID string
+
+	// Cat contains content categories of the creative.
+	Cat []string
+
+	// Attr contains creative attributes.
+	Attr []int
+
+	// DealID is the id of the private marketplace deal.
+	DealID string
+
+	// W is the creative width in device independent pixels.
+	W int
+
+	// H is the creative height in device independent pixels.
+	H int
+
+	// Exp is the number of seconds to wait between the auction
+	// and the imp.
+	Exp int
+
+	// Ext is optional raw JSON with the ext object.
+	Ext []byte
+}
+
+// MarshalTo appends JSON representation of r to dst and returns the result.
+//
+// An error is returned if r contains invalid raw JSON in Ext fields.
+func (r *BidResponse) MarshalTo(dst []byte) ([]byte, error) {
+	jw := writerPool.Get().(*fastjson.Writer)
+	jw.Reset(nil)
+	r.WriteJSON(jw)
+	err := jw.Close()
+	if err == nil {
+		dst = append(dst, jw.Bytes()...)
+	}
+	jw.Reset(nil)
+	writerPool.Put(jw)
+	return dst, err
+}
+
+var writerPool = sync.Pool{
+	New: func() interface{} {
+		return &fastjson.Writer{}
+	},
+}
+
+// WriteJSON writes r to jw.
+//
+// Use jw.Close for checking errors.
+func (r *BidResponse) WriteJSON(jw *fastjson.Writer) {
+	jw.BeginObject()
+	jw.Key("id")
+	jw.String(r.ID)
+	if len(r.SeatBids) > 0 {
+		jw.Key("seatbid")
+		jw.BeginArray()
+		for i := range r.SeatBids {
+			r.SeatBids[i].writeJSON(jw)
+		}
+		jw.EndArray()
+	}
+	writeString(jw, "bidid", r.BidID)
+	writeString(jw, "cur", r.Cur)
+	writeString(jw, "customdata", r.CustomData)
+	writeInt(jw, "nbr", r.NBR)
+	writeRaw(jw, "ext", r.Ext)
+	jw.EndObject()
+}
+
+func (sb *SeatBid) writeJSON(jw *fastjson.Writer) {
+	jw.BeginObject()
+	jw.Key("bid")
+	jw.BeginArray()
+	for i := range sb.Bids {
+		sb.Bids[i].writeJSON(jw)
+	}
+	jw.EndArray()
+	writeString(jw, "seat", sb.Seat)
+	if sb.Group {
+		writeInt(jw, "group", 1)
+	}
+	writeRaw(jw, "ext", sb.Ext)
+	jw.EndObject()
+}
+
+func (b *Bid) writeJSON(jw *fastjson.Writer) {
+	jw.BeginObject()
+	jw.Key("id")
+	jw.String(b.ID)
+	jw.Key("impid")
+	jw.String(b.ImpID)
+	jw.Key("price")
+	jw.Float64(b.Price)
+	writeString(jw, "nurl", b.NURL)
+	writeString(jw, "burl", b.BURL)
+	writeString(jw, "lurl", b.LURL)
+	writeString(jw, "adm", b.AdM)
+	writeString(jw, "adid", b.AdID)
+	writeStrings(jw, "adomain", b.ADomain)
+	writeString(jw, "bundle", b.Bundle)
+	writeString(jw, "iurl", b.IURL)
+	writeString(jw, "cid", b.CID)
+	writeString(jw, "crid", b.CrID)
+	writeStrings(jw, "cat", b.Cat)
+	if len(b.Attr) > 0 {
+		jw.Key("attr")
+		jw.BeginArray()
+		for _, n := range b.Attr {
+			jw.Int64(int64(n))
+		}
+		jw.EndArray()
+	}
+	writeString(jw, "dealid", b.DealID)
+	writeInt(jw, "w", b.W)
+	writeInt(jw, "h", b.H)
+	writeInt(jw, "exp", b.Exp)
+	writeRaw(jw, "ext", b.Ext)
+	jw.EndObject()
+}
+
+func writeString(jw *fastjson.Writer, key, s string) {
+	if len(s) == 0 {
+		return
+	}
+	jw.Key(key)
+	jw.String(s)
+}
+
+func writeStrings(jw *fastjson.Writer, key string, a []string) {
+	if len(a) == 0 {
+		return
+	}
+	jw.Key(key)
+	jw.BeginArray()
+	for _, s := range a {
+		jw.String(s)
+	}
+	jw.EndArray()
+}
+
+func writeInt(jw *fastjson.Writer, key string, n int) {
+	if n == 0 {
+		return
+	}
+	jw.Key(key)
+	jw.Int64(int64(n))
+}
+
+func writeRaw(jw *fastjson.Writer, key string, b []byte) {
+	if len(b) == 0 {
+		return
+	}
+	jw.Key(key)
+	jw.Raw(b)
+}
@@ -0,0 +1,110 @@
+package openrtb
+
+import (
+	"testing"
+
+	"github.com/valyala/fastjson"
+)
+
+func TestBidResponseMarshalTo(t *testing.T) {
+	f := func(r *BidResponse, expected string) {
+		t.Helper()
+		b, err := r.MarshalTo(nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if string(b) != expected {
+			t.Fatalf("unexpected response;\ngot\n%s\nwant\n%s", b, expected)
+		}
+		if err := fastjson.ValidateStrictBytes(b); err != nil {
+			t.Fatalf("invalid JSON: %s", err)
+		}
+	}
+
+	// No-bid response.
+	f(&BidResponse{
+		ID:  "1234567890",
+		NBR: 2,
+	}, `{"id":"1234567890","nbr":2}`)
+
+	// Example from the specification.
+	f(&BidResponse{
+		ID:    "1234567890",
+		BidID: "abc1123",
+		Cur:   "USD",
+		SeatBids: []SeatBid{
+			{
+				Seat: "512",
+				Bids: []Bid{
+					{
+						ID:      "1",
+						ImpID:   "102",
+						Price:   9.43,
+						NURL:    "http://adserver.com/winnotice?impid=102",
+						IURL:    "http://adserver.com/pathtosampleimage",
+						ADomain: []string{"advertiserdomain.com"},
+						CID:     "campaign111",
+						CrID:    "creative112",
+						Attr:    []int{1, 2, 3, 4, 5, 6, 7, 12},
+					},
+				},
+			},
+		},
+	}, `{"id":"1234567890","seatbid":[{"bid":[{"id":"1","impid":"102","price":9.43,"nurl":"http://adserver.com/winnotice?impid=102","adomain":["advertiserdomain.com"],"iurl":"http://adserver.com/pathtosampleimage","cid":"campaign111","crid":"creative112","attr":[1,2,3,4,5,6,7,12]}],"seat":"512"}],"bidid":"abc1123","cur":"USD"}`)
+
+	// All the fields.
+	f(&BidResponse{
+		ID: "x",
+		SeatBids: []SeatBid{
+			{
+				Group: true,
+				Ext:   []byte(` {"a": 1} `),
+				Bids: []Bid{
+					{
+						ID:     "1",
+						ImpID:  "2",
+						Price:  0.5,
+						BURL:   "http://b",
+						LURL:   "http://l",
+						AdM:    `<a href="x">ad</a>`,
+						AdID:   "ad",
+						Bundle: "com.foo",
+						Cat:    []string{"IAB1", "IAB2"},
+						DealID: "deal",
+						W:      300,
+						H:      250,
+						Exp:    30,
+						Ext:    []byte(`{}`),
+					},
+					{
+						ID:    "2",
+						ImpID: "3",
+					},
+				},
+			},
+			{},
+		},
+		CustomData: "data",
+		Ext:        []byte(`[1]`),
+	}, `{"id":"x","seatbid":[{"bid":[{"id":"1","impid":"2","price":0.5,"burl":"http://b","lurl":"http://l","adm":"<a href=\"x\">ad</a>","adid":"ad","bundle":"com.foo","cat":["IAB1","IAB2"],"dealid":"deal","w":300,"h":250,"exp":30,"ext":{}},{"id":"2","impid":"3","price":0}],"group":1,"ext":{"a": 1}},{"bid":[]}],"customdata":"data","ext":[1]}`)
+
+	// dst is preserved.
+	r := &BidResponse{ID: "a"}
+	b, err := r.MarshalTo([]byte("foo"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if string(b) != `foo{"id":"a"}` {
+		t.Fatalf("unexpected result: %s", b)
+	}
+
+	// Invalid ext.
+	r = &BidResponse{ID: "a", Ext: []byte(`{foo`)}
+	b, err = r.MarshalTo([]byte("foo"))
+	if err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+	if string(b) != "foo" {
+		t.Fatalf("unexpected result on error: %s", b)
+	}
+}
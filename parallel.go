@@ -0,0 +1,320 @@
+package fastjson
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// ParallelScanner parses line-delimited JSON values ( http://jsonlines.org/ )
+// on multiple goroutines.
+//
+// The input is split into chunks on newline boundaries. Chunks are parsed
+// by concurrently running workers, each with its own Parser obtained
+// from Pool.
+//
+// ParallelScanner may be used from concurrent goroutines.
+type ParallelScanner struct {
+	// Workers is the number of goroutines parsing the input.
+	//
+	// runtime.GOMAXPROCS(0) is used by default.
+	Workers int
+
+	// ChunkSize is the approximate size in bytes of input chunks
+	// passed to workers.
+	//
+	// 64KB is used by default.
+	ChunkSize int
+
+	// Ordered enables delivering values to the callback in the original
+	// order. The callback is never called concurrently in this mode.
+	//
+	// Values are delivered in arbitrary order from concurrently running
+	// workers by default.
+	Ordered bool
+
+	// Pool is used for obtaining Parsers for workers.
+	//
+	// A package-level pool is used by default.
+	Pool *ParserPool
+}
+
+const defaultParallelChunkSize = 64 * 1024
+
+var parallelParserPool ParserPool
+
+// ProcessBytes calls f for each JSON line in b.
+//
+// f may be called from concurrently running goroutines unless Ordered
+// is set. f cannot hold v after returning.
+//
+// Processing stops on the first invalid line or on the first error
+// returned from f. The error is returned from ProcessBytes.
+// *ParseError is returned for invalid line.
+func (ps *ParallelScanner) ProcessBytes(b []byte, f func(v *Value) error) error {
+	chunkSize := ps.chunkSize()
+	return ps.process(f, func(emit func(chunk []byte, release func()) bool) error {
+		for len(b) > 0 {
+			n := len(b)
+			if n > chunkSize {
+				m := bytes.IndexByte(b[chunkSize:], '\n')
+				if m >= 0 {
+					n = chunkSize + m + 1
+				}
+			}
+			if !emit(b[:n], nil) {
+				return nil
+			}
+			b = b[n:]
+		}
+		return nil
+	})
+}
+
+// ProcessReader calls f for each JSON line read from r.
+//
+// Lines may be longer than ChunkSize.
+//
+// f may be called from concurrently running goroutines unless Ordered
+// is set. f cannot hold v after returning.
+//
+// Processing stops on the first invalid line, on the first error returned
+// from f or on read error. The error is returned from ProcessReader.
+// *ParseError is returned for invalid line.
+func (ps *ParallelScanner) ProcessReader(r io.Reader, f func(v *Value) error) error {
+	chunkSize := ps.chunkSize()
+	var bufPool sync.Pool
+	return ps.process(f, func(emit func(chunk []byte, release func()) bool) error {
+		var tail []byte
+		eof := false
+		for !eof {
+			var buf *[]byte
+			if v := bufPool.Get(); v != nil {
+				buf = v.(*[]byte)
+			} else {
+				buf = new([]byte)
+			}
+			chunk := append((*buf)[:0], tail...)
+			tail = tail[:0]
+
+			// Read at least chunkSize bytes up to the end of the line.
+			hasNewline := false
+			for !eof && (len(chunk) < chunkSize || !hasNewline) {
+				if free := cap(chunk) - len(chunk); free == 0 || free < chunkSize/2 {
+					b := make([]byte, len(chunk), 2*cap(chunk)+chunkSize)
+					copy(b, chunk)
+					chunk = b
+				}
+				n, err := r.Read(chunk[len(chunk):cap(chunk)])
+				if bytes.IndexByte(chunk[len(chunk):len(chunk)+n], '\n') >= 0 {
+					hasNewline = true
+				}
+				chunk = chunk[:len(chunk)+n]
+				if err == io.EOF {
+					eof = true
+				} else if err != nil {
+					return err
+				}
+			}
+			if !eof {
+				n := bytes.LastIndexByte(chunk, '\n') + 1
+				tail = append(tail, chunk[n:]...)
+				chunk = chunk[:n]
+			}
+			*buf = chunk
+			release := func() {
+				bufPool.Put(buf)
+			}
+			if !emit(chunk, release) {
+				return nil
+			}
+		}
+		return nil
+	})
+}
+
+func (ps *ParallelScanner) chunkSize() int {
+	if ps.ChunkSize <= 0 {
+		return defaultParallelChunkSize
+	}
+	return ps.ChunkSize
+}
+
+type parallelChunk struct {
+	b       []byte
+	release func()
+	offset  int
+	seq     int
+}
+
+// parallelState is shared between workers during a single process call.
+type parallelState struct {
+	mu   sync.Mutex
+	cond sync.Cond
+
+	// nextSeq is the sequence number of the chunk to be delivered next
+	// in ordered mode.
+	nextSeq int
+
+	err error
+}
+
+func (st *parallelState) setError(err error) {
+	st.mu.Lock()
+	if st.err == nil {
+		st.err = err
+	}
+	st.mu.Unlock()
+	st.cond.Broadcast()
+}
+
+func (st *parallelState) stopped() bool {
+	st.mu.Lock()
+	stopped := st.err != nil
+	st.mu.Unlock()
+	return stopped
+}
+
+func (ps *ParallelScanner) process(f func(v *Value) error, feed func(emit func(chunk []byte, release func()) bool) error) error {
+	workers := ps.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	pp := ps.Pool
+	if pp == nil {
+		pp = &parallelParserPool
+	}
+
+	var st parallelState
+	st.cond.L = &st.mu
+	ch := make(chan parallelChunk, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var vs []*Value
+			for c := range ch {
+				vs = ps.processChunk(&st, pp, c, f, vs[:0])
+				if c.release != nil {
+					c.release()
+				}
+			}
+		}()
+	}
+
+	offset := 0
+	seq := 0
+	err := feed(func(chunk []byte, release func()) bool {
+		if st.stopped() {
+			if release != nil {
+				release()
+			}
+			return false
+		}
+		ch <- parallelChunk{
+			b:       chunk,
+			release: release,
+			offset:  offset,
+			seq:     seq,
+		}
+		offset += len(chunk)
+		seq++
+		return true
+	})
+	close(ch)
+	wg.Wait()
+	if err != nil {
+		return err
+	}
+	return st.err
+}
+
+// newLineParseError returns ParseError for err detected at the given tail
+// of the line located at [lineOffset, lineEnd) in the input.
+func (c *cache) newLineParseError(err error, tail string, lineOffset, lineEnd int) *ParseError {
+	pe := c.newParseError(err, tail)
+	pe.Offset = lineEnd - len(tail)
+	pe.msg = fmt.Sprintf("cannot parse line at offset %d: %s", lineOffset, pe.msg)
+	return pe
+}
+
+func (ps *ParallelScanner) processChunk(st *parallelState, pp *ParserPool, c parallelChunk, f func(v *Value) error, vs []*Value) []*Value {
+	p := pp.Get()
+	defer pp.Put(p)
+
+	// Parse all the lines in the chunk into a single cache,
+	// so the values remain valid until the chunk is delivered.
+	// Parser settings are reset explicitly, since the pooled Parser
+	// may be left with arbitrary settings by the previous user.
+	p.b = append(p.b[:0], c.b...)
+	p.c.reset()
+	p.c.duplicateKeys = DuplicateKeysAllow
+	p.c.invalidUTF8 = InvalidUTF8Allow
+	p.c.positions = false
+	p.c.limits = Limits{}
+	s := b2s(p.b)
+	var err error
+	for len(s) > 0 {
+		n := len(s)
+		line := s
+		if m := strings.IndexByte(s, '\n'); m >= 0 {
+			n = m + 1
+			line = s[:m]
+		}
+		lineOffset := c.offset + len(p.b) - len(s)
+		lineEnd := lineOffset + len(line)
+		if line = skipWS(line); len(line) > 0 {
+			var v *Value
+			var tail string
+			v, tail, err = parseValue(line, &p.c)
+			if err == nil && len(skipWS(tail)) > 0 {
+				tail = skipWS(tail)
+				err = errUnexpectedTail
+			}
+			if err != nil {
+				err = p.c.newLineParseError(err, tail, lineOffset, lineEnd)
+				break
+			}
+			vs = append(vs, v)
+		}
+		s = s[n:]
+	}
+
+	if ps.Ordered {
+		// Wait for the preceding chunks to be delivered.
+		st.mu.Lock()
+		for st.nextSeq != c.seq && st.err == nil {
+			st.cond.Wait()
+		}
+		stopped := st.err != nil
+		st.mu.Unlock()
+		if stopped {
+			return vs
+		}
+	} else if st.stopped() {
+		return vs
+	}
+
+	for _, v := range vs {
+		if ferr := f(v); ferr != nil {
+			st.setError(ferr)
+			return vs
+		}
+	}
+	if err != nil {
+		st.setError(err)
+		return vs
+	}
+
+	if ps.Ordered {
+		st.mu.Lock()
+		st.nextSeq++
+		st.mu.Unlock()
+		st.cond.Broadcast()
+	}
+	return vs
+}
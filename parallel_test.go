@@ -0,0 +1,198 @@
+package fastjson
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+)
+
+func newParallelTestInput(n int) string {
+	var bb bytes.Buffer
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&bb, `{"id":%d,"name":"item_%d","tags":["a","b"]}`+"\n", i, i)
+		if i%10 == 0 {
+			// Empty lines must be skipped.
+			bb.WriteString("\n  \n")
+		}
+	}
+	return bb.String()
+}
+
+func TestParallelScannerOrdered(t *testing.T) {
+	const itemsCount = 1000
+	s := newParallelTestInput(itemsCount)
+
+	for _, chunkSize := range []int{1, 100, 4096, 1 << 20} {
+		ps := &ParallelScanner{
+			Workers:   4,
+			ChunkSize: chunkSize,
+			Ordered:   true,
+		}
+		var ids []int
+		err := ps.ProcessBytes([]byte(s), func(v *Value) error {
+			ids = append(ids, v.GetInt("id"))
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error for chunkSize=%d: %s", chunkSize, err)
+		}
+		if len(ids) != itemsCount {
+			t.Fatalf("unexpected number of items for chunkSize=%d; got %d; want %d", chunkSize, len(ids), itemsCount)
+		}
+		for i, id := range ids {
+			if id != i {
+				t.Fatalf("unexpected item order for chunkSize=%d; got id=%d at position %d", chunkSize, id, i)
+			}
+		}
+
+		ids = ids[:0]
+		err = ps.ProcessReader(iotest.HalfReader(strings.NewReader(s)), func(v *Value) error {
+			ids = append(ids, v.GetInt("id"))
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error for chunkSize=%d: %s", chunkSize, err)
+		}
+		if len(ids) != itemsCount {
+			t.Fatalf("unexpected number of items read for chunkSize=%d; got %d; want %d", chunkSize, len(ids), itemsCount)
+		}
+		for i, id := range ids {
+			if id != i {
+				t.Fatalf("unexpected order of items read for chunkSize=%d; got id=%d at position %d", chunkSize, id, i)
+			}
+		}
+	}
+}
+
+func TestParallelScannerUnordered(t *testing.T) {
+	const itemsCount = 1000
+	s := newParallelTestInput(itemsCount)
+
+	ps := &ParallelScanner{
+		ChunkSize: 512,
+	}
+	var mu sync.Mutex
+	seen := make(map[int]bool)
+	err := ps.ProcessReader(strings.NewReader(s), func(v *Value) error {
+		id := v.GetInt("id")
+		if string(v.GetStringBytes("name")) != fmt.Sprintf("item_%d", id) {
+			return fmt.Errorf("unexpected value: %s", v)
+		}
+		mu.Lock()
+		seen[id] = true
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(seen) != itemsCount {
+		t.Fatalf("unexpected number of distinct items; got %d; want %d", len(seen), itemsCount)
+	}
+}
+
+func TestParallelScannerError(t *testing.T) {
+	s := newParallelTestInput(100) + "{\"id\": bad}\n" + newParallelTestInput(100)
+	ps := &ParallelScanner{
+		Workers:   3,
+		ChunkSize: 64,
+		Ordered:   true,
+	}
+	n := 0
+	err := ps.ProcessBytes([]byte(s), func(v *Value) error {
+		n++
+		return nil
+	})
+	if err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+	if !strings.Contains(err.Error(), fmt.Sprintf("at offset %d", strings.Index(s, "{\"id\": bad}"))) {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	pe, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("unexpected error type; got %T; want *ParseError", err)
+	}
+	if expectedOffset := strings.Index(s, "bad}"); pe.Offset != expectedOffset {
+		t.Fatalf("unexpected error offset; got %d; want %d", pe.Offset, expectedOffset)
+	}
+	if n != 100 {
+		t.Fatalf("unexpected number of values delivered before the error; got %d; want %d", n, 100)
+	}
+
+	// Error from callback.
+	n = 0
+	err = ps.ProcessReader(strings.NewReader(newParallelTestInput(100)), func(v *Value) error {
+		n++
+		if n == 10 {
+			return fmt.Errorf("callback error")
+		}
+		return nil
+	})
+	if err == nil || err.Error() != "callback error" {
+		t.Fatalf("unexpected error; got %v; want %q", err, "callback error")
+	}
+	if n != 10 {
+		t.Fatalf("unexpected number of callback calls; got %d; want %d", n, 10)
+	}
+
+	// Multiple values on a line.
+	err = ps.ProcessBytes([]byte("1\n2 3\n"), func(v *Value) error {
+		return nil
+	})
+	if err == nil {
+		t.Fatalf("expecting non-nil error for multiple values on a line")
+	}
+}
+
+func TestParallelScannerPoolSettings(t *testing.T) {
+	// Settings left in pooled parsers mustn't affect ParallelScanner.
+	var pp ParserPool
+	p := pp.Get()
+	p.DuplicateKeys = DuplicateKeysReject
+	p.InvalidUTF8 = InvalidUTF8Reject
+	p.Limits = Limits{MaxValues: 1}
+	if _, err := p.Parse(`{"a":1,"a":2}`); err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+	pp.Put(p)
+
+	ps := &ParallelScanner{
+		Workers: 1,
+		Pool:    &pp,
+	}
+	n := 0
+	err := ps.ProcessBytes([]byte("{\"a\":1,\"a\":[2,3]}\n\"\xff\"\n"), func(v *Value) error {
+		n++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if n != 2 {
+		t.Fatalf("unexpected number of values; got %d; want %d", n, 2)
+	}
+}
+
+func TestParallelScannerLongLines(t *testing.T) {
+	long := strings.Repeat("x", 10000)
+	s := fmt.Sprintf("\"%s\"\n[1]\n\"%s\"", long, long)
+	ps := &ParallelScanner{
+		ChunkSize: 16,
+		Ordered:   true,
+	}
+	var lens []int
+	err := ps.ProcessReader(iotest.OneByteReader(strings.NewReader(s)), func(v *Value) error {
+		lens = append(lens, len(v.String()))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if fmt.Sprint(lens) != "[10002 3 10002]" {
+		t.Fatalf("unexpected values; got %v; want [10002 3 10002]", lens)
+	}
+}
@@ -1,11 +1,14 @@
 package fastjson
 
 import (
-	"bytes"
+	"errors"
 	"fmt"
+	"math"
 	"reflect"
 	"strconv"
 	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
 	"unsafe"
 )
 
@@ -13,33 +16,95 @@ import (
 //
 // Parser may be re-used for subsequent parsing.
 //
+// Nested arrays and objects are parsed without recursion, so the nesting
+// depth isn't limited by the goroutine stack size. This applies to relaxed
+// mode and to Value.MarshalTo, Value.String and Value.Freeze as well.
+//
 // Parser cannot be used from concurrent goroutines.
 // Use per-goroutine parsers or ParserPool instead.
 type Parser struct {
+	// Relaxed enables relaxed parsing mode, which accepts JSON5
+	// ( https://json5.org/ ) in addition to JSON. This includes
+	// comments, trailing commas, single-quoted and multi-line strings,
+	// unquoted object keys, hex numbers, Infinity and NaN.
+	//
+	// Relaxed mode is slower than the default mode,
+	// so enable it only for hand-edited inputs such as configs.
+	Relaxed bool
+
+	// DuplicateKeys is the policy for duplicate keys in JSON objects.
+	//
+	// DuplicateKeysAllow is used by default.
+	DuplicateKeys DuplicateKeys
+
+	// InvalidUTF8 is the policy for invalid UTF-8 in strings and object keys.
+	//
+	// InvalidUTF8Allow is used by default.
+	InvalidUTF8 InvalidUTF8
+
+	// RecordPositions enables recording start and end offsets of the parsed
+	// values and offsets of object keys.
+	// See Value.Offset, Value.Raw, Object.KeyOffset and Parser.LineColumn
+	// for details.
+	//
+	// Parsing is slightly slower when positions are recorded. Escaped strings
+	// and object keys are unescaped into new memory instead of in place,
+	// so Value.Raw returns the original text.
+	RecordPositions bool
+
+	// Limits limits resources used for parsing untrusted JSON.
+	//
+	// There are no limits by default.
+	Limits Limits
+
 	// b contains working copy of the string to be parsed.
 	b []byte
 
 	// c is a cache for json values.
 	c cache
+
+	// lines contains offsets of line starts in the parsed input.
+	// It is filled by LineColumn.
+	lines []int
+
+	// linesReady is set when lines are filled for the parsed input.
+	linesReady bool
 }
 
 // Parse parses s containing JSON.
 //
 // The returned value is valid until the next call to Parse*.
 //
+// *ParseError is returned on invalid JSON.
+//
 // Use Scanner if a stream of JSON values must be parsed.
 func (p *Parser) Parse(s string) (*Value, error) {
-	s = skipWS(s)
+	p.c.duplicateKeys = p.DuplicateKeys
+	p.c.invalidUTF8 = p.InvalidUTF8
+	p.c.positions = p.RecordPositions
+	p.c.limits = p.Limits
+	p.c.inputLen = len(s)
+	if p.Limits.MaxInputSize > 0 && len(s) > p.Limits.MaxInputSize {
+		return nil, newInputSizeError(p.Limits.MaxInputSize)
+	}
 	p.b = append(p.b[:0], s...)
 	p.c.reset()
+	p.linesReady = false
+	p.c.input = ""
+	if p.c.positions {
+		p.c.input = b2s(p.b)
+	}
+	if p.Relaxed {
+		return p.parseRelaxed()
+	}
 
-	v, tail, err := parseValue(b2s(p.b), &p.c)
+	v, tail, err := parseValue(skipWS(b2s(p.b)), &p.c)
 	if err != nil {
-		return nil, fmt.Errorf("cannot parse JSON: %s; unparsed tail: %q", err, tail)
+		return nil, p.c.newParseError(err, tail)
 	}
 	tail = skipWS(tail)
 	if len(tail) > 0 {
-		return nil, fmt.Errorf("unexpected tail: %q", tail)
+		return nil, p.c.newParseError(errUnexpectedTail, tail)
 	}
 	return v, nil
 }
@@ -54,21 +119,108 @@ func (p *Parser) ParseBytes(b []byte) (*Value, error) {
 }
 
 type cache struct {
-	vs []Value
+	// slabs contain values for the parsed JSON.
+	//
+	// Values are allocated from fixed-size slabs, so the cache growth
+	// doesn't copy the already allocated values and doesn't leave behind
+	// the old backing arrays referenced by these values.
+	// Slabs are re-used across parses.
+	slabs []*valueSlab
+
+	// n is the number of values allocated from slabs.
+	n int
+
+	// duplicateKeys is the policy for duplicate object keys.
+	duplicateKeys DuplicateKeys
+
+	// keys indexes keys of big objects for duplicate keys detection.
+	keys map[objectKey]int
+
+	// maxKeys is the maximum number of entries in keys since its creation.
+	// keys doesn't shrink when cleared, so the memory retained by it
+	// is proportional to maxKeys.
+	maxKeys int
+
+	// invalidUTF8 is the policy for invalid UTF-8 in strings and keys.
+	invalidUTF8 InvalidUTF8
+
+	// positions enables recording positions of the parsed values.
+	positions bool
+
+	// inputLen is the length of the parsed input.
+	// It is used for calculating value positions.
+	inputLen int
+
+	// input is the parsed input. It is set only if positions are recorded.
+	// It isn't modified during parsing, since strings are unescaped
+	// into new memory in this case.
+	input string
+
+	// limits limits resources used for parsing.
+	limits Limits
+
+	// values is the number of parsed values if Limits.MaxValues is set.
+	values int
+
+	// limitErr is set when a limit is exceeded.
+	limitErr *LimitError
+
+	// stack is the stack of arrays and objects being parsed.
+	stack []parseFrame
+
+	// posSlabs contain positions of the values from slabs with the same
+	// indexes if positions are recorded.
+	posSlabs []*slabPositions
+
+	// retained is the capacity in bytes of arrays, objects and key positions
+	// retained by the values from slabs plus the estimated size of keys.
+	// It is updated when they grow, so MemoryUsage doesn't need to visit
+	// all the values.
+	retained int
+}
+
+// appendItem appends v to array a.
+func (c *cache) appendItem(a, v *Value) {
+	n := cap(a.a)
+	a.a = append(a.a, v)
+	c.retained += (cap(a.a) - n) * ptrSize
 }
 
+// getKV returns new kv from o.
+func (c *cache) getKV(o *Object) *kv {
+	n := cap(o.kvs)
+	kv := o.getKV()
+	c.retained += (cap(o.kvs) - n) * kvSize
+	return kv
+}
+
+// valuesPerSlab is the number of values in a single cache slab.
+const valuesPerSlab = 64
+
+type valueSlab [valuesPerSlab]Value
+
 func (c *cache) reset() {
-	c.vs = c.vs[:0]
+	c.n = 0
+	c.values = 0
+	c.limitErr = nil
+	for k := range c.keys {
+		delete(c.keys, k)
+	}
 }
 
 func (c *cache) getValue() *Value {
-	if cap(c.vs) > len(c.vs) {
-		c.vs = c.vs[:len(c.vs)+1]
-	} else {
-		c.vs = append(c.vs, Value{})
+	n := uint(c.n)
+	i := n / valuesPerSlab
+	if i == uint(len(c.slabs)) {
+		c.slabs = append(c.slabs, &valueSlab{})
 	}
-	v := &c.vs[len(c.vs)-1]
+	v := &c.slabs[i][n%valuesPerSlab]
+	c.n++
 	v.reset()
+	v.o.pos = nil
+	if c.positions {
+		v.o.pos = c.initPos(int(n))
+	}
 	return v
 }
 
@@ -109,160 +261,279 @@ type kv struct {
 	v *Value
 }
 
-func parseValue(s string, c *cache) (*Value, string, error) {
-	if len(s) == 0 {
-		return nil, s, fmt.Errorf("cannot parse empty string")
-	}
+// parseFrame is an array or object being parsed by parseValue.
+type parseFrame struct {
+	// v is the array or object.
+	v *Value
 
+	// start is the input starting at v. It is used for recording positions.
+	start string
+
+	// dup is the index of the previous object item with the same key
+	// as the last item. It is -1 if the last key isn't duplicate.
+	dup int
+}
+
+// parseValue parses JSON value at the start of s.
+//
+// Nested arrays and objects are parsed iteratively with the explicit stack
+// stored in c, so the nesting depth isn't limited by the goroutine stack.
+func parseValue(s string, c *cache) (*Value, string, error) {
+	stack := c.stack[:0]
 	var v *Value
 	var err error
 
-	switch s[0] {
-	case '{':
-		v, s, err = parseObject(s, c)
-		if err != nil {
-			return nil, s, fmt.Errorf("cannot parse object: %s", err)
+parseNext:
+	for {
+		if len(s) == 0 {
+			return c.parseError(stack, "", s, fmt.Errorf("cannot parse empty string"))
 		}
-		return v, s, nil
-	case '[':
-		v, s, err = parseArray(s, c)
-		if err != nil {
-			return nil, s, fmt.Errorf("cannot parse array: %s", err)
+		if c.limits.MaxValues > 0 {
+			if err = c.checkValue(); err != nil {
+				return c.parseError(stack, "", s, err)
+			}
 		}
-		return v, s, nil
-	case '"':
-		var ss string
-		ss, s, err = parseRawString(s)
-		if err != nil {
-			return nil, s, fmt.Errorf("cannot parse string: %s", err)
+
+		start := s
+		switch s[0] {
+		case '{':
+			s = skipWS(s[1:])
+			if len(s) == 0 {
+				return c.parseError(stack, "object", s, fmt.Errorf("missing '}'"))
+			}
+			if s[0] == '}' {
+				v = emptyObject
+				s = s[1:]
+				break
+			}
+			o := c.getValue()
+			o.t = TypeObject
+			stack = append(stack, parseFrame{
+				v:     o,
+				start: start,
+			})
+			if s, err = c.parseKey(&stack[len(stack)-1], s); err != nil {
+				return c.frameError(stack, s, err)
+			}
+			continue
+		case '[':
+			s = skipWS(s[1:])
+			if len(s) == 0 {
+				return c.parseError(stack, "array", s, fmt.Errorf("missing ']'"))
+			}
+			if s[0] == ']' {
+				v = emptyArray
+				s = s[1:]
+				break
+			}
+			a := c.getValue()
+			a.t = TypeArray
+			stack = append(stack, parseFrame{
+				v:     a,
+				start: start,
+			})
+			continue
+		case '"':
+			var ss string
+			ss, s, err = parseRawString(s)
+			if err != nil {
+				return c.parseError(stack, "", s, fmt.Errorf("cannot parse string: %s", err))
+			}
+			if c.limits.MaxStringLength > 0 {
+				if err = c.checkString(ss); err != nil {
+					return c.parseError(stack, "", start, fmt.Errorf("cannot parse string: %s", err))
+				}
+			}
+			if ss, err = c.checkUTF8(ss); err != nil {
+				return c.parseError(stack, "", start, fmt.Errorf("cannot parse string: %s", err))
+			}
+			v = c.getValue()
+			v.t = typeRawString
+			v.s = ss
+		case 't':
+			if !strings.HasPrefix(s, "true") {
+				return c.parseError(stack, "", s, fmt.Errorf("unexpected value found: %q", s))
+			}
+			s = s[len("true"):]
+			v = valueTrue
+		case 'f':
+			if !strings.HasPrefix(s, "false") {
+				return c.parseError(stack, "", s, fmt.Errorf("unexpected value found: %q", s))
+			}
+			s = s[len("false"):]
+			v = valueFalse
+		case 'n':
+			if !strings.HasPrefix(s, "null") {
+				return c.parseError(stack, "", s, fmt.Errorf("unexpected value found: %q", s))
+			}
+			s = s[len("null"):]
+			v = valueNull
+		default:
+			var ns string
+			ns, s, err = parseRawNumber(s)
+			if err != nil {
+				return c.parseError(stack, "", s, fmt.Errorf("cannot parse number: %s", err))
+			}
+			v = c.getValue()
+			v.t = typeRawNumber
+			v.s = ns
 		}
-		v = c.getValue()
-		v.t = typeRawString
-		v.s = ss
-		return v, s, nil
-	case 't':
-		if !strings.HasPrefix(s, "true") {
-			return nil, s, fmt.Errorf("unexpected value found: %q", s)
-		}
-		s = s[len("true"):]
-		return valueTrue, s, nil
-	case 'f':
-		if !strings.HasPrefix(s, "false") {
-			return nil, s, fmt.Errorf("unexpected value found: %q", s)
-		}
-		s = s[len("false"):]
-		return valueFalse, s, nil
-	case 'n':
-		if !strings.HasPrefix(s, "null") {
-			return nil, s, fmt.Errorf("unexpected value found: %q", s)
-		}
-		s = s[len("null"):]
-		return valueNull, s, nil
-	default:
-		var ns string
-		ns, s, err = parseRawNumber(s)
-		if err != nil {
-			return nil, s, fmt.Errorf("cannot parse number: %s", err)
+		if c.positions {
+			v = c.setPos(v, start, s)
 		}
-		v = c.getValue()
-		v.t = typeRawNumber
-		v.s = ns
+
+		// Add v to the parent arrays and objects, which end after v.
+		for len(stack) > 0 {
+			f := &stack[len(stack)-1]
+			if f.v.t == TypeArray {
+				c.appendItem(f.v, v)
+				if c.limits.MaxElements > 0 {
+					if err = c.checkElements(len(f.v.a)); err != nil {
+						return c.frameError(stack, s, err)
+					}
+				}
+				s = skipWS(s)
+				if len(s) == 0 {
+					return c.frameError(stack, s, fmt.Errorf("unexpected end of array"))
+				}
+				if s[0] == ',' {
+					s = skipWS(s[1:])
+					continue parseNext
+				}
+				if s[0] != ']' {
+					return c.frameError(stack, s, fmt.Errorf("missing ',' after array value"))
+				}
+			} else {
+				o := &f.v.o
+				o.kvs[len(o.kvs)-1].v = v
+				if f.dup >= 0 {
+					c.dropDuplicateKey(o, f.dup)
+				}
+				s = skipWS(s)
+				if len(s) == 0 {
+					return c.frameError(stack, s, fmt.Errorf("unexpected end of object"))
+				}
+				if s[0] == ',' {
+					if s, err = c.parseKey(f, s[1:]); err != nil {
+						return c.frameError(stack, s, err)
+					}
+					continue parseNext
+				}
+				if s[0] != '}' {
+					return c.frameError(stack, s, fmt.Errorf("missing ',' after object value"))
+				}
+				if c.duplicateKeys != DuplicateKeysAllow || c.positions {
+					// Keys are unescaped during parsing.
+					o.keysUnescaped = true
+				}
+			}
+			s = s[1:]
+			v = f.v
+			if c.positions {
+				v = c.setPos(v, f.start, s)
+			}
+			stack = stack[:len(stack)-1]
+		}
+		c.stack = stack
 		return v, s, nil
 	}
 }
 
-func parseArray(s string, c *cache) (*Value, string, error) {
-	// Skip the first char - '['
-	s = s[1:]
+// parseKey parses the next object key at s for the object in f.
+//
+// It returns the tail starting at the value for the key.
+func (c *cache) parseKey(f *parseFrame, s string) (string, error) {
+	var err error
+	o := &f.v.o
+	kv := c.getKV(o)
 
 	s = skipWS(s)
-	if len(s) == 0 {
-		return nil, s, fmt.Errorf("missing ']'")
+	keyStart := s
+	if c.limits.MaxElements > 0 {
+		if err = c.checkElements(len(o.kvs)); err != nil {
+			return s, err
+		}
 	}
-
-	if s[0] == ']' {
-		return emptyArray, s[1:], nil
+	kv.k, s, err = parseRawString(s)
+	if err != nil {
+		return s, fmt.Errorf("cannot parse object key: %s", err)
 	}
-
-	a := c.getValue()
-	a.t = TypeArray
-	for {
-		var v *Value
-		var err error
-
-		s = skipWS(s)
-		v, s, err = parseValue(s, c)
-		if err != nil {
-			return nil, s, fmt.Errorf("cannot parse array value: %s", err)
+	if c.limits.MaxStringLength > 0 {
+		if err = c.checkString(kv.k); err != nil {
+			return keyStart, fmt.Errorf("cannot parse object key: %s", err)
 		}
-		a.a = append(a.a, v)
-
-		s = skipWS(s)
-		if len(s) == 0 {
-			return nil, s, fmt.Errorf("unexpected end of array")
-		}
-		if s[0] == ',' {
-			s = s[1:]
-			continue
-		}
-		if s[0] == ']' {
-			s = s[1:]
-			return a, s, nil
+	}
+	if kv.k, err = c.checkUTF8(kv.k); err != nil {
+		return keyStart, fmt.Errorf("cannot parse object key: %s", err)
+	}
+	if c.positions {
+		// Keys are unescaped during parsing, so Object.KeyOffset
+		// may compare them.
+		kv.k = unescapeStringBestEffortCopy(kv.k)
+		c.setKeyPos(o, kv.k, keyStart)
+	} else if c.duplicateKeys != DuplicateKeysAllow {
+		kv.k = unescapeStringBestEffort(kv.k)
+	}
+	f.dup = -1
+	if c.duplicateKeys != DuplicateKeysAllow {
+		f.dup = c.duplicateKey(o)
+		if f.dup >= 0 && c.duplicateKeys == DuplicateKeysReject {
+			return keyStart, fmt.Errorf("duplicate key %q", kv.k)
 		}
-		return nil, s, fmt.Errorf("missing ',' after array value")
 	}
-}
-
-func parseObject(s string, c *cache) (*Value, string, error) {
-	// Skip the first char - '{'
-	s = s[1:]
-
 	s = skipWS(s)
-	if len(s) == 0 {
-		return nil, s, fmt.Errorf("missing '}'")
+	if len(s) == 0 || s[0] != ':' {
+		return s, fmt.Errorf("missing ':' after object key")
 	}
+	return skipWS(s[1:]), nil
+}
 
-	if s[0] == '}' {
-		return emptyObject, s[1:], nil
+// frameError returns err occurred in the innermost array or object
+// from stack.
+func (c *cache) frameError(stack []parseFrame, tail string, err error) (*Value, string, error) {
+	container := "array"
+	if stack[len(stack)-1].v.t == TypeObject {
+		container = "object"
 	}
+	return c.parseError(stack[:len(stack)-1], container, tail, err)
+}
 
-	o := c.getValue()
-	o.t = TypeObject
-	for {
-		var err error
-		kv := o.o.getKV()
-
-		// Parse key.
-		s = skipWS(s)
-		kv.k, s, err = parseRawString(s)
-		if err != nil {
-			return nil, s, fmt.Errorf("cannot parse object key: %s", err)
-		}
-		s = skipWS(s)
-		if len(s) == 0 || s[0] != ':' {
-			return nil, s, fmt.Errorf("missing ':' after object key")
+// parseError returns err occurred inside the arrays and objects from stack.
+//
+// err is occurred in the given container if it isn't empty.
+//
+// The error message contains the path to the error in the same way
+// as recursive parsing would produce, e.g. "cannot parse array:
+// cannot parse array value: cannot parse object: missing '}'".
+func (c *cache) parseError(stack []parseFrame, container, tail string, err error) (*Value, string, error) {
+	var b []byte
+	for i := range stack {
+		if stack[i].v.t == TypeObject {
+			b = append(b, "cannot parse object: cannot parse object value: "...)
+		} else {
+			b = append(b, "cannot parse array: cannot parse array value: "...)
 		}
-		s = s[1:]
+	}
+	if len(container) > 0 {
+		b = append(b, "cannot parse "...)
+		b = append(b, container...)
+		b = append(b, ": "...)
+	}
+	if len(b) > 0 {
+		err = errors.New(string(append(b, err.Error()...)))
+	}
+	c.stack = stack[:0]
+	return nil, tail, err
+}
 
-		// Parse value
-		s = skipWS(s)
-		kv.v, s, err = parseValue(s, c)
-		if err != nil {
-			return nil, s, fmt.Errorf("cannot parse object value: %s", err)
-		}
-		s = skipWS(s)
-		if len(s) == 0 {
-			return nil, s, fmt.Errorf("unexpected end of object")
-		}
-		if s[0] == ',' {
-			s = s[1:]
-			continue
-		}
-		if s[0] == '}' {
-			return o, s[1:], nil
-		}
-		return nil, s, fmt.Errorf("missing ',' after object value")
+// unescapeStringBestEffortCopy is like unescapeStringBestEffort,
+// but it doesn't modify s.
+func unescapeStringBestEffortCopy(s string) string {
+	if strings.IndexByte(s, '\\') < 0 {
+		return s
 	}
+	b := append([]byte(nil), s...)
+	return unescapeStringBestEffort(b2s(b))
 }
 
 func unescapeStringBestEffort(s string) string {
@@ -309,8 +580,19 @@ func unescapeStringBestEffort(s string) string {
 				b = append(b, '\\', ch)
 				break
 			}
-			b = append(b, string(rune(x))...)
 			s = s[4:]
+			if utf16.IsSurrogate(rune(x)) && len(s) >= 6 && s[0] == '\\' && s[1] == 'u' {
+				// Decode UTF-16 surrogate pair.
+				x2, err := strconv.ParseUint(s[2:6], 16, 16)
+				if err == nil {
+					if r := utf16.DecodeRune(rune(x), rune(x2)); r != utf8.RuneError {
+						b = append(b, string(r)...)
+						s = s[6:]
+						break
+					}
+				}
+			}
+			b = append(b, string(rune(x))...)
 		default:
 			// Unknown escape sequence. Just store it unchanged.
 			b = append(b, '\\', ch)
@@ -384,11 +666,17 @@ func parseRawNumber(s string) (string, string, error) {
 
 // Object represents JSON object.
 //
-// Object cannot be used from concurrent goroutines.
+// Object cannot be used from concurrent goroutines
+// unless it is obtained from a frozen Value. See Value.Freeze for details.
 // Use per-goroutine parsers or ParserPool instead.
 type Object struct {
 	kvs           []kv
 	keysUnescaped bool
+
+	// pos is the position of the Value containing the object. It is nil
+	// if positions aren't recorded. It is stored in Object instead of Value,
+	// so it is accessible from both Value and Object methods.
+	pos *valuePos
 }
 
 func (o *Object) reset() {
@@ -401,19 +689,11 @@ func (o *Object) reset() {
 // This function is for debugging purposes only. It isn't optimized for speed.
 func (o *Object) String() string {
 	o.unescapeKeys()
-
-	// Use bytes.Buffer instead of strings.Builder,
-	// so it works on go 1.9 and below.
-	var bb bytes.Buffer
-	bb.WriteString("{")
-	for i, kv := range o.kvs {
-		fmt.Fprintf(&bb, "%q:%s", kv.k, kv.v)
-		if i != len(o.kvs)-1 {
-			bb.WriteString(",")
-		}
+	v := Value{
+		o: *o,
+		t: TypeObject,
 	}
-	bb.WriteString("}")
-	return bb.String()
+	return v.String()
 }
 
 func (o *Object) getKV() *kv {
@@ -443,6 +723,9 @@ func (o *Object) Len() int {
 
 // Get returns the value for the given key in the o.
 //
+// The value for the first matching key is returned if the o contains
+// duplicate keys. See Parser.DuplicateKeys for other options.
+//
 // Returns nil if the value for the given key isn't found.
 //
 // The returned value is valid until Parse is called on the Parser returned o.
@@ -476,8 +759,8 @@ func (o *Object) Visit(f func(key []byte, v *Value)) {
 //
 // Call Type in order to determine the actual type of the JSON value.
 //
-// Value cannot be used from concurrent goroutines.
-// Use per-goroutine parsers or ParserPool instead.
+// Value cannot be used from concurrent goroutines unless it is frozen
+// with Freeze. Use per-goroutine parsers or ParserPool instead.
 type Value struct {
 	o Object
 	a []*Value
@@ -501,40 +784,240 @@ func (v *Value) reset() {
 // Don't confuse this function with StringBytes, which must be called
 // for obtaining the underlying JSON string for the v.
 func (v *Value) String() string {
-	switch v.Type() {
-	case TypeObject:
-		return v.o.String()
-	case TypeArray:
-		// Use bytes.Buffer instead of strings.Builder,
-		// so it works on go 1.9 and below.
-		var bb bytes.Buffer
-		bb.WriteString("[")
-		for i, vv := range v.a {
-			fmt.Fprintf(&bb, "%s", vv)
-			if i != len(v.a)-1 {
-				bb.WriteString(",")
+	return string(v.appendTo(nil, 0, true))
+}
+
+// MarshalTo appends marshaled JSON representation of the v to dst
+// and returns the result.
+//
+// Numbers are marshaled in the form they had in the parsed JSON.
+// Infinity and NaN are marshaled as null.
+func (v *Value) MarshalTo(dst []byte) []byte {
+	return v.appendTo(dst, 0, false)
+}
+
+func (v *Value) marshalTo(dst []byte, flags escapeFlags) []byte {
+	return v.appendTo(dst, flags, false)
+}
+
+// appendFrame is an array or object being appended by appendTo.
+type appendFrame struct {
+	// v is the array or object.
+	v *Value
+
+	// i is the index of the next item to append.
+	i int
+}
+
+// appendTo appends v to dst.
+//
+// Nested arrays and objects are appended iteratively with the explicit
+// stack, so the nesting depth isn't limited by the goroutine stack.
+//
+// Strings, numbers and object keys are appended in the String format
+// if debug is set. Otherwise they are appended as JSON escaped with flags.
+func (v *Value) appendTo(dst []byte, flags escapeFlags, debug bool) []byte {
+	var stackBuf [16]appendFrame
+	stack := stackBuf[:0]
+	for {
+		switch v.Type() {
+		case TypeObject:
+			v.o.unescapeKeys()
+			dst = append(dst, '{')
+			stack = append(stack, appendFrame{v: v})
+		case TypeArray:
+			dst = append(dst, '[')
+			stack = append(stack, appendFrame{v: v})
+		default:
+			dst = v.appendScalar(dst, flags, debug)
+		}
+
+		// Find the next value to append, closing the finished
+		// arrays and objects.
+		v = nil
+		for v == nil {
+			if len(stack) == 0 {
+				return dst
 			}
+			f := &stack[len(stack)-1]
+			if f.v.t == TypeObject {
+				if f.i < len(f.v.o.kvs) {
+					if f.i > 0 {
+						dst = append(dst, ',')
+					}
+					kv := &f.v.o.kvs[f.i]
+					if debug {
+						dst = strconv.AppendQuote(dst, kv.k)
+					} else {
+						dst = appendEscapedStringFlags(dst, kv.k, flags)
+					}
+					dst = append(dst, ':')
+					v = kv.v
+					f.i++
+					continue
+				}
+				dst = append(dst, '}')
+			} else {
+				if f.i < len(f.v.a) {
+					if f.i > 0 {
+						dst = append(dst, ',')
+					}
+					v = f.v.a[f.i]
+					f.i++
+					continue
+				}
+				dst = append(dst, ']')
+			}
+			stack = stack[:len(stack)-1]
 		}
-		bb.WriteString("]")
-		return bb.String()
+	}
+}
+
+// appendScalar appends v, which isn't array or object, to dst.
+//
+// See appendTo for details.
+func (v *Value) appendScalar(dst []byte, flags escapeFlags, debug bool) []byte {
+	switch v.Type() {
 	case TypeString:
-		return fmt.Sprintf("%q", v.s)
+		if debug {
+			return strconv.AppendQuote(dst, v.s)
+		}
+		return appendEscapedStringFlags(dst, v.s, flags)
 	case TypeNumber:
-		if float64(int(v.n)) == v.n {
-			return fmt.Sprintf("%d", int(v.n))
+		if debug {
+			if float64(int(v.n)) == v.n {
+				return strconv.AppendInt(dst, int64(int(v.n)), 10)
+			}
+			return strconv.AppendFloat(dst, v.n, 'f', 6, 64)
+		}
+		if len(v.s) > 0 {
+			// The parser accepts numbers such as 01, 1. or +1,
+			// which aren't valid JSON. Write them in canonical form.
+			if tail, err := validateNumber(v.s); err == nil && len(tail) == 0 {
+				return append(dst, v.s...)
+			}
 		}
-		return fmt.Sprintf("%f", v.n)
+		return appendFloat64(dst, v.n)
 	case TypeTrue:
-		return "true"
+		return append(dst, "true"...)
 	case TypeFalse:
-		return "false"
+		return append(dst, "false"...)
 	case TypeNull:
-		return "null"
+		return append(dst, "null"...)
 	default:
 		panic(fmt.Errorf("BUG: unknown Value type: %d", v.Type()))
 	}
 }
 
+// appendFloat64 appends f to dst as JSON number.
+//
+// Infinity and NaN are appended as null.
+func appendFloat64(dst []byte, f float64) []byte {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		// JSON has no representation for Infinity and NaN,
+		// which may be obtained in relaxed mode.
+		return append(dst, "null"...)
+	}
+	return strconv.AppendFloat(dst, f, 'g', -1, 64)
+}
+
+// escapeFlags control escaping of JSON strings.
+type escapeFlags uint8
+
+const (
+	// escapeHTML enables escaping of <, > and & chars
+	// and U+2028, U+2029 line terminators.
+	escapeHTML escapeFlags = 1 << iota
+
+	// escapeASCII enables escaping of non-ASCII chars.
+	escapeASCII
+)
+
+// appendEscapedString appends s to dst as a quoted JSON string.
+func appendEscapedString(dst []byte, s string) []byte {
+	dst = append(dst, '"')
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if ch >= 0x20 && ch != '"' && ch != '\\' {
+			continue
+		}
+		dst = append(dst, s[:i]...)
+		dst = appendEscapedChar(dst, ch)
+		s = s[i+1:]
+		i = -1
+	}
+	dst = append(dst, s...)
+	return append(dst, '"')
+}
+
+// appendEscapedStringFlags appends s to dst as a quoted JSON string
+// with additional escaping according to flags.
+//
+// Invalid UTF-8 sequences are replaced with U+FFFD if escapeASCII is set.
+func appendEscapedStringFlags(dst []byte, s string, flags escapeFlags) []byte {
+	if flags == 0 {
+		return appendEscapedString(dst, s)
+	}
+	dst = append(dst, '"')
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if ch >= 0x20 && ch != '"' && ch != '\\' && ch < utf8.RuneSelf && (flags&escapeHTML == 0 || (ch != '<' && ch != '>' && ch != '&')) {
+			continue
+		}
+		if ch < utf8.RuneSelf {
+			dst = append(dst, s[:i]...)
+			dst = appendEscapedChar(dst, ch)
+			s = s[i+1:]
+			i = -1
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if flags&escapeASCII == 0 && r != '\u2028' && r != '\u2029' {
+			i += size - 1
+			continue
+		}
+		dst = append(dst, s[:i]...)
+		if r >= 0x10000 {
+			r1, r2 := utf16.EncodeRune(r)
+			dst = appendEscapedRune(dst, r1)
+			dst = appendEscapedRune(dst, r2)
+		} else {
+			dst = appendEscapedRune(dst, r)
+		}
+		s = s[i+size:]
+		i = -1
+	}
+	dst = append(dst, s...)
+	return append(dst, '"')
+}
+
+func appendEscapedChar(dst []byte, ch byte) []byte {
+	switch ch {
+	case '"':
+		return append(dst, '\\', '"')
+	case '\\':
+		return append(dst, '\\', '\\')
+	case '\n':
+		return append(dst, '\\', 'n')
+	case '\r':
+		return append(dst, '\\', 'r')
+	case '\t':
+		return append(dst, '\\', 't')
+	case '\b':
+		return append(dst, '\\', 'b')
+	case '\f':
+		return append(dst, '\\', 'f')
+	default:
+		return appendEscapedRune(dst, rune(ch))
+	}
+}
+
+func appendEscapedRune(dst []byte, r rune) []byte {
+	return append(dst, '\\', 'u', hexChars[(r>>12)&0xf], hexChars[(r>>8)&0xf], hexChars[(r>>4)&0xf], hexChars[r&0xf])
+}
+
+const hexChars = "0123456789abcdef"
+
 // Type represents JSON type.
 type Type int
 
@@ -593,7 +1076,12 @@ func (t Type) String() string {
 func (v *Value) Type() Type {
 	switch v.t {
 	case typeRawString:
-		v.s = unescapeStringBestEffort(v.s)
+		if v.o.pos != nil {
+			// Leave the input unmodified for Value.Raw.
+			v.s = unescapeStringBestEffortCopy(v.s)
+		} else {
+			v.s = unescapeStringBestEffort(v.s)
+		}
 		v.t = TypeString
 	case typeRawNumber:
 		f, err := strconv.ParseFloat(v.s, 64)
@@ -606,6 +1094,31 @@ func (v *Value) Type() Type {
 	return v.t
 }
 
+// Freeze resolves all the lazily parsed strings, numbers and object keys
+// in v and its children.
+//
+// Values are parsed lazily on the first access, so even read-only access
+// to a Value modifies it. A frozen Value and all its children may be read
+// from concurrent goroutines until Parse is called on the Parser returned v.
+func (v *Value) Freeze() {
+	// Use the explicit stack instead of recursion, so the nesting depth
+	// isn't limited by the goroutine stack.
+	stack := []*Value{v}
+	for len(stack) > 0 {
+		v := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		switch v.Type() {
+		case TypeObject:
+			v.o.unescapeKeys()
+			for _, kv := range v.o.kvs {
+				stack = append(stack, kv.v)
+			}
+		case TypeArray:
+			stack = append(stack, v.a...)
+		}
+	}
+}
+
 // Exists returns true if the field exists for the given keys path.
 //
 // Array indexes may be represented as decimal numbers in keys.
@@ -841,10 +1354,12 @@ func (v *Value) InDepthSearch(keys ...string) ([]interface{}, error) {
 	return rValues, nil
 }
 
+// Values shared between all the parsers. They are never modified,
+// so they may be safely accessed from concurrent goroutines.
 var (
 	valueTrue   = &Value{t: TypeTrue}
 	valueFalse  = &Value{t: TypeFalse}
 	valueNull   = &Value{t: TypeNull}
-	emptyObject = &Value{t: TypeObject}
+	emptyObject = &Value{t: TypeObject, o: Object{keysUnescaped: true}}
 	emptyArray  = &Value{t: TypeArray}
 )
@@ -192,3 +192,26 @@ func ExampleValue_InDepthSearch() {
 	// Output:
 	// v.baz.foo = ["bar_0" "bar_1"]
 }
+
+func ExampleValue_Raw() {
+	p := &fastjson.Parser{
+		RecordPositions: true,
+	}
+	s := `{
+  "id": "x\ny",
+  "ext": {"a": [1, 2.50]}
+}`
+	v, err := p.Parse(s)
+	if err != nil {
+		log.Fatalf("cannot parse json: %s", err)
+	}
+
+	ext := v.Get("ext")
+	line, column := p.LineColumn(ext.Offset())
+	fmt.Printf("ext at %d:%d: %s\n", line, column, ext.Raw())
+	fmt.Printf("id: %s\n", v.Get("id").Raw())
+
+	// Output:
+	// ext at 3:10: {"a": [1, 2.50]}
+	// id: "x\ny"
+}
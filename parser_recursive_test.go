@@ -0,0 +1,735 @@
+package fastjson
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// This file contains the recursive descent implementations of parseValue
+// and parseValueRelaxed, which were used before the iterative ones.
+// They are kept for verifying that both implementations produce the same
+// results and for benchmarking.
+
+// parseRecursive is like p.Parse, but uses parseValueRecursive.
+func parseRecursive(p *Parser, s string) (*Value, error) {
+	p.c.duplicateKeys = p.DuplicateKeys
+	p.c.invalidUTF8 = p.InvalidUTF8
+	p.c.positions = p.RecordPositions
+	p.c.limits = p.Limits
+	p.c.inputLen = len(s)
+	if p.Limits.MaxInputSize > 0 && len(s) > p.Limits.MaxInputSize {
+		return nil, newInputSizeError(p.Limits.MaxInputSize)
+	}
+	p.b = append(p.b[:0], s...)
+	p.c.reset()
+	p.linesReady = false
+	p.c.input = ""
+	if p.c.positions {
+		p.c.input = b2s(p.b)
+	}
+
+	v, tail, err := parseValueRecursive(skipWS(b2s(p.b)), &p.c)
+	if err != nil {
+		return nil, p.c.newParseError(err, tail)
+	}
+	tail = skipWS(tail)
+	if len(tail) > 0 {
+		return nil, p.c.newParseError(errUnexpectedTail, tail)
+	}
+	return v, nil
+}
+
+func parseValueRecursive(s string, c *cache) (*Value, string, error) {
+	if len(s) == 0 {
+		return nil, s, fmt.Errorf("cannot parse empty string")
+	}
+	if c.limits.MaxValues > 0 {
+		if err := c.checkValue(); err != nil {
+			return nil, s, err
+		}
+	}
+
+	var v *Value
+	var err error
+	start := s
+
+	switch s[0] {
+	case '{':
+		v, s, err = parseObjectRecursive(s, c)
+		if err != nil {
+			return nil, s, fmt.Errorf("cannot parse object: %s", err)
+		}
+	case '[':
+		v, s, err = parseArrayRecursive(s, c)
+		if err != nil {
+			return nil, s, fmt.Errorf("cannot parse array: %s", err)
+		}
+	case '"':
+		var ss string
+		ss, s, err = parseRawString(s)
+		if err != nil {
+			return nil, s, fmt.Errorf("cannot parse string: %s", err)
+		}
+		if c.limits.MaxStringLength > 0 {
+			if err = c.checkString(ss); err != nil {
+				return nil, start, fmt.Errorf("cannot parse string: %s", err)
+			}
+		}
+		if ss, err = c.checkUTF8(ss); err != nil {
+			return nil, start, fmt.Errorf("cannot parse string: %s", err)
+		}
+		v = c.getValue()
+		v.t = typeRawString
+		v.s = ss
+	case 't':
+		if !strings.HasPrefix(s, "true") {
+			return nil, s, fmt.Errorf("unexpected value found: %q", s)
+		}
+		s = s[len("true"):]
+		v = valueTrue
+	case 'f':
+		if !strings.HasPrefix(s, "false") {
+			return nil, s, fmt.Errorf("unexpected value found: %q", s)
+		}
+		s = s[len("false"):]
+		v = valueFalse
+	case 'n':
+		if !strings.HasPrefix(s, "null") {
+			return nil, s, fmt.Errorf("unexpected value found: %q", s)
+		}
+		s = s[len("null"):]
+		v = valueNull
+	default:
+		var ns string
+		ns, s, err = parseRawNumber(s)
+		if err != nil {
+			return nil, s, fmt.Errorf("cannot parse number: %s", err)
+		}
+		v = c.getValue()
+		v.t = typeRawNumber
+		v.s = ns
+	}
+	if c.positions {
+		v = c.setPos(v, start, s)
+	}
+	return v, s, nil
+}
+
+func parseArrayRecursive(s string, c *cache) (*Value, string, error) {
+	// Skip the first char - '['
+	s = s[1:]
+
+	s = skipWS(s)
+	if len(s) == 0 {
+		return nil, s, fmt.Errorf("missing ']'")
+	}
+
+	if s[0] == ']' {
+		return emptyArray, s[1:], nil
+	}
+
+	a := c.getValue()
+	a.t = TypeArray
+	for {
+		var v *Value
+		var err error
+
+		s = skipWS(s)
+		v, s, err = parseValueRecursive(s, c)
+		if err != nil {
+			return nil, s, fmt.Errorf("cannot parse array value: %s", err)
+		}
+		c.appendItem(a, v)
+		if c.limits.MaxElements > 0 {
+			if err = c.checkElements(len(a.a)); err != nil {
+				return nil, s, err
+			}
+		}
+
+		s = skipWS(s)
+		if len(s) == 0 {
+			return nil, s, fmt.Errorf("unexpected end of array")
+		}
+		if s[0] == ',' {
+			s = s[1:]
+			continue
+		}
+		if s[0] == ']' {
+			s = s[1:]
+			return a, s, nil
+		}
+		return nil, s, fmt.Errorf("missing ',' after array value")
+	}
+}
+
+func parseObjectRecursive(s string, c *cache) (*Value, string, error) {
+	// Skip the first char - '{'
+	s = s[1:]
+
+	s = skipWS(s)
+	if len(s) == 0 {
+		return nil, s, fmt.Errorf("missing '}'")
+	}
+
+	if s[0] == '}' {
+		return emptyObject, s[1:], nil
+	}
+
+	o := c.getValue()
+	o.t = TypeObject
+	for {
+		var err error
+		kv := c.getKV(&o.o)
+
+		// Parse key.
+		s = skipWS(s)
+		keyStart := s
+		if c.limits.MaxElements > 0 {
+			if err = c.checkElements(len(o.o.kvs)); err != nil {
+				return nil, s, err
+			}
+		}
+		kv.k, s, err = parseRawString(s)
+		if err != nil {
+			return nil, s, fmt.Errorf("cannot parse object key: %s", err)
+		}
+		if c.limits.MaxStringLength > 0 {
+			if err = c.checkString(kv.k); err != nil {
+				return nil, keyStart, fmt.Errorf("cannot parse object key: %s", err)
+			}
+		}
+		if kv.k, err = c.checkUTF8(kv.k); err != nil {
+			return nil, keyStart, fmt.Errorf("cannot parse object key: %s", err)
+		}
+		if c.positions {
+			kv.k = unescapeStringBestEffortCopy(kv.k)
+			c.setKeyPos(&o.o, kv.k, keyStart)
+		} else if c.duplicateKeys != DuplicateKeysAllow {
+			kv.k = unescapeStringBestEffort(kv.k)
+		}
+		dup := -1
+		if c.duplicateKeys != DuplicateKeysAllow {
+			dup = c.duplicateKey(&o.o)
+			if dup >= 0 && c.duplicateKeys == DuplicateKeysReject {
+				return nil, keyStart, fmt.Errorf("duplicate key %q", kv.k)
+			}
+		}
+		s = skipWS(s)
+		if len(s) == 0 || s[0] != ':' {
+			return nil, s, fmt.Errorf("missing ':' after object key")
+		}
+		s = s[1:]
+
+		// Parse value
+		s = skipWS(s)
+		kv.v, s, err = parseValueRecursive(s, c)
+		if err != nil {
+			return nil, s, fmt.Errorf("cannot parse object value: %s", err)
+		}
+		if dup >= 0 {
+			c.dropDuplicateKey(&o.o, dup)
+		}
+		s = skipWS(s)
+		if len(s) == 0 {
+			return nil, s, fmt.Errorf("unexpected end of object")
+		}
+		if s[0] == ',' {
+			s = s[1:]
+			continue
+		}
+		if s[0] == '}' {
+			if c.duplicateKeys != DuplicateKeysAllow || c.positions {
+				// Keys are unescaped during parsing.
+				o.o.keysUnescaped = true
+			}
+			return o, s[1:], nil
+		}
+		return nil, s, fmt.Errorf("missing ',' after object value")
+	}
+}
+
+// parseRelaxedRecursive is like p.Parse in relaxed mode, but uses
+// parseValueRelaxedRecursive.
+func parseRelaxedRecursive(p *Parser, s string) (*Value, error) {
+	p.c.duplicateKeys = p.DuplicateKeys
+	p.c.invalidUTF8 = p.InvalidUTF8
+	p.c.positions = p.RecordPositions
+	p.c.limits = p.Limits
+	p.c.inputLen = len(s)
+	if p.Limits.MaxInputSize > 0 && len(s) > p.Limits.MaxInputSize {
+		return nil, newInputSizeError(p.Limits.MaxInputSize)
+	}
+	p.b = append(p.b[:0], s...)
+	p.c.reset()
+	p.linesReady = false
+	p.c.input = ""
+	if p.c.positions {
+		p.c.input = b2s(p.b)
+	}
+
+	s, err := skipWSRelaxed(b2s(p.b))
+	if err != nil {
+		return nil, p.c.newParseError(err, s)
+	}
+	v, tail, err := parseValueRelaxedRecursive(s, &p.c)
+	if err != nil {
+		return nil, p.c.newParseError(err, tail)
+	}
+	tail, err = skipWSRelaxed(tail)
+	if err != nil {
+		return nil, p.c.newParseError(err, tail)
+	}
+	if len(tail) > 0 {
+		return nil, p.c.newParseError(errUnexpectedTail, tail)
+	}
+	return v, nil
+}
+
+func parseValueRelaxedRecursive(s string, c *cache) (*Value, string, error) {
+	if len(s) == 0 {
+		return nil, s, fmt.Errorf("cannot parse empty string")
+	}
+	if c.limits.MaxValues > 0 {
+		if err := c.checkValue(); err != nil {
+			return nil, s, err
+		}
+	}
+
+	var v *Value
+	var err error
+	start := s
+
+	switch s[0] {
+	case '{':
+		v, s, err = parseObjectRelaxedRecursive(s, c)
+		if err != nil {
+			return nil, s, fmt.Errorf("cannot parse object: %s", err)
+		}
+	case '[':
+		v, s, err = parseArrayRelaxedRecursive(s, c)
+		if err != nil {
+			return nil, s, fmt.Errorf("cannot parse array: %s", err)
+		}
+	case '"', '\'':
+		var ss string
+		ss, s, err = parseStringRelaxed(s, !c.positions)
+		if err != nil {
+			return nil, s, fmt.Errorf("cannot parse string: %s", err)
+		}
+		if c.limits.MaxStringLength > 0 {
+			if err = c.checkString(ss); err != nil {
+				return nil, start, fmt.Errorf("cannot parse string: %s", err)
+			}
+		}
+		if ss, err = c.checkUTF8(ss); err != nil {
+			return nil, start, fmt.Errorf("cannot parse string: %s", err)
+		}
+		v = c.getValue()
+		v.t = TypeString
+		v.s = ss
+	case 't':
+		if !strings.HasPrefix(s, "true") {
+			return nil, s, fmt.Errorf("unexpected value found: %q", s)
+		}
+		s = s[len("true"):]
+		v = valueTrue
+	case 'f':
+		if !strings.HasPrefix(s, "false") {
+			return nil, s, fmt.Errorf("unexpected value found: %q", s)
+		}
+		s = s[len("false"):]
+		v = valueFalse
+	case 'n':
+		if !strings.HasPrefix(s, "null") {
+			return nil, s, fmt.Errorf("unexpected value found: %q", s)
+		}
+		s = s[len("null"):]
+		v = valueNull
+	default:
+		var ns string
+		var f float64
+		ns, f, s, err = parseNumberRelaxed(s)
+		if err != nil {
+			return nil, s, fmt.Errorf("cannot parse number: %s", err)
+		}
+		v = c.getValue()
+		v.t = TypeNumber
+		v.n = f
+		// Preserve the original number only if it is valid JSON number,
+		// so MarshalTo emits valid JSON.
+		if tail, err := validateNumber(ns); err == nil && len(tail) == 0 {
+			v.s = ns
+		}
+	}
+	if c.positions {
+		v = c.setPos(v, start, s)
+	}
+	return v, s, nil
+}
+
+func parseArrayRelaxedRecursive(s string, c *cache) (*Value, string, error) {
+	// Skip the first char - '['
+	s = s[1:]
+
+	s, err := skipWSRelaxed(s)
+	if err != nil {
+		return nil, s, err
+	}
+	if len(s) == 0 {
+		return nil, s, fmt.Errorf("missing ']'")
+	}
+	if s[0] == ']' {
+		return emptyArray, s[1:], nil
+	}
+
+	a := c.getValue()
+	a.t = TypeArray
+	for {
+		var v *Value
+
+		s, err = skipWSRelaxed(s)
+		if err != nil {
+			return nil, s, err
+		}
+		v, s, err = parseValueRelaxedRecursive(s, c)
+		if err != nil {
+			return nil, s, fmt.Errorf("cannot parse array value: %s", err)
+		}
+		c.appendItem(a, v)
+		if c.limits.MaxElements > 0 {
+			if err = c.checkElements(len(a.a)); err != nil {
+				return nil, s, err
+			}
+		}
+
+		s, err = skipWSRelaxed(s)
+		if err != nil {
+			return nil, s, err
+		}
+		if len(s) == 0 {
+			return nil, s, fmt.Errorf("unexpected end of array")
+		}
+		if s[0] == ',' {
+			// Skip trailing comma.
+			s, err = skipWSRelaxed(s[1:])
+			if err != nil {
+				return nil, s, err
+			}
+			if len(s) == 0 || s[0] != ']' {
+				continue
+			}
+		}
+		if s[0] == ']' {
+			return a, s[1:], nil
+		}
+		return nil, s, fmt.Errorf("missing ',' after array value")
+	}
+}
+
+func parseObjectRelaxedRecursive(s string, c *cache) (*Value, string, error) {
+	// Skip the first char - '{'
+	s = s[1:]
+
+	s, err := skipWSRelaxed(s)
+	if err != nil {
+		return nil, s, err
+	}
+	if len(s) == 0 {
+		return nil, s, fmt.Errorf("missing '}'")
+	}
+	if s[0] == '}' {
+		return emptyObject, s[1:], nil
+	}
+
+	o := c.getValue()
+	o.t = TypeObject
+	for {
+		// Parse key.
+		s, err = skipWSRelaxed(s)
+		if err != nil {
+			return nil, s, err
+		}
+		if len(s) == 0 {
+			return nil, s, fmt.Errorf("missing object key")
+		}
+		kv := c.getKV(&o.o)
+		keyStart := s
+		if c.limits.MaxElements > 0 {
+			if err = c.checkElements(len(o.o.kvs)); err != nil {
+				return nil, s, err
+			}
+		}
+		if s[0] == '"' || s[0] == '\'' {
+			kv.k, s, err = parseStringRelaxed(s, !c.positions)
+		} else {
+			kv.k, s, err = parseIdentifier(s)
+		}
+		if err != nil {
+			return nil, s, fmt.Errorf("cannot parse object key: %s", err)
+		}
+		if c.limits.MaxStringLength > 0 {
+			if err = c.checkString(kv.k); err != nil {
+				return nil, keyStart, fmt.Errorf("cannot parse object key: %s", err)
+			}
+		}
+		if kv.k, err = c.checkUTF8(kv.k); err != nil {
+			return nil, keyStart, fmt.Errorf("cannot parse object key: %s", err)
+		}
+		if c.positions {
+			c.setKeyPos(&o.o, kv.k, keyStart)
+		}
+		dup := -1
+		if c.duplicateKeys != DuplicateKeysAllow {
+			dup = c.duplicateKey(&o.o)
+			if dup >= 0 && c.duplicateKeys == DuplicateKeysReject {
+				return nil, keyStart, fmt.Errorf("duplicate key %q", kv.k)
+			}
+		}
+		s, err = skipWSRelaxed(s)
+		if err != nil {
+			return nil, s, err
+		}
+		if len(s) == 0 || s[0] != ':' {
+			return nil, s, fmt.Errorf("missing ':' after object key")
+		}
+		s = s[1:]
+
+		// Parse value
+		s, err = skipWSRelaxed(s)
+		if err != nil {
+			return nil, s, err
+		}
+		kv.v, s, err = parseValueRelaxedRecursive(s, c)
+		if err != nil {
+			return nil, s, fmt.Errorf("cannot parse object value: %s", err)
+		}
+		if dup >= 0 {
+			c.dropDuplicateKey(&o.o, dup)
+		}
+		s, err = skipWSRelaxed(s)
+		if err != nil {
+			return nil, s, err
+		}
+		if len(s) == 0 {
+			return nil, s, fmt.Errorf("unexpected end of object")
+		}
+		if s[0] == ',' {
+			// Skip trailing comma.
+			s, err = skipWSRelaxed(s[1:])
+			if err != nil {
+				return nil, s, err
+			}
+			if len(s) == 0 || s[0] != '}' {
+				continue
+			}
+		}
+		if s[0] == '}' {
+			// Keys are unescaped during parsing.
+			o.o.keysUnescaped = true
+			return o, s[1:], nil
+		}
+		return nil, s, fmt.Errorf("missing ',' after object value")
+	}
+}
+
+func TestParseIterativeMatchesRecursive(t *testing.T) {
+	inputs := []string{
+		``, ` `, `1`, `-1.5e3`, `"foo"`, `"a\nbA"`, `true`, `false`, `null`,
+		`[]`, `{}`, ` [ 1 , [ ] , { } , "x" ] `, `{"a":1,"b":[2,{"c":null}],"d":{}}`,
+		`{"a":1,"a":2,"b":{"a":3,"a":[4]},"a":5}`,
+		`[[[[[[[[[[1]]]]]]]]]]`, `{"a":{"b":{"c":{"d":[{"e":[]}]}}}}`,
+		"[\"\xff\",{\"\xfe\":1}]",
+		smallFixture, mediumFixture, largeFixture,
+
+		// Invalid JSON.
+		`[`, `{`, `[ `, `{ `, `[1`, `[1,`, `[1,]`, `[,1]`, `[1 2]`, `[1}`,
+		`{"a"`, `{"a":`, `{"a":1`, `{"a":1,`, `{"a":1,}`, `{"a" 1}`, `{1:2}`, `{"a:1}`,
+		`{"a":1]`, `[{"a":[1,{"b":tru}]}]`, `[{"a":[1,{"b":2,}]}]`, `{"a":[1,[2,"x]]}`,
+		`[1]]`, `{"a":{}}}`, `[nul]`, `[-]`, `{"a":[{"b":[{"c":-}]}]}`,
+	}
+	var deep []string
+	for i := 0; i < 20; i++ {
+		deep = append(deep, `{"a":[1,`)
+	}
+	inputs = append(inputs, strings.Join(deep, ""))
+
+	parsers := []Parser{
+		{},
+		{RecordPositions: true},
+		{DuplicateKeys: DuplicateKeysReject},
+		{DuplicateKeys: DuplicateKeysKeepFirst, RecordPositions: true},
+		{DuplicateKeys: DuplicateKeysKeepLast},
+		{InvalidUTF8: InvalidUTF8Reject},
+		{InvalidUTF8: InvalidUTF8Replace, RecordPositions: true},
+		{Limits: Limits{MaxValues: 5}},
+		{Limits: Limits{MaxStringLength: 3}},
+		{Limits: Limits{MaxElements: 2}},
+	}
+	for i := range parsers {
+		p := &parsers[i]
+		var pr Parser
+		pr.DuplicateKeys = p.DuplicateKeys
+		pr.InvalidUTF8 = p.InvalidUTF8
+		pr.RecordPositions = p.RecordPositions
+		pr.Limits = p.Limits
+		for _, s := range inputs {
+			v, err := p.Parse(s)
+			vr, errr := parseRecursive(&pr, s)
+			if (err == nil) != (errr == nil) {
+				t.Fatalf("parser #%d: unexpected error for %q; got %v; want %v", i, s, err, errr)
+			}
+			if err != nil {
+				pe, pr := err.(*ParseError), errr.(*ParseError)
+				if pe.Error() != pr.Error() || pe.Offset != pr.Offset {
+					t.Fatalf("parser #%d: unexpected error for %q;\ngot\n%s (offset %d)\nwant\n%s (offset %d)",
+						i, s, pe, pe.Offset, pr, pr.Offset)
+				}
+				if fmt.Sprintf("%v", pe.Err) != fmt.Sprintf("%v", pr.Err) {
+					t.Fatalf("parser #%d: unexpected ParseError.Err for %q; got %v; want %v", i, s, pe.Err, pr.Err)
+				}
+				continue
+			}
+			if got, want := dumpWithPositions(v), dumpWithPositions(vr); got != want {
+				t.Fatalf("parser #%d: unexpected value for %q;\ngot\n%s\nwant\n%s", i, s, got, want)
+			}
+		}
+	}
+}
+
+func TestParseRelaxedIterativeMatchesRecursive(t *testing.T) {
+	inputs := []string{
+		``, ` `, `1`, `"foo"`, `'foo'`, `true`, `null`, `[]`, `{}`, ` [ 1 , [ ] , { } , "x" ] `,
+		`{"a":1,"b":[2,{"c":null}],"d":{}}`, `{"a":1,"a":2,"b":{"a":3,"a":[4]},"a":5}`,
+		`[[[[[[[[[[1]]]]]]]]]]`, `{a:{b:{c:{d:[{e:[]}]}}}}`,
+		"// comment\n{a: 1, /* b */ 'b': [0x1F, +Infinity, NaN, .5,], c: 'x\\\ny',}",
+		"[\"\xff\",{\"\xfe\":1}]", `[1,]`, `{a:1,}`, `[ 1 , ]`, `{ a : 1 , }`,
+		smallFixture, mediumFixture,
+
+		// Invalid JSON.
+		`[`, `{`, `[ `, `{ `, `[1`, `[1,`, `[,1]`, `[1 2]`, `[1}`, `[1,,]`, `{a:1,,}`,
+		`{"a"`, `{"a":`, `{"a":1`, `{"a":1,`, `{"a" 1}`, `{1:2}`, `{"a:1}`, `{,}`,
+		`{"a":1]`, `[{"a":[1,{"b":tru}]}]`, `{"a":[1,[2,"x]]}`, `[/*]`, `{/*`, `[1/*`, `[1,/*]`,
+		`{a/*}`, `{a:/*}`, `{a:1/*}`, `{a:1,/*}`, `[1]]`, `{"a":{}}}`, `[nul]`, `[-]`, `{"a":[{"b":[{"c":-}]}]}`,
+	}
+	var deep []string
+	for i := 0; i < 20; i++ {
+		deep = append(deep, `{a:[1,`)
+	}
+	inputs = append(inputs, strings.Join(deep, ""))
+
+	parsers := []Parser{
+		{},
+		{RecordPositions: true},
+		{DuplicateKeys: DuplicateKeysReject},
+		{DuplicateKeys: DuplicateKeysKeepFirst, RecordPositions: true},
+		{DuplicateKeys: DuplicateKeysKeepLast},
+		{InvalidUTF8: InvalidUTF8Reject},
+		{InvalidUTF8: InvalidUTF8Replace, RecordPositions: true},
+		{Limits: Limits{MaxValues: 5}},
+		{Limits: Limits{MaxStringLength: 3}},
+		{Limits: Limits{MaxElements: 2}},
+	}
+	for i := range parsers {
+		p := &parsers[i]
+		p.Relaxed = true
+		var pr Parser
+		pr.DuplicateKeys = p.DuplicateKeys
+		pr.InvalidUTF8 = p.InvalidUTF8
+		pr.RecordPositions = p.RecordPositions
+		pr.Limits = p.Limits
+		for _, s := range inputs {
+			v, err := p.Parse(s)
+			vr, errr := parseRelaxedRecursive(&pr, s)
+			if (err == nil) != (errr == nil) {
+				t.Fatalf("parser #%d: unexpected error for %q; got %v; want %v", i, s, err, errr)
+			}
+			if err != nil {
+				pe, pr := err.(*ParseError), errr.(*ParseError)
+				if pe.Error() != pr.Error() || pe.Offset != pr.Offset {
+					t.Fatalf("parser #%d: unexpected error for %q;\ngot\n%s (offset %d)\nwant\n%s (offset %d)",
+						i, s, pe, pe.Offset, pr, pr.Offset)
+				}
+				if fmt.Sprintf("%v", pe.Err) != fmt.Sprintf("%v", pr.Err) {
+					t.Fatalf("parser #%d: unexpected ParseError.Err for %q; got %v; want %v", i, s, pe.Err, pr.Err)
+				}
+				continue
+			}
+			if got, want := dumpWithPositions(v), dumpWithPositions(vr); got != want {
+				t.Fatalf("parser #%d: unexpected value for %q;\ngot\n%s\nwant\n%s", i, s, got, want)
+			}
+		}
+	}
+}
+
+// dumpWithPositions returns v with the offsets of all the nested values.
+func dumpWithPositions(v *Value) string {
+	var b []byte
+	var dump func(v *Value)
+	dump = func(v *Value) {
+		b = append(b, fmt.Sprintf("@%d:", v.Offset())...)
+		switch v.Type() {
+		case TypeObject:
+			b = append(b, '{')
+			v.GetObject().Visit(func(k []byte, vv *Value) {
+				b = appendEscapedString(b, string(k))
+				b = append(b, fmt.Sprintf("@%d:", v.GetObject().KeyOffset(string(k)))...)
+				dump(vv)
+				b = append(b, ',')
+			})
+			b = append(b, '}')
+		case TypeArray:
+			b = append(b, '[')
+			for _, vv := range v.GetArray() {
+				dump(vv)
+				b = append(b, ',')
+			}
+			b = append(b, ']')
+		default:
+			b = v.MarshalTo(b)
+		}
+	}
+	dump(v)
+	return string(b)
+}
+
+func TestParseDeeplyNested(t *testing.T) {
+	const depth = 100000
+	s := strings.Repeat(`[{"a":`, depth) + `1` + strings.Repeat(`}]`, depth)
+	var p Parser
+	for i := 0; i < 2; i++ {
+		v, err := p.Parse(s)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		for j := 0; j < depth; j++ {
+			v = v.Get("0", "a")
+		}
+		if n := v.GetInt(); n != 1 {
+			t.Fatalf("unexpected innermost value; got %d; want %d", n, 1)
+		}
+	}
+
+	// Relaxed mode.
+	p.Relaxed = true
+	v, err := p.Parse(strings.Repeat(`[{a:`, depth) + `1` + strings.Repeat(`},]`, depth))
+	if err != nil {
+		t.Fatalf("unexpected error in relaxed mode: %s", err)
+	}
+	for j := 0; j < depth; j++ {
+		v = v.Get("0", "a")
+	}
+	if n := v.GetInt(); n != 1 {
+		t.Fatalf("unexpected innermost value in relaxed mode; got %d; want %d", n, 1)
+	}
+	p.Relaxed = false
+
+	// Unclosed arrays.
+	_, err = p.Parse(strings.Repeat(`[`, 1000))
+	if err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+	if !strings.HasSuffix(err.Error(), `cannot parse array: missing ']'; unparsed tail: ""`) {
+		t.Fatalf("unexpected error suffix: %s", err)
+	}
+}
@@ -66,6 +66,9 @@ func TestUnescapeStringBestEffort(t *testing.T) {
 		testUnescapeStringBestEffort(t, `\\\"абв`, `\"абв`)
 		testUnescapeStringBestEffort(t, `йцук\n\"\\Y`, "йцук\n\"\\Y")
 		testUnescapeStringBestEffort(t, `q\u1234we`, "q\u1234we")
+		testUnescapeStringBestEffort(t, `x\ud83d\ude00y`, "x\U0001f600y")
+		testUnescapeStringBestEffort(t, `x\ud83dy`, "x\ufffdy")
+		testUnescapeStringBestEffort(t, `\ud83d\u0041`, "\ufffdA")
 	})
 
 	t.Run("error", func(t *testing.T) {
@@ -88,6 +91,30 @@ func testUnescapeStringBestEffort(t *testing.T, s, expectedS string) {
 	}
 }
 
+func TestParserSurrogatePairs(t *testing.T) {
+	f := func(escaped, expected string) {
+		t.Helper()
+		var p Parser
+		s := `{"` + escaped + `":"` + escaped + `"}`
+		v, err := p.Parse(s)
+		if err != nil {
+			t.Fatalf("cannot parse %s: %s", s, err)
+		}
+
+		// Both object keys and string values must be decoded.
+		if sb := v.GetStringBytes(expected); string(sb) != expected {
+			t.Fatalf("unexpected string value for %s; got %q; want %q", s, sb, expected)
+		}
+	}
+
+	f(`\ud83d\ude00`, "\U0001f600")
+	f(`x\uD83D\uDE00y\ud83c\udf89`, "x\U0001f600y\U0001f389")
+	f(`\ud83d`, "\ufffd")
+	f(`\ude00\ud83d`, "\ufffd\ufffd")
+	f(`\ud83d\u0041`, "\ufffdA")
+	f(`\ud83dx\ude00`, "\ufffdx\ufffd")
+}
+
 func TestParseRawString(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		testParseRawStringSuccess(t, `""`, "", "")
@@ -1076,3 +1103,85 @@ func TestParseBigObject(t *testing.T) {
 		t.Fatalf("unexpected non-nil value for non-existing-key: %q", sb)
 	}
 }
+
+func TestValueMarshalTo(t *testing.T) {
+	f := func(s, expectedS string) {
+		t.Helper()
+		var p Parser
+		v, err := p.Parse(s)
+		if err != nil {
+			t.Fatalf("cannot parse %q: %s", s, err)
+		}
+		b := v.MarshalTo(nil)
+		if string(b) != expectedS {
+			t.Fatalf("unexpected marshaled value; got %s; want %s", b, expectedS)
+		}
+	}
+
+	f(`null`, `null`)
+	f(` true `, `true`)
+	f(`false`, `false`)
+	f(`-12.34e5`, `-12.34e5`)
+	f(`"foobar"`, `"foobar"`)
+	f(`"\"\\\/\b\f\n\r\t\u0001"`, `"\"\\/\b\f\n\r\t\u0001"`)
+	f(`{ "a\nb" : [ 1 , {} , [ ] , "x" ] }`, `{"a\nb":[1,{},[],"x"]}`)
+}
+
+func TestValueFreeze(t *testing.T) {
+	var p Parser
+	s := `{"a\nb":[1.5,"x\ty",{},[],{"c":{"d":"a"}}],"e":-12e3,"f":true,"g":null}`
+	v, err := p.Parse(s)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	v.Freeze()
+
+	// Verify that the frozen value may be read from concurrent goroutines.
+	// Run this test with -race flag.
+	expected := `{"a\nb":[1.5,"x\ty",{},[],{"c":{"d":"a"}}],"e":-12e3,"f":true,"g":null}`
+	ch := make(chan error, 5)
+	for i := 0; i < cap(ch); i++ {
+		go func() {
+			var err error
+			if result := string(v.MarshalTo(nil)); result != expected {
+				err = fmt.Errorf("unexpected result;\ngot\n%s\nwant\n%s", result, expected)
+			}
+			if d := v.GetStringBytes("a\nb", "4", "c", "d"); string(d) != "a" {
+				err = fmt.Errorf("unexpected value; got %q; want %q", d, "a")
+			}
+			if n := v.GetFloat64("e"); n != -12e3 {
+				err = fmt.Errorf("unexpected number; got %v; want %v", n, -12e3)
+			}
+			v.GetArray("a\nb")[2].GetObject().Visit(func(k []byte, v *Value) {
+				err = fmt.Errorf("unexpected key in empty object: %q", k)
+			})
+			ch <- err
+		}()
+	}
+	for i := 0; i < cap(ch); i++ {
+		if err := <-ch; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestValueDeeplyNested(t *testing.T) {
+	const depth = 100000
+	s := strings.Repeat(`[{"a\n":`, depth) + `"x\ty"` + strings.Repeat(`}]`, depth)
+	var p Parser
+	v, err := p.Parse(s)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	v.Freeze()
+	if result := string(v.MarshalTo(nil)); result != s {
+		t.Fatalf("unexpected marshaled value of %d bytes; want %d bytes", len(result), len(s))
+	}
+	expected := strings.Repeat(`[{"a\n":`, depth) + `"x\ty"` + strings.Repeat(`}]`, depth)
+	if result := v.String(); result != expected {
+		t.Fatalf("unexpected string representation of %d bytes; want %d bytes", len(result), len(expected))
+	}
+	if result := v.GetArray()[0].GetObject().String(); result != expected[1:len(expected)-1] {
+		t.Fatalf("unexpected object string representation of %d bytes; want %d bytes", len(result), len(expected)-2)
+	}
+}
@@ -2,19 +2,55 @@ package fastjson
 
 import (
 	"sync"
+	"sync/atomic"
 )
 
 // ParserPool may be used for pooling parsers for similarly typed JSONs.
 type ParserPool struct {
+	// Counters are placed at the start of the struct in order to guarantee
+	// 64-bit alignment for atomic operations on 32-bit platforms.
+	gets    uint64
+	puts    uint64
+	news    uint64
+	shrinks uint64
+
+	// MaxParserMemoryUsage is the maximum memory in bytes a parser
+	// may retain when returned to the pool via Put.
+	//
+	// Parsers retaining more memory after parsing big JSON are shrunk
+	// with Parser.Shrink, so the pool doesn't hold the memory forever.
+	//
+	// Parsers aren't shrunk by default.
+	MaxParserMemoryUsage int
+
 	pool sync.Pool
 }
 
+// ParserPoolStats contains ParserPool statistics.
+type ParserPoolStats struct {
+	// Gets is the number of Get calls.
+	Gets uint64
+
+	// Puts is the number of Put calls.
+	Puts uint64
+
+	// News is the number of parsers created by Get calls,
+	// since the pool had no free parsers.
+	News uint64
+
+	// Shrinks is the number of parsers shrunk by Put calls.
+	// See ParserPool.MaxParserMemoryUsage for details.
+	Shrinks uint64
+}
+
 // Get returns a parser from pp.
 //
 // The parser must be Put to pp after use.
 func (pp *ParserPool) Get() *Parser {
+	atomic.AddUint64(&pp.gets, 1)
 	v := pp.pool.Get()
 	if v == nil {
+		atomic.AddUint64(&pp.news, 1)
 		return &Parser{}
 	}
 	return v.(*Parser)
@@ -25,5 +61,19 @@ func (pp *ParserPool) Get() *Parser {
 // p and objects recursively returned from p cannot be used after p
 // is put into pp.
 func (pp *ParserPool) Put(p *Parser) {
+	atomic.AddUint64(&pp.puts, 1)
+	if pp.MaxParserMemoryUsage > 0 && p.Shrink(pp.MaxParserMemoryUsage) {
+		atomic.AddUint64(&pp.shrinks, 1)
+	}
 	pp.pool.Put(p)
 }
+
+// Stats returns pp statistics.
+func (pp *ParserPool) Stats() ParserPoolStats {
+	return ParserPoolStats{
+		Gets:    atomic.LoadUint64(&pp.gets),
+		Puts:    atomic.LoadUint64(&pp.puts),
+		News:    atomic.LoadUint64(&pp.news),
+		Shrinks: atomic.LoadUint64(&pp.shrinks),
+	}
+}
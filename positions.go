@@ -0,0 +1,159 @@
+package fastjson
+
+import (
+	"sort"
+	"strings"
+)
+
+// Offset returns the byte offset of v in the input passed to Parse.
+//
+// -1 is returned if v hasn't been obtained from Parser
+// with RecordPositions set.
+//
+// Use Parser.LineColumn for converting the offset to line and column.
+func (v *Value) Offset() int {
+	if v.o.pos == nil {
+		return -1
+	}
+	return v.o.pos.offset
+}
+
+// Raw returns the original text of v in the input passed to Parse.
+//
+// nil is returned if v hasn't been obtained from Parser
+// with RecordPositions set.
+//
+// The returned bytes are valid until the next Parse call
+// on the Parser returned v. The returned bytes mustn't be modified.
+func (v *Value) Raw() []byte {
+	if v.o.pos == nil {
+		return nil
+	}
+	return s2b(v.o.pos.raw)
+}
+
+// KeyOffset returns the byte offset of the given key from o in the input
+// passed to Parse.
+//
+// The offset of the first matching key is returned if o contains
+// duplicate keys.
+//
+// -1 is returned if the key isn't found or if o hasn't been obtained
+// from Parser with RecordPositions set.
+func (o *Object) KeyOffset(key string) int {
+	if o.pos == nil {
+		return -1
+	}
+	for _, kp := range o.pos.keys {
+		if kp.k == key {
+			return kp.offset
+		}
+	}
+	return -1
+}
+
+// LineColumn returns 1-based line and column for the given byte offset
+// in the last input passed to Parse.
+//
+// Column is measured in bytes. Lines are delimited by '\n'.
+//
+// Parser.RecordPositions must be set during parsing.
+// Line starts are located on the first call after Parse.
+func (p *Parser) LineColumn(offset int) (line, column int) {
+	if !p.linesReady {
+		p.initLines()
+	}
+	n := sort.Search(len(p.lines), func(i int) bool {
+		return p.lines[i] > offset
+	})
+	lineStart := 0
+	if n > 0 {
+		lineStart = p.lines[n-1]
+	}
+	return n + 1, offset - lineStart + 1
+}
+
+// initLines fills p.lines with offsets of line starts in the parsed input.
+func (p *Parser) initLines() {
+	p.lines = p.lines[:0]
+	p.linesReady = true
+	s := b2s(p.b)
+	offset := 0
+	for {
+		n := strings.IndexByte(s, '\n')
+		if n < 0 {
+			return
+		}
+		offset += n + 1
+		p.lines = append(p.lines, offset)
+		s = s[n+1:]
+	}
+}
+
+// valuePos is the position of a value in the parsed input.
+//
+// Positions are kept outside Value in cache.posSlabs, so Value holds
+// only a pointer to its position.
+type valuePos struct {
+	// offset is the offset of the value in the parsed input.
+	offset int
+
+	// raw is the original text of the value.
+	raw string
+
+	// keys contains positions of object keys in the order of Object.kvs.
+	keys []keyPos
+}
+
+// keyPos is the position of object key.
+type keyPos struct {
+	// k is the unescaped key.
+	k string
+
+	// offset is the offset of the key in the parsed input.
+	offset int
+}
+
+// slabPositions contains positions of the values from valueSlab.
+type slabPositions [valuesPerSlab]valuePos
+
+// initPos returns the reset position for the value at the given slab slot.
+func (c *cache) initPos(slot int) *valuePos {
+	for slot/valuesPerSlab >= len(c.posSlabs) {
+		c.posSlabs = append(c.posSlabs, &slabPositions{})
+	}
+	vp := &c.posSlabs[slot/valuesPerSlab][slot%valuesPerSlab]
+	vp.offset = -1
+	vp.raw = ""
+	vp.keys = vp.keys[:0]
+	return vp
+}
+
+// setPos sets the position of v, which starts at s and ends at tail.
+//
+// Shared values such as valueTrue are copied, since they cannot have
+// positions.
+func (c *cache) setPos(v *Value, s, tail string) *Value {
+	switch v {
+	case valueTrue, valueFalse, valueNull, emptyObject, emptyArray:
+		t := v.t
+		v = c.getValue()
+		v.t = t
+		v.o.keysUnescaped = true
+	}
+	vp := v.o.pos
+	vp.offset = c.inputLen - len(s)
+	vp.raw = c.input[vp.offset : c.inputLen-len(tail)]
+	return v
+}
+
+// setKeyPos records the position of the last key k from o starting at s.
+func (c *cache) setKeyPos(o *Object, k, s string) {
+	vp := o.pos
+	n := cap(vp.keys)
+	vp.keys = append(vp.keys, keyPos{
+		k:      k,
+		offset: c.inputLen - len(s),
+	})
+	c.retained += (cap(vp.keys) - n) * keyPosSize
+}
@@ -0,0 +1,262 @@
+package fastjson
+
+import (
+	"testing"
+)
+
+func TestValueOffset(t *testing.T) {
+	f := func(relaxed bool, s string, keys []string, expectedOffset int) {
+		t.Helper()
+		p := &Parser{
+			Relaxed:         relaxed,
+			RecordPositions: true,
+		}
+		v, err := p.Parse(s)
+		if err != nil {
+			t.Fatalf("unexpected error when parsing %q: %s", s, err)
+		}
+		vv := v.Get(keys...)
+		if vv == nil {
+			t.Fatalf("cannot find value for keys %q in %q", keys, s)
+		}
+		offset := vv.Offset()
+		if offset != expectedOffset {
+			t.Fatalf("unexpected offset for keys %q in %q; got %d; want %d", keys, s, offset, expectedOffset)
+		}
+	}
+
+	for _, relaxed := range []bool{false, true} {
+		f(relaxed, `123`, nil, 0)
+		f(relaxed, "  \n\t 123 ", nil, 5)
+		f(relaxed, ` {}`, nil, 1)
+		f(relaxed, `[1, "foo", true, false, null, {}, [], {"a":"b"}, -1.5]`, []string{"0"}, 1)
+		f(relaxed, `[1, "foo", true, false, null, {}, [], {"a":"b"}, -1.5]`, []string{"1"}, 4)
+		f(relaxed, `[1, "foo", true, false, null, {}, [], {"a":"b"}, -1.5]`, []string{"2"}, 11)
+		f(relaxed, `[1, "foo", true, false, null, {}, [], {"a":"b"}, -1.5]`, []string{"3"}, 17)
+		f(relaxed, `[1, "foo", true, false, null, {}, [], {"a":"b"}, -1.5]`, []string{"4"}, 24)
+		f(relaxed, `[1, "foo", true, false, null, {}, [], {"a":"b"}, -1.5]`, []string{"5"}, 30)
+		f(relaxed, `[1, "foo", true, false, null, {}, [], {"a":"b"}, -1.5]`, []string{"6"}, 34)
+		f(relaxed, `[1, "foo", true, false, null, {}, [], {"a":"b"}, -1.5]`, []string{"7"}, 38)
+		f(relaxed, `[1, "foo", true, false, null, {}, [], {"a":"b"}, -1.5]`, []string{"7", "a"}, 43)
+		f(relaxed, `[1, "foo", true, false, null, {}, [], {"a":"b"}, -1.5]`, []string{"8"}, 49)
+		f(relaxed, `{"a\"b":"x\ny", "c": [1]}`, []string{"c", "0"}, 22)
+	}
+	f(true, "// comment\n{foo: 'bar'}", []string{"foo"}, 17)
+}
+
+func TestValueOffsetDisabled(t *testing.T) {
+	var p Parser
+	v, err := p.Parse(`{"a":[1,true,{}]}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for _, vv := range []*Value{v, v.Get("a"), v.Get("a", "0"), v.Get("a", "1"), v.Get("a", "2")} {
+		if offset := vv.Offset(); offset != -1 {
+			t.Fatalf("unexpected offset for %s; got %d; want -1", vv, offset)
+		}
+	}
+	if offset := v.GetObject().KeyOffset("a"); offset != -1 {
+		t.Fatalf("unexpected key offset; got %d; want -1", offset)
+	}
+
+	// Verify that positions aren't left from the previous parsing.
+	p.RecordPositions = true
+	if _, err := p.Parse(`{"a":[1,true,{}]}`); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	p.RecordPositions = false
+	v, err = p.Parse(`{"a":[1,true,{}]}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if offset := v.Get("a", "0").Offset(); offset != -1 {
+		t.Fatalf("unexpected offset; got %d; want -1", offset)
+	}
+	if offset := v.GetObject().KeyOffset("a"); offset != -1 {
+		t.Fatalf("unexpected key offset; got %d; want -1", offset)
+	}
+}
+
+func TestObjectKeyOffset(t *testing.T) {
+	f := func(relaxed bool, s string, key string, expectedOffset int) {
+		t.Helper()
+		p := &Parser{
+			Relaxed:         relaxed,
+			RecordPositions: true,
+		}
+		v, err := p.Parse(s)
+		if err != nil {
+			t.Fatalf("unexpected error when parsing %q: %s", s, err)
+		}
+		offset := v.GetObject().KeyOffset(key)
+		if offset != expectedOffset {
+			t.Fatalf("unexpected offset for key %q in %q; got %d; want %d", key, s, offset, expectedOffset)
+		}
+	}
+
+	for _, relaxed := range []bool{false, true} {
+		f(relaxed, `{"a":1, "b" : 2}`, "a", 1)
+		f(relaxed, `{"a":1, "b" : 2}`, "b", 8)
+		f(relaxed, `{"a":1, "b" : 2}`, "c", -1)
+		f(relaxed, `{"a\nb":1, "cA" : 2}`, "cA", 11)
+		f(relaxed, `{"a":1, "a" : 2}`, "a", 1)
+	}
+	f(true, `{a:1, 'b': 2}`, "b", 6)
+	f(true, `{a:1, 'b\'c': 2}`, "b'c", 6)
+
+	// Duplicate keys.
+	fd := func(dk DuplicateKeys, valueOffset int) {
+		t.Helper()
+		p := &Parser{
+			DuplicateKeys:   dk,
+			RecordPositions: true,
+		}
+		v, err := p.Parse(`{"a":1, "b":2, "a":3, "c":4}`)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		o := v.GetObject()
+		keyOffsets := map[string]int{
+			"a": 1,
+			"b": 8,
+			"c": 22,
+		}
+		for key, expectedOffset := range keyOffsets {
+			if offset := o.KeyOffset(key); offset != expectedOffset {
+				t.Fatalf("unexpected offset for key %q; got %d; want %d", key, offset, expectedOffset)
+			}
+		}
+		if offset := o.Get("a").Offset(); offset != valueOffset {
+			t.Fatalf("unexpected offset for the duplicate key value; got %d; want %d", offset, valueOffset)
+		}
+	}
+	fd(DuplicateKeysKeepFirst, 5)
+	fd(DuplicateKeysKeepLast, 19)
+}
+
+func TestParserLineColumn(t *testing.T) {
+	s := "{\n  \"a\": [\n    1,\n    \"x\\ny\",\n    {\"b\": null}\n  ]\n}\n"
+	p := &Parser{
+		RecordPositions: true,
+	}
+	v, err := p.Parse(s)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	f := func(offset, expectedLine, expectedColumn int) {
+		t.Helper()
+		line, column := p.LineColumn(offset)
+		if line != expectedLine || column != expectedColumn {
+			t.Fatalf("unexpected position for offset %d; got %d:%d; want %d:%d", offset, line, column, expectedLine, expectedColumn)
+		}
+	}
+	f(v.Offset(), 1, 1)
+	f(v.GetObject().KeyOffset("a"), 2, 3)
+	f(v.Get("a").Offset(), 2, 8)
+	f(v.Get("a", "0").Offset(), 3, 5)
+	f(v.Get("a", "1").Offset(), 4, 5)
+	f(v.Get("a", "2").Offset(), 5, 5)
+	f(v.Get("a", "2").GetObject().KeyOffset("b"), 5, 6)
+	f(v.Get("a", "2", "b").Offset(), 5, 11)
+}
+
+func TestValueRaw(t *testing.T) {
+	f := func(relaxed bool, s string, keys []string, expectedRaw string) {
+		t.Helper()
+		p := &Parser{
+			Relaxed:         relaxed,
+			RecordPositions: true,
+		}
+		v, err := p.Parse(s)
+		if err != nil {
+			t.Fatalf("unexpected error when parsing %q: %s", s, err)
+		}
+		vv := v.Get(keys...)
+		if vv == nil {
+			t.Fatalf("cannot find value for keys %q in %q", keys, s)
+		}
+
+		// Access the value, so the escaped strings are unescaped.
+		_ = vv.String()
+
+		raw := string(vv.Raw())
+		if raw != expectedRaw {
+			t.Fatalf("unexpected raw value for keys %q in %q; got %q; want %q", keys, s, raw, expectedRaw)
+		}
+	}
+
+	for _, relaxed := range []bool{false, true} {
+		f(relaxed, ` 123 `, nil, `123`)
+		f(relaxed, `[1, "foo", true, false, null, {}, [], -1.5e3]`, nil, `[1, "foo", true, false, null, {}, [], -1.5e3]`)
+		f(relaxed, `[1, "foo", true, false, null, {}, [], -1.5e3]`, []string{"1"}, `"foo"`)
+		f(relaxed, `[1, "foo", true, false, null, {}, [], -1.5e3]`, []string{"2"}, `true`)
+		f(relaxed, `[1, "foo", true, false, null, {}, [], -1.5e3]`, []string{"4"}, `null`)
+		f(relaxed, `[1, "foo", true, false, null, {}, [], -1.5e3]`, []string{"5"}, `{}`)
+		f(relaxed, `[1, "foo", true, false, null, {}, [], -1.5e3]`, []string{"6"}, `[]`)
+		f(relaxed, `[1, "foo", true, false, null, {}, [], -1.5e3]`, []string{"7"}, `-1.5e3`)
+		f(relaxed, `{"ext": {"a\nb": [ "x\ty" , {} ] }, "id": 1}`, []string{"ext"}, `{"a\nb": [ "x\ty" , {} ] }`)
+		f(relaxed, `{"ext": {"a\nb": [ "x\ty" , {} ] }, "id": 1}`, []string{"ext", "a\nb"}, `[ "x\ty" , {} ]`)
+		f(relaxed, `{"ext": {"a\nb": [ "x\ty" , {} ] }, "id": 1}`, []string{"ext", "a\nb", "0"}, `"x\ty"`)
+	}
+	f(true, `{ext: {a: 'b', /* c */ d: 0x10,},}`, []string{"ext"}, `{a: 'b', /* c */ d: 0x10,}`)
+	f(true, `{ext: {a: 'b', /* c */ d: 0x10,},}`, []string{"ext", "d"}, `0x10`)
+}
+
+func TestValueRawDisabled(t *testing.T) {
+	var p Parser
+	v, err := p.Parse(`{"a":[1]}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if raw := v.Raw(); raw != nil {
+		t.Fatalf("expecting nil raw value; got %q", raw)
+	}
+	if raw := v.Get("a", "0").Raw(); raw != nil {
+		t.Fatalf("expecting nil raw value; got %q", raw)
+	}
+}
+
+func TestValuePositionsMultipleParsers(t *testing.T) {
+	p1 := &Parser{
+		RecordPositions: true,
+	}
+	p2 := &Parser{
+		RecordPositions: true,
+	}
+	var p3 Parser
+	v1, err := p1.Parse(`{"a": [1, "x\ty"]}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	v2, err := p2.Parse(`  {"b":  {"a": 2}}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	v3, err := p3.Parse(`{"a": 3}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Values must keep positions from the parser they were obtained from.
+	if offset := v1.Get("a", "1").Offset(); offset != 10 {
+		t.Fatalf("unexpected offset from the first parser; got %d; want %d", offset, 10)
+	}
+	if raw := string(v1.Get("a", "1").Raw()); raw != `"x\ty"` {
+		t.Fatalf("unexpected raw value from the first parser; got %q; want %q", raw, `"x\ty"`)
+	}
+	if offset := v2.Get("b", "a").Offset(); offset != 15 {
+		t.Fatalf("unexpected offset from the second parser; got %d; want %d", offset, 15)
+	}
+	if offset := v2.GetObject("b").KeyOffset("a"); offset != 10 {
+		t.Fatalf("unexpected key offset from the second parser; got %d; want %d", offset, 10)
+	}
+	if raw := string(v2.Get("b").Raw()); raw != `{"a": 2}` {
+		t.Fatalf("unexpected raw value from the second parser; got %q; want %q", raw, `{"a": 2}`)
+	}
+	if offset := v3.Get("a").Offset(); offset != -1 {
+		t.Fatalf("unexpected offset from the parser without positions; got %d; want -1", offset)
+	}
+	if line, column := p2.LineColumn(v2.Get("b").Offset()); line != 1 || column != 10 {
+		t.Fatalf("unexpected line:column; got %d:%d; want 1:10", line, column)
+	}
+}
@@ -0,0 +1,532 @@
+package fastjson
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// parseRelaxed parses p.b in relaxed mode. See Parser.Relaxed for details.
+func (p *Parser) parseRelaxed() (*Value, error) {
+	s, err := skipWSRelaxed(b2s(p.b))
+	if err != nil {
+		return nil, p.c.newParseError(err, s)
+	}
+	v, tail, err := parseValueRelaxed(s, &p.c)
+	if err != nil {
+		return nil, p.c.newParseError(err, tail)
+	}
+	tail, err = skipWSRelaxed(tail)
+	if err != nil {
+		return nil, p.c.newParseError(err, tail)
+	}
+	if len(tail) > 0 {
+		return nil, p.c.newParseError(errUnexpectedTail, tail)
+	}
+	return v, nil
+}
+
+// skipWSRelaxed skips whitespace and comments in s.
+func skipWSRelaxed(s string) (string, error) {
+	for len(s) > 0 {
+		ch := s[0]
+		switch {
+		case ch == '\x20' || ch == '\x0A' || ch == '\x0D' || ch == '\x09' || ch == '\x0B' || ch == '\x0C':
+			s = s[1:]
+		case ch == '/' && len(s) > 1 && s[1] == '/':
+			n := strings.IndexByte(s, '\n')
+			if n < 0 {
+				return "", nil
+			}
+			s = s[n+1:]
+		case ch == '/' && len(s) > 1 && s[1] == '*':
+			n := strings.Index(s[2:], "*/")
+			if n < 0 {
+				return s, fmt.Errorf("missing '*/' for block comment")
+			}
+			s = s[n+4:]
+		case ch >= utf8.RuneSelf:
+			r, size := utf8.DecodeRuneInString(s)
+			if r != '\uFEFF' && r != '\u2028' && r != '\u2029' && !unicode.Is(unicode.Zs, r) {
+				return s, nil
+			}
+			s = s[size:]
+		default:
+			return s, nil
+		}
+	}
+	return s, nil
+}
+
+// parseValueRelaxed parses relaxed JSON value at the start of s.
+//
+// Nested arrays and objects are parsed iteratively with the explicit stack
+// stored in c in the same way as parseValue does.
+func parseValueRelaxed(s string, c *cache) (*Value, string, error) {
+	stack := c.stack[:0]
+	var v *Value
+	var err error
+
+parseNext:
+	for {
+		if len(s) == 0 {
+			return c.parseError(stack, "", s, fmt.Errorf("cannot parse empty string"))
+		}
+		if c.limits.MaxValues > 0 {
+			if err = c.checkValue(); err != nil {
+				return c.parseError(stack, "", s, err)
+			}
+		}
+
+		start := s
+		switch s[0] {
+		case '{':
+			if s, err = skipWSRelaxed(s[1:]); err != nil {
+				return c.parseError(stack, "object", s, err)
+			}
+			if len(s) == 0 {
+				return c.parseError(stack, "object", s, fmt.Errorf("missing '}'"))
+			}
+			if s[0] == '}' {
+				v = emptyObject
+				s = s[1:]
+				break
+			}
+			o := c.getValue()
+			o.t = TypeObject
+			stack = append(stack, parseFrame{
+				v:     o,
+				start: start,
+			})
+			if s, err = c.parseKeyRelaxed(&stack[len(stack)-1], s); err != nil {
+				return c.frameError(stack, s, err)
+			}
+			continue
+		case '[':
+			if s, err = skipWSRelaxed(s[1:]); err != nil {
+				return c.parseError(stack, "array", s, err)
+			}
+			if len(s) == 0 {
+				return c.parseError(stack, "array", s, fmt.Errorf("missing ']'"))
+			}
+			if s[0] == ']' {
+				v = emptyArray
+				s = s[1:]
+				break
+			}
+			a := c.getValue()
+			a.t = TypeArray
+			stack = append(stack, parseFrame{
+				v:     a,
+				start: start,
+			})
+			continue
+		case '"', '\'':
+			var ss string
+			ss, s, err = parseStringRelaxed(s, !c.positions)
+			if err != nil {
+				return c.parseError(stack, "", s, fmt.Errorf("cannot parse string: %s", err))
+			}
+			if c.limits.MaxStringLength > 0 {
+				if err = c.checkString(ss); err != nil {
+					return c.parseError(stack, "", start, fmt.Errorf("cannot parse string: %s", err))
+				}
+			}
+			if ss, err = c.checkUTF8(ss); err != nil {
+				return c.parseError(stack, "", start, fmt.Errorf("cannot parse string: %s", err))
+			}
+			v = c.getValue()
+			v.t = TypeString
+			v.s = ss
+		case 't':
+			if !strings.HasPrefix(s, "true") {
+				return c.parseError(stack, "", s, fmt.Errorf("unexpected value found: %q", s))
+			}
+			s = s[len("true"):]
+			v = valueTrue
+		case 'f':
+			if !strings.HasPrefix(s, "false") {
+				return c.parseError(stack, "", s, fmt.Errorf("unexpected value found: %q", s))
+			}
+			s = s[len("false"):]
+			v = valueFalse
+		case 'n':
+			if !strings.HasPrefix(s, "null") {
+				return c.parseError(stack, "", s, fmt.Errorf("unexpected value found: %q", s))
+			}
+			s = s[len("null"):]
+			v = valueNull
+		default:
+			var ns string
+			var f float64
+			ns, f, s, err = parseNumberRelaxed(s)
+			if err != nil {
+				return c.parseError(stack, "", s, fmt.Errorf("cannot parse number: %s", err))
+			}
+			v = c.getValue()
+			v.t = TypeNumber
+			v.n = f
+			// Preserve the original number only if it is valid JSON number,
+			// so MarshalTo emits valid JSON.
+			if tail, err := validateNumber(ns); err == nil && len(tail) == 0 {
+				v.s = ns
+			}
+		}
+		if c.positions {
+			v = c.setPos(v, start, s)
+		}
+
+		// Add v to the parent arrays and objects, which end after v.
+		for len(stack) > 0 {
+			f := &stack[len(stack)-1]
+			if f.v.t == TypeArray {
+				c.appendItem(f.v, v)
+				if c.limits.MaxElements > 0 {
+					if err = c.checkElements(len(f.v.a)); err != nil {
+						return c.frameError(stack, s, err)
+					}
+				}
+				if s, err = skipWSRelaxed(s); err != nil {
+					return c.frameError(stack, s, err)
+				}
+				if len(s) == 0 {
+					return c.frameError(stack, s, fmt.Errorf("unexpected end of array"))
+				}
+				if s[0] == ',' {
+					// Skip trailing comma.
+					if s, err = skipWSRelaxed(s[1:]); err != nil {
+						return c.frameError(stack, s, err)
+					}
+					if len(s) == 0 || s[0] != ']' {
+						continue parseNext
+					}
+				}
+				if s[0] != ']' {
+					return c.frameError(stack, s, fmt.Errorf("missing ',' after array value"))
+				}
+			} else {
+				o := &f.v.o
+				o.kvs[len(o.kvs)-1].v = v
+				if f.dup >= 0 {
+					c.dropDuplicateKey(o, f.dup)
+				}
+				if s, err = skipWSRelaxed(s); err != nil {
+					return c.frameError(stack, s, err)
+				}
+				if len(s) == 0 {
+					return c.frameError(stack, s, fmt.Errorf("unexpected end of object"))
+				}
+				if s[0] == ',' {
+					// Skip trailing comma.
+					if s, err = skipWSRelaxed(s[1:]); err != nil {
+						return c.frameError(stack, s, err)
+					}
+					if len(s) == 0 || s[0] != '}' {
+						if s, err = c.parseKeyRelaxed(f, s); err != nil {
+							return c.frameError(stack, s, err)
+						}
+						continue parseNext
+					}
+				}
+				if s[0] != '}' {
+					return c.frameError(stack, s, fmt.Errorf("missing ',' after object value"))
+				}
+				// Keys are unescaped during parsing.
+				o.keysUnescaped = true
+			}
+			s = s[1:]
+			v = f.v
+			if c.positions {
+				v = c.setPos(v, f.start, s)
+			}
+			stack = stack[:len(stack)-1]
+		}
+		c.stack = stack
+		return v, s, nil
+	}
+}
+
+// parseKeyRelaxed parses the next object key at s for the object in f.
+//
+// It returns the tail starting at the value for the key.
+func (c *cache) parseKeyRelaxed(f *parseFrame, s string) (string, error) {
+	s, err := skipWSRelaxed(s)
+	if err != nil {
+		return s, err
+	}
+	if len(s) == 0 {
+		return s, fmt.Errorf("missing object key")
+	}
+	o := &f.v.o
+	kv := c.getKV(o)
+	keyStart := s
+	if c.limits.MaxElements > 0 {
+		if err = c.checkElements(len(o.kvs)); err != nil {
+			return s, err
+		}
+	}
+	if s[0] == '"' || s[0] == '\'' {
+		kv.k, s, err = parseStringRelaxed(s, !c.positions)
+	} else {
+		kv.k, s, err = parseIdentifier(s)
+	}
+	if err != nil {
+		return s, fmt.Errorf("cannot parse object key: %s", err)
+	}
+	if c.limits.MaxStringLength > 0 {
+		if err = c.checkString(kv.k); err != nil {
+			return keyStart, fmt.Errorf("cannot parse object key: %s", err)
+		}
+	}
+	if kv.k, err = c.checkUTF8(kv.k); err != nil {
+		return keyStart, fmt.Errorf("cannot parse object key: %s", err)
+	}
+	if c.positions {
+		c.setKeyPos(o, kv.k, keyStart)
+	}
+	f.dup = -1
+	if c.duplicateKeys != DuplicateKeysAllow {
+		f.dup = c.duplicateKey(o)
+		if f.dup >= 0 && c.duplicateKeys == DuplicateKeysReject {
+			return keyStart, fmt.Errorf("duplicate key %q", kv.k)
+		}
+	}
+	if s, err = skipWSRelaxed(s); err != nil {
+		return s, err
+	}
+	if len(s) == 0 || s[0] != ':' {
+		return s, fmt.Errorf("missing ':' after object key")
+	}
+	return skipWSRelaxed(s[1:])
+}
+
+// parseIdentifier parses unquoted object key.
+func parseIdentifier(s string) (string, string, error) {
+	i := 0
+	for i < len(s) {
+		ch := s[i]
+		if (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || ch == '_' || ch == '$' || (i > 0 && ch >= '0' && ch <= '9') {
+			i++
+			continue
+		}
+		if ch < utf8.RuneSelf {
+			break
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if !unicode.IsLetter(r) && (i == 0 || (!unicode.IsDigit(r) && !unicode.IsMark(r))) {
+			break
+		}
+		i += size
+	}
+	if i == 0 {
+		return "", s, fmt.Errorf("unexpected char: %q", s[:1])
+	}
+	return s[:i], s[i:], nil
+}
+
+// parseStringRelaxed parses single-quoted or double-quoted string
+// and returns it in unescaped form.
+//
+// The string is unescaped in place if inPlace is set, so s must point
+// to Parser.b in this case. Otherwise it is unescaped into new memory.
+func parseStringRelaxed(s string, inPlace bool) (string, string, error) {
+	quote := s[0]
+	i := 1
+	for {
+		if i >= len(s) {
+			return "", "", fmt.Errorf("missing closing %q", quote)
+		}
+		ch := s[i]
+		if ch == quote {
+			break
+		}
+		switch ch {
+		case '\\':
+			if strings.HasPrefix(s[i+1:], "\r\n") {
+				// Line continuation with CRLF.
+				i++
+			}
+			i += 2
+		case '\n', '\r':
+			return "", s[i:], fmt.Errorf("unescaped line terminator in string")
+		default:
+			i++
+		}
+	}
+	ss, err := unescapeStringRelaxed(s[1:i], inPlace)
+	if err != nil {
+		return "", s[1:], err
+	}
+	return ss, s[i+1:], nil
+}
+
+// unescapeStringRelaxed unescapes JSON5 string s.
+//
+// See parseStringRelaxed for inPlace details.
+func unescapeStringRelaxed(s string, inPlace bool) (string, error) {
+	n := strings.IndexByte(s, '\\')
+	if n < 0 {
+		// Fast path - nothing to unescape.
+		return s, nil
+	}
+
+	// Slow path - unescape string.
+	var b []byte
+	if inPlace {
+		b = s2b(s) // It is safe to do, since s points to a byte slice in Parser.b.
+		b = b[:n]
+	} else {
+		b = make([]byte, n, len(s))
+		copy(b, s)
+	}
+	s = s[n+1:]
+	for len(s) > 0 {
+		ch := s[0]
+		s = s[1:]
+		switch ch {
+		case 'b':
+			b = append(b, '\b')
+		case 'f':
+			b = append(b, '\f')
+		case 'n':
+			b = append(b, '\n')
+		case 'r':
+			b = append(b, '\r')
+		case 't':
+			b = append(b, '\t')
+		case 'v':
+			b = append(b, '\v')
+		case '0':
+			if len(s) > 0 && s[0] >= '0' && s[0] <= '9' {
+				return "", fmt.Errorf("octal escape sequences aren't allowed")
+			}
+			b = append(b, 0)
+		case 'x', 'u':
+			size := 2
+			if ch == 'u' {
+				size = 4
+			}
+			if len(s) < size {
+				return "", fmt.Errorf("too short \\%c escape sequence", ch)
+			}
+			x, err := strconv.ParseUint(s[:size], 16, 16)
+			if err != nil {
+				return "", fmt.Errorf("invalid \\%c escape sequence: %q", ch, s[:size])
+			}
+			s = s[size:]
+			if ch == 'u' && utf16.IsSurrogate(rune(x)) && len(s) >= 6 && s[0] == '\\' && s[1] == 'u' {
+				// Decode UTF-16 surrogate pair.
+				x2, err := strconv.ParseUint(s[2:6], 16, 16)
+				if err == nil {
+					if r := utf16.DecodeRune(rune(x), rune(x2)); r != utf8.RuneError {
+						b = append(b, string(r)...)
+						s = s[6:]
+						break
+					}
+				}
+			}
+			b = append(b, string(rune(x))...)
+		case '\r':
+			// Line continuation.
+			if len(s) > 0 && s[0] == '\n' {
+				s = s[1:]
+			}
+		case '\n':
+			// Line continuation.
+		default:
+			if ch == '\xe2' && (strings.HasPrefix(s, "\x80\xa8") || strings.HasPrefix(s, "\x80\xa9")) {
+				// Line continuation with U+2028 or U+2029.
+				s = s[2:]
+				break
+			}
+			if ch >= '1' && ch <= '9' {
+				return "", fmt.Errorf("invalid escape sequence \\%c", ch)
+			}
+			// Any other escaped char represents itself.
+			b = append(b, ch)
+		}
+		n = strings.IndexByte(s, '\\')
+		if n < 0 {
+			b = append(b, s...)
+			break
+		}
+		b = append(b, s[:n]...)
+		s = s[n+1:]
+	}
+	return b2s(b), nil
+}
+
+// parseNumberRelaxed parses JSON5 number.
+//
+// It returns the raw number, its value and the tail.
+func parseNumberRelaxed(s string) (string, float64, string, error) {
+	i := 0
+	neg := false
+	if s[0] == '+' || s[0] == '-' {
+		neg = s[0] == '-'
+		i++
+	}
+	switch {
+	case strings.HasPrefix(s[i:], "Infinity"):
+		i += len("Infinity")
+		if neg {
+			return s[:i], math.Inf(-1), s[i:], nil
+		}
+		return s[:i], math.Inf(1), s[i:], nil
+	case strings.HasPrefix(s[i:], "NaN"):
+		i += len("NaN")
+		return s[:i], math.NaN(), s[i:], nil
+	case strings.HasPrefix(s[i:], "0x") || strings.HasPrefix(s[i:], "0X"):
+		i += 2
+		start := i
+		for i < len(s) && isHexChar(s[i]) {
+			i++
+		}
+		if i == start {
+			return "", 0, s, fmt.Errorf("missing hex digits in %q", s[:i])
+		}
+		x, err := strconv.ParseUint(s[start:i], 16, 64)
+		if err != nil {
+			return "", 0, s, fmt.Errorf("cannot parse hex number %q: %s", s[:i], err)
+		}
+		f := float64(x)
+		if neg {
+			f = -f
+		}
+		return s[:i], f, s[i:], nil
+	}
+
+	start := i
+	i = skipDigits(s, i)
+	digits := i - start
+	if i < len(s) && s[i] == '.' {
+		i++
+		n := i
+		i = skipDigits(s, i)
+		digits += i - n
+	}
+	if digits == 0 {
+		return "", 0, s, fmt.Errorf("unexpected char: %q", s[:1])
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			i++
+		}
+		n := i
+		i = skipDigits(s, i)
+		if i == n {
+			return "", 0, s, fmt.Errorf("missing exponent digits in %q", s[:i])
+		}
+	}
+	ns := s[:i]
+	f, err := strconv.ParseFloat(ns, 64)
+	if err != nil {
+		return "", 0, s, fmt.Errorf("cannot parse %q: %s", ns, err)
+	}
+	return ns, f, s[i:], nil
+}
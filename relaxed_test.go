@@ -0,0 +1,127 @@
+package fastjson
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParserRelaxedSuccess(t *testing.T) {
+	f := func(s, expectedS string) {
+		t.Helper()
+		p := &Parser{
+			Relaxed: true,
+		}
+		v, err := p.Parse(s)
+		if err != nil {
+			t.Fatalf("unexpected error when parsing %q: %s", s, err)
+		}
+		b := v.MarshalTo(nil)
+		if string(b) != expectedS {
+			t.Fatalf("unexpected value parsed from %q; got %s; want %s", s, b, expectedS)
+		}
+	}
+
+	// Standard JSON.
+	f(`{"foo":[1,"bar",true,false,null,{}]}`, `{"foo":[1,"bar",true,false,null,{}]}`)
+	f(` "a\nbA" `, `"a\nbA"`)
+
+	// Comments.
+	f("// comment\n123 // trailing", `123`)
+	f("/* block\ncomment */ [1, /* inner */ 2]/**/", `[1,2]`)
+	f("{\n  // key comment\n  \"a\": 1\n}", `{"a":1}`)
+
+	// Trailing commas.
+	f(`[1,2,]`, `[1,2]`)
+	f(`{"a":1,}`, `{"a":1}`)
+	f("[ [ ] , { } , ]", `[[],{}]`)
+
+	// Strings.
+	f(`'single'`, `"single"`)
+	f(`'it\'s "quoted"'`, `"it's \"quoted\""`)
+	f(`"\x41B\v\0\a"`, `"AB\u000b\u0000a"`)
+	f(`'\uD83D\uDE00 \ud83d'`, "\"\U0001f600 \ufffd\"")
+	f("'multi\\\nline\\\r\nstring'", `"multilinestring"`)
+	f("\"ls\\\u2028ps\\\u2029end\"", `"lspsend"`)
+
+	// Unquoted keys.
+	f(`{foo: 1, $bar_2: 'x', ключ: true}`, `{"foo":1,"$bar_2":"x","ключ":true}`)
+
+	// Numbers.
+	f(`0x1F`, `31`)
+	f(`-0XfF`, `-255`)
+	f(`+12`, `12`)
+	f(`.5`, `0.5`)
+	f(`5.`, `5`)
+	f(`1.5e3`, `1.5e3`)
+	f(`[Infinity, -Infinity, NaN]`, `[null,null,null]`)
+
+	// Whitespace.
+	f(" \u00a0\ufeff\u2028\v\f1 ", `1`)
+}
+
+func TestParserRelaxedNumbers(t *testing.T) {
+	p := &Parser{
+		Relaxed: true,
+	}
+	v, err := p.Parse(`{a: Infinity, b: -Infinity, c: NaN, d: 0x10, e: -.25}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if f := v.GetFloat64("a"); !math.IsInf(f, 1) {
+		t.Fatalf("unexpected value for a; got %v; want +Inf", f)
+	}
+	if f := v.GetFloat64("b"); !math.IsInf(f, -1) {
+		t.Fatalf("unexpected value for b; got %v; want -Inf", f)
+	}
+	if f := v.GetFloat64("c"); !math.IsNaN(f) {
+		t.Fatalf("unexpected value for c; got %v; want NaN", f)
+	}
+	if n := v.GetInt("d"); n != 16 {
+		t.Fatalf("unexpected value for d; got %d; want %d", n, 16)
+	}
+	if f := v.GetFloat64("e"); f != -0.25 {
+		t.Fatalf("unexpected value for e; got %v; want %v", f, -0.25)
+	}
+}
+
+func TestParserRelaxedError(t *testing.T) {
+	f := func(s string) {
+		t.Helper()
+		p := &Parser{
+			Relaxed: true,
+		}
+		if _, err := p.Parse(s); err == nil {
+			t.Fatalf("expecting non-nil error when parsing %q", s)
+		}
+	}
+
+	f(``)
+	f(`// only comment`)
+	f(`/* unclosed comment`)
+	f(`[1 /* unclosed`)
+	f(`[,]`)
+	f(`[1,,]`)
+	f(`{,}`)
+	f(`{a:1,,}`)
+	f(`{a}`)
+	f(`{1a: 2}`)
+	f(`'unclosed`)
+	f("'line\nbreak'")
+	f(`"\x4"`)
+	f(`"\u12"`)
+	f(`"\01"`)
+	f(`0x`)
+	f(`1e`)
+	f(`.`)
+	f(`Inf`)
+	f(`[1] 2`)
+}
+
+func TestParserRelaxedDisabled(t *testing.T) {
+	var p Parser
+	for _, s := range []string{`[1,]`, `{a:1}`, `'foo'`, "1 // comment"} {
+		if _, err := p.Parse(s); err == nil {
+			t.Fatalf("expecting non-nil error when parsing %q in default mode", s)
+		}
+	}
+}
@@ -2,11 +2,14 @@ package fastjson
 
 import (
 	"errors"
+	"fmt"
+	"strings"
 )
 
 // Scanner scans a series of JSON values. Values may be delimited by whitespace.
 //
 // Scanner may parse JSON lines ( http://jsonlines.org/ ).
+// Scanner may parse JSON text sequences in Sequence mode.
 //
 // Scanner may be re-used for subsequent parsing.
 //
@@ -14,6 +17,37 @@ import (
 //
 // Use Parser for parsing only a single JSON value.
 type Scanner struct {
+	// SkipInvalid enables recovery mode.
+	//
+	// In recovery mode Next skips invalid values instead of stopping
+	// on the first error. Scanning continues from the next line
+	// or the next record separator (0x1E) after the start of the invalid
+	// value, so the recovery mode is intended for line-delimited input
+	// such as JSON lines.
+	SkipInvalid bool
+
+	// ErrorHandler is called for each invalid value skipped
+	// in recovery mode if it is set.
+	//
+	// err cannot be held after returning from ErrorHandler.
+	ErrorHandler func(err *ScanError)
+
+	// Sequence enables parsing JSON text sequences
+	// ( https://tools.ietf.org/html/rfc7464 ), which are also known
+	// as application/json-seq. Each record in the sequence starts
+	// with the record separator (0x1E).
+	//
+	// Invalid and truncated records are always skipped in this mode
+	// as recommended by RFC 7464, regardless of SkipInvalid.
+	// Use ErrorHandler and BadRecords for tracking skipped records.
+	Sequence bool
+
+	// Limits limits resources used for parsing untrusted JSON.
+	//
+	// All the limits except MaxInputSize are applied to each value.
+	// There are no limits by default.
+	Limits Limits
+
 	// b contains a working copy of json value passed to Init.
 	b []byte
 
@@ -28,16 +62,86 @@ type Scanner struct {
 
 	// c is used for caching JSON values.
 	c cache
+
+	// offset is the offset of b in the whole input.
+	// It is non-zero if scanning has been started with InitAt.
+	offset int
+
+	// index is the number of records read so far.
+	index int
+
+	// goodRecords is the number of successfully parsed records.
+	goodRecords int
+
+	// badRecords is the number of invalid records skipped in recovery mode.
+	badRecords int
+
+	// scanErr is passed to ErrorHandler.
+	scanErr ScanError
+
+	// newCache returns the cache for parsing the next value if set.
+	//
+	// It allows holding the parsed values after the next Init call,
+	// so b isn't re-used in this case.
+	newCache func() *cache
+}
+
+// ScanError describes invalid value found by Scanner in recovery mode.
+type ScanError struct {
+	// Index is the zero-based index of the invalid record.
+	// Both valid and invalid records are counted.
+	Index int
+
+	// Offset is the byte offset of the invalid record start in the input.
+	// See Scanner.Offset for details.
+	Offset int
+
+	// Err is the parse error.
+	//
+	// It is *ParseError for invalid JSON.
+	Err error
+}
+
+// Error implements error interface.
+func (e *ScanError) Error() string {
+	return fmt.Sprintf("cannot parse record #%d at offset %d: %s", e.Index, e.Offset, e.Err)
 }
 
 // Init initializes sc with the given s.
 //
 // s may contain multiple JSON values, which may be delimited by whitespace.
 func (sc *Scanner) Init(s string) {
-	sc.b = append(sc.b[:0], s...)
+	sc.InitAt(s, 0, 0)
+}
+
+// InitAt initializes sc for resuming scanning at the checkpoint obtained
+// via Offset and Index calls.
+//
+// s must contain the input starting at the given offset, i.e. the part
+// of the input, which hasn't been consumed yet. Offset, Index and
+// ScanError values are counted from the start of the whole input,
+// so they may be used for subsequent checkpoints.
+func (sc *Scanner) InitAt(s string, offset, index int) {
+	var err error
+	if sc.Limits.MaxInputSize > 0 && len(s) > sc.Limits.MaxInputSize {
+		pe := newInputSizeError(sc.Limits.MaxInputSize)
+		pe.Offset += offset
+		err = pe
+		s = ""
+	}
+	if sc.newCache != nil {
+		// The parsed values may reference the previous b.
+		sc.b = append([]byte(nil), s...)
+	} else {
+		sc.b = append(sc.b[:0], s...)
+	}
 	sc.s = b2s(sc.b)
-	sc.err = nil
+	sc.err = err
 	sc.v = nil
+	sc.offset = offset
+	sc.index = index
+	sc.goodRecords = 0
+	sc.badRecords = 0
 }
 
 // InitBytes initializes sc with the given b.
@@ -57,23 +161,190 @@ func (sc *Scanner) Next() bool {
 	if sc.err != nil {
 		return false
 	}
+	if sc.Sequence {
+		return sc.nextSequence()
+	}
 
-	sc.s = skipWS(sc.s)
-	if len(sc.s) == 0 {
-		sc.err = errEOF
-		return false
+	for {
+		sc.s = skipWS(sc.s)
+		if len(sc.s) == 0 {
+			sc.err = errEOF
+			return false
+		}
+
+		c := sc.valueCache()
+		v, tail, err := parseValue(sc.s, c)
+		sc.index++
+		if err != nil {
+			err = sc.parseError(c, err, tail, sc.offset+len(sc.b))
+			if !sc.SkipInvalid {
+				sc.err = err
+				return false
+			}
+			sc.skipInvalid(err)
+			continue
+		}
+
+		sc.goodRecords++
+		sc.s = tail
+		sc.v = v
+		return true
 	}
+}
 
-	sc.c.reset()
-	v, tail, err := parseValue(sc.s, &sc.c)
-	if err != nil {
-		sc.err = err
-		return false
+func (sc *Scanner) skipInvalid(err error) {
+	sc.badRecord(err, sc.Offset())
+
+	// Resync to the next line or record separator.
+	n := strings.IndexAny(sc.s, "\n\x1e")
+	if n < 0 {
+		sc.s = ""
+		return
 	}
+	sc.s = sc.s[n+1:]
+}
+
+// recordSeparator starts each record in JSON text sequences.
+const recordSeparator = '\x1e'
+
+func (sc *Scanner) nextSequence() bool {
+	for {
+		if len(sc.s) == 0 {
+			sc.err = errEOF
+			return false
+		}
+
+		offset := sc.Offset()
+		missingRS := sc.s[0] != recordSeparator
+		if !missingRS {
+			sc.s = sc.s[1:]
+		}
+		record := sc.s
+		n := strings.IndexByte(sc.s, recordSeparator)
+		if n >= 0 {
+			record = sc.s[:n]
+			sc.s = sc.s[n:]
+		} else {
+			sc.s = ""
+		}
+
+		// Empty records are ignored.
+		record = skipWS(record)
+		if len(record) == 0 {
+			continue
+		}
+
+		sc.index++
+		if missingRS {
+			sc.badRecord(fmt.Errorf("missing record separator before %q", record), offset)
+			continue
+		}
+		c := sc.valueCache()
+		v, tail, err := parseValue(record, c)
+		if err != nil {
+			err = sc.parseError(c, err, tail, sc.Offset())
+		} else {
+			if len(skipWS(tail)) > 0 {
+				err = fmt.Errorf("unexpected tail: %q", skipWS(tail))
+			} else if len(tail) == 0 && record[0] != '{' && record[0] != '[' && record[0] != '"' {
+				// RFC 7464 requires treating numbers, true, false and null
+				// without trailing whitespace as truncated, since
+				// they cannot be distinguished from truncated values.
+				err = fmt.Errorf("possibly truncated value %q: missing whitespace after the value", record)
+			}
+		}
+		if err != nil {
+			sc.badRecord(err, offset)
+			continue
+		}
+
+		sc.goodRecords++
+		sc.v = v
+		return true
+	}
+}
+
+// valueCache returns the reset cache for parsing the next value.
+func (sc *Scanner) valueCache() *cache {
+	c := &sc.c
+	if sc.newCache != nil {
+		c = sc.newCache()
+
+		// The cache may be left with arbitrary settings
+		// by the previous user.
+		c.duplicateKeys = DuplicateKeysAllow
+		c.invalidUTF8 = InvalidUTF8Allow
+		c.positions = false
+	}
+	c.limits = sc.Limits
+	c.reset()
+	return c
+}
+
+// parseError returns ParseError for err returned from parseValue
+// called with c.
+//
+// tail must be the unparsed tail of the input ending at endOffset.
+func (sc *Scanner) parseError(c *cache, err error, tail string, endOffset int) error {
+	pe := &ParseError{
+		Offset: endOffset - len(tail),
+		Err:    err,
+		msg:    err.Error(),
+	}
+	if c.limitErr != nil {
+		pe.Err = c.limitErr
+	}
+	return pe
+}
+
+// badRecord registers invalid record at the given offset.
+func (sc *Scanner) badRecord(err error, offset int) {
+	sc.badRecords++
+	if sc.ErrorHandler != nil {
+		sc.scanErr = ScanError{
+			Index:  sc.index - 1,
+			Offset: offset,
+			Err:    err,
+		}
+		sc.ErrorHandler(&sc.scanErr)
+		sc.scanErr.Err = nil
+	}
+}
+
+// Offset returns the byte offset in the input, where the scanning stopped.
+//
+// The offset points to the end of the value returned by the last Next call.
+// The offset includes invalid records skipped in recovery mode.
+//
+// The offset is counted from the start of the whole input, i.e. it includes
+// the offset passed to InitAt.
+func (sc *Scanner) Offset() int {
+	return sc.offset + len(sc.b) - len(sc.s)
+}
+
+// Index returns the number of records read so far, including invalid
+// records skipped in recovery mode.
+//
+// The value returned by the last Next call has the zero-based index
+// Index()-1. The index is counted from the start of the whole input,
+// i.e. it includes the index passed to InitAt.
+//
+// Store Offset and Index after processing the value returned by Next
+// in order to resume scanning from this point via InitAt later.
+func (sc *Scanner) Index() int {
+	return sc.index
+}
+
+// GoodRecords returns the number of values successfully parsed
+// since the last Init or InitAt call.
+func (sc *Scanner) GoodRecords() int {
+	return sc.goodRecords
+}
 
-	sc.s = tail
-	sc.v = v
-	return true
+// BadRecords returns the number of invalid values skipped in recovery mode
+// since the last Init or InitAt call.
+func (sc *Scanner) BadRecords() int {
+	return sc.badRecords
 }
 
 // Error returns the last error.
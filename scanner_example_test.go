@@ -52,3 +52,29 @@ func ExampleScanner_reuse() {
 	// [1],"1",
 	// [2],"2",
 }
+
+func ExampleScanner_InitAt() {
+	input := `{"id":1} {"id":2} {"id":3}`
+
+	// Process the first two values and store the checkpoint.
+	var sc fastjson.Scanner
+	sc.Init(input)
+	for i := 0; i < 2 && sc.Next(); i++ {
+		fmt.Printf("id=%d\n", sc.Value().GetInt("id"))
+	}
+	offset, index := sc.Offset(), sc.Index()
+
+	// Resume scanning from the checkpoint, e.g. after restart.
+	sc.InitAt(input[offset:], offset, index)
+	for sc.Next() {
+		fmt.Printf("id=%d, record=%d, offset=%d\n", sc.Value().GetInt("id"), sc.Index()-1, sc.Offset())
+	}
+	if err := sc.Error(); err != nil {
+		log.Fatalf("unexpected error: %s", err)
+	}
+
+	// Output:
+	// id=1
+	// id=2
+	// id=3, record=2, offset=26
+}
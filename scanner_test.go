@@ -3,6 +3,8 @@ package fastjson
 import (
 	"bytes"
 	"fmt"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -37,3 +39,209 @@ func TestScanner(t *testing.T) {
 		}
 	})
 }
+
+func TestScannerSkipInvalid(t *testing.T) {
+	var errs []string
+	sc := &Scanner{
+		SkipInvalid: true,
+		ErrorHandler: func(err *ScanError) {
+			errs = append(errs, fmt.Sprintf("%d:%d", err.Index, err.Offset))
+		},
+	}
+
+	sc.Init("{\"a\":1}\n{\"a\": bad}\ngarbage\x1e[2]\n\"x\" tail\n{\"a\":3")
+	var bb bytes.Buffer
+	for sc.Next() {
+		fmt.Fprintf(&bb, "%s,", sc.Value())
+	}
+	if err := sc.Error(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	s := bb.String()
+	if s != `{"a":1},[2],"x",` {
+		t.Fatalf("unexpected values obtained; got %q; want %q", s, `{"a":1},[2],"x",`)
+	}
+	e := strings.Join(errs, " ")
+	if e != "1:8 2:19 5:35 6:40" {
+		t.Fatalf("unexpected errors; got %q; want %q", e, "1:8 2:19 5:35 6:40")
+	}
+	if n := sc.GoodRecords(); n != 3 {
+		t.Fatalf("unexpected number of good records; got %d; want %d", n, 3)
+	}
+	if n := sc.BadRecords(); n != 4 {
+		t.Fatalf("unexpected number of bad records; got %d; want %d", n, 4)
+	}
+
+	// Counters must be reset on Init.
+	sc.Init(`1 2`)
+	for sc.Next() {
+	}
+	if sc.GoodRecords() != 2 || sc.BadRecords() != 0 {
+		t.Fatalf("unexpected counters after Init; got good=%d, bad=%d; want good=2, bad=0", sc.GoodRecords(), sc.BadRecords())
+	}
+}
+
+func TestScanErrorError(t *testing.T) {
+	var sc Scanner
+	sc.SkipInvalid = true
+	var msg string
+	sc.ErrorHandler = func(err *ScanError) {
+		msg = err.Error()
+	}
+	sc.Init("123\nfoo\n")
+	for sc.Next() {
+	}
+	if !strings.HasPrefix(msg, "cannot parse record #1 at offset 4: ") {
+		t.Fatalf("unexpected error message: %q", msg)
+	}
+}
+
+func TestScannerSequence(t *testing.T) {
+	var errs []string
+	sc := &Scanner{
+		Sequence: true,
+		ErrorHandler: func(err *ScanError) {
+			errs = append(errs, fmt.Sprintf("%d:%d", err.Index, err.Offset))
+		},
+	}
+
+	f := func(s, expectedValues, expectedErrs string, good, bad int) {
+		t.Helper()
+		errs = errs[:0]
+		sc.Init(s)
+		var bb bytes.Buffer
+		for sc.Next() {
+			fmt.Fprintf(&bb, "%s,", sc.Value())
+		}
+		if err := sc.Error(); err != nil {
+			t.Fatalf("unexpected error for %q: %s", s, err)
+		}
+		if bb.String() != expectedValues {
+			t.Fatalf("unexpected values for %q; got %q; want %q", s, bb.String(), expectedValues)
+		}
+		if e := strings.Join(errs, " "); e != expectedErrs {
+			t.Fatalf("unexpected errors for %q; got %q; want %q", s, e, expectedErrs)
+		}
+		if sc.GoodRecords() != good || sc.BadRecords() != bad {
+			t.Fatalf("unexpected counters for %q; got good=%d, bad=%d; want good=%d, bad=%d", s, sc.GoodRecords(), sc.BadRecords(), good, bad)
+		}
+	}
+
+	f("", "", "", 0, 0)
+	f("\x1e\n\x1e\x1e  ", "", "", 0, 0)
+	f("\x1e{\"a\":1}\n\x1e[1, 2]\n\x1e\"x\"\n\x1e123\n\x1etrue\n\x1enull\n", `{"a":1},[1,2],"x",123,true,null,`, "", 6, 0)
+
+	// Values may span multiple lines.
+	f("\x1e{\n\"a\": [\n1\n]\n}\n\x1e2\n", `{"a":[1]},2,`, "", 2, 0)
+
+	// Truncated records.
+	f("\x1e{\"a\":\x1e[1]\n\x1e[1,\n\x1e\"x\"\n", `[1],"x",`, "0:0 2:11", 2, 2)
+
+	// Truncated numbers, true, false and null at the end of the record.
+	f("\x1e12\x1e1\n\x1etru\x1etrue\x1enull", `1,`, "0:0 2:6 3:10 4:15", 1, 4)
+
+	// Multiple values in a record.
+	f("\x1e1 2\n\x1e3\n", `3,`, "0:0", 1, 1)
+
+	// Data before the first record separator.
+	f("garbage\n\x1e1\n", `1,`, "0:0", 1, 1)
+	f(" \n\x1e1\n", `1,`, "", 1, 0)
+}
+
+func TestScannerInitAt(t *testing.T) {
+	f := func(sequence bool, s string) {
+		t.Helper()
+
+		type record struct {
+			value  string
+			offset int
+			index  int
+		}
+		scan := func(sc *Scanner) []record {
+			var records []record
+			for sc.Next() {
+				records = append(records, record{
+					value:  sc.Value().String(),
+					offset: sc.Offset(),
+					index:  sc.Index(),
+				})
+			}
+			if err := sc.Error(); err != nil {
+				t.Fatalf("unexpected error for %q: %s", s, err)
+			}
+			return records
+		}
+
+		var errs []string
+		sc := &Scanner{
+			SkipInvalid: true,
+			Sequence:    sequence,
+			ErrorHandler: func(err *ScanError) {
+				errs = append(errs, fmt.Sprintf("%d:%d", err.Index, err.Offset))
+			},
+		}
+		sc.Init(s)
+		records := scan(sc)
+		allErrs := append([]string{}, errs...)
+		if sc.Offset() != len(s) {
+			t.Fatalf("unexpected offset at the end of %q; got %d; want %d", s, sc.Offset(), len(s))
+		}
+
+		// Resume scanning from every checkpoint.
+		for i, r := range records {
+			errs = errs[:0]
+			sc.InitAt(s[r.offset:], r.offset, r.index)
+			tail := scan(sc)
+			if len(tail) != len(records)-i-1 || (len(tail) > 0 && !reflect.DeepEqual(tail, records[i+1:])) {
+				t.Fatalf("unexpected records after resuming %q at %d;\ngot\n%v\nwant\n%v", s, r.offset, tail, records[i+1:])
+			}
+			var expectedErrs []string
+			for _, e := range allErrs {
+				var index, offset int
+				fmt.Sscanf(e, "%d:%d", &index, &offset)
+				if offset >= r.offset {
+					expectedErrs = append(expectedErrs, e)
+				}
+			}
+			if strings.Join(errs, " ") != strings.Join(expectedErrs, " ") {
+				t.Fatalf("unexpected errors after resuming %q at %d; got %q; want %q", s, r.offset, errs, expectedErrs)
+			}
+		}
+	}
+
+	f(false, `1 2 [3] {"a":4}  "5"`)
+	f(false, "{\"a\":1}\nbad\n[2]\n{\"b\n3\n")
+	f(true, "\x1e1\n\x1e[2]\n\x1e{\"a\n\x1e\"3\"\n\x1e4")
+}
+
+func TestScannerOffsetIndex(t *testing.T) {
+	var sc Scanner
+	sc.Init(` 12 "ab" []`)
+	if sc.Offset() != 0 || sc.Index() != 0 {
+		t.Fatalf("unexpected position after Init; got offset=%d, index=%d; want offset=0, index=0", sc.Offset(), sc.Index())
+	}
+	expected := []struct {
+		offset int
+		index  int
+	}{
+		{3, 1},
+		{8, 2},
+		{11, 3},
+	}
+	for _, e := range expected {
+		if !sc.Next() {
+			t.Fatalf("unexpected end of values: %v", sc.Error())
+		}
+		if sc.Offset() != e.offset || sc.Index() != e.index {
+			t.Fatalf("unexpected position for %s; got offset=%d, index=%d; want offset=%d, index=%d", sc.Value(), sc.Offset(), sc.Index(), e.offset, e.index)
+		}
+	}
+
+	sc.InitAt(`"x"`, 100, 10)
+	if !sc.Next() {
+		t.Fatalf("unexpected error: %v", sc.Error())
+	}
+	if sc.Offset() != 103 || sc.Index() != 11 {
+		t.Fatalf("unexpected position; got offset=%d, index=%d; want offset=103, index=11", sc.Offset(), sc.Index())
+	}
+}
@@ -0,0 +1,510 @@
+// Package schema validates JSON values parsed by fastjson against JSON Schema.
+//
+// The following keywords from JSON Schema draft 2020-12
+// ( https://json-schema.org/draft/2020-12/json-schema-validation.html )
+// are supported:
+//
+//   - type, enum, const
+//   - multipleOf, minimum, maximum, exclusiveMinimum, exclusiveMaximum
+//   - minLength, maxLength, pattern
+//   - items, prefixItems, contains, minItems, maxItems, uniqueItems
+//   - properties, patternProperties, additionalProperties, required,
+//     minProperties, maxProperties
+//   - allOf, anyOf, oneOf, not
+//   - $ref pointing inside the schema document, $defs
+//
+// Other keywords are ignored.
+//
+// Compile rejects schemas applying themselves to the same value in a loop,
+// e.g. via $ref pointing to itself, since their validation would never finish.
+package schema
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/valyala/fastjson"
+)
+
+// Schema is a compiled JSON Schema.
+//
+// Schema may be used from concurrent goroutines.
+type Schema struct {
+	// p holds the parsed schema document referenced by the compiled nodes.
+	p fastjson.Parser
+
+	root *node
+}
+
+// node is a compiled schema.
+type node struct {
+	// location is JSON Pointer to the schema in the schema document.
+	location string
+
+	// alwaysFalse is set for false boolean schema.
+	alwaysFalse bool
+
+	types typeMask
+
+	enum     []*fastjson.Value
+	constVal *fastjson.Value
+
+	multipleOf       float64
+	minimum          float64
+	maximum          float64
+	exclusiveMinimum float64
+	exclusiveMaximum float64
+	hasMinimum       bool
+	hasMaximum       bool
+	hasExclusiveMin  bool
+	hasExclusiveMax  bool
+
+	minLength int
+	maxLength int
+	pattern   *regexp.Regexp
+
+	items       *node
+	prefixItems []*node
+	contains    *node
+	minItems    int
+	maxItems    int
+	uniqueItems bool
+
+	properties           []property
+	patternProperties    []patternProperty
+	additionalProperties *node
+	required             []string
+	minProperties        int
+	maxProperties        int
+
+	allOf []*node
+	anyOf []*node
+	oneOf []*node
+	not   *node
+
+	ref       string
+	refTarget *node
+}
+
+type property struct {
+	name   string
+	schema *node
+}
+
+type patternProperty struct {
+	re     *regexp.Regexp
+	schema *node
+}
+
+type typeMask uint8
+
+const (
+	typeNull typeMask = 1 << iota
+	typeBoolean
+	typeObject
+	typeArray
+	typeNumber
+	typeInteger
+	typeString
+)
+
+var typeNames = map[string]typeMask{
+	"null":    typeNull,
+	"boolean": typeBoolean,
+	"object":  typeObject,
+	"array":   typeArray,
+	"number":  typeNumber,
+	"integer": typeInteger,
+	"string":  typeString,
+}
+
+// Compile compiles JSON Schema s.
+func Compile(s string) (*Schema, error) {
+	var sch Schema
+	doc, err := sch.p.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse schema: %s", err)
+	}
+
+	// The schema document is accessed from concurrent goroutines
+	// during validation.
+	doc.Freeze()
+
+	c := &compiler{
+		doc:   doc,
+		nodes: make(map[string]*node),
+	}
+	root, err := c.compile(doc, "")
+	if err != nil {
+		return nil, err
+	}
+	for len(c.refs) > 0 {
+		nd := c.refs[len(c.refs)-1]
+		c.refs = c.refs[:len(c.refs)-1]
+		if nd.refTarget, err = c.resolveRef(nd.ref); err != nil {
+			return nil, fmt.Errorf("cannot resolve $ref %q at %q: %s", nd.ref, nd.location, err)
+		}
+	}
+	if err := c.checkLoops(); err != nil {
+		return nil, err
+	}
+	sch.root = root
+	return &sch, nil
+}
+
+// MustCompile is like Compile but panics on error.
+func MustCompile(s string) *Schema {
+	sch, err := Compile(s)
+	if err != nil {
+		panic(err)
+	}
+	return sch
+}
+
+type compiler struct {
+	doc *fastjson.Value
+
+	// nodes contains compiled schemas keyed by their locations.
+	nodes map[string]*node
+
+	// refs contains nodes with unresolved $ref.
+	refs []*node
+}
+
+func (c *compiler) compile(v *fastjson.Value, location string) (*node, error) {
+	if nd := c.nodes[location]; nd != nil {
+		return nd, nil
+	}
+	nd := &node{
+		location:  location,
+		minLength: -1,
+		maxLength: -1,
+		minItems:  -1,
+		maxItems:  -1,
+
+		minProperties: -1,
+		maxProperties: -1,
+	}
+	c.nodes[location] = nd
+
+	switch v.Type() {
+	case fastjson.TypeTrue:
+		return nd, nil
+	case fastjson.TypeFalse:
+		nd.alwaysFalse = true
+		return nd, nil
+	case fastjson.TypeObject:
+	default:
+		return nil, fmt.Errorf("schema at %q must be object or boolean; got %s", location, v.Type())
+	}
+
+	var err error
+	o := v.GetObject()
+	o.Visit(func(k []byte, kv *fastjson.Value) {
+		if err != nil {
+			return
+		}
+		err = c.compileKeyword(nd, string(k), kv)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return nd, nil
+}
+
+func (c *compiler) compileKeyword(nd *node, keyword string, v *fastjson.Value) error {
+	location := nd.location + "/" + escapePointerToken(keyword)
+	var err error
+	switch keyword {
+	case "type":
+		nd.types, err = compileTypes(v)
+	case "enum":
+		nd.enum, err = v.Array()
+	case "const":
+		nd.constVal = v
+	case "multipleOf":
+		nd.multipleOf, err = getNumber(v)
+		if err == nil && nd.multipleOf <= 0 {
+			err = fmt.Errorf("must be greater than 0")
+		}
+	case "minimum":
+		nd.minimum, err = getNumber(v)
+		nd.hasMinimum = true
+	case "maximum":
+		nd.maximum, err = getNumber(v)
+		nd.hasMaximum = true
+	case "exclusiveMinimum":
+		nd.exclusiveMinimum, err = getNumber(v)
+		nd.hasExclusiveMin = true
+	case "exclusiveMaximum":
+		nd.exclusiveMaximum, err = getNumber(v)
+		nd.hasExclusiveMax = true
+	case "minLength":
+		nd.minLength, err = getCount(v)
+	case "maxLength":
+		nd.maxLength, err = getCount(v)
+	case "pattern":
+		nd.pattern, err = compilePattern(v)
+	case "items":
+		nd.items, err = c.compile(v, location)
+	case "prefixItems":
+		nd.prefixItems, err = c.compileList(v, location)
+	case "contains":
+		nd.contains, err = c.compile(v, location)
+	case "minItems":
+		nd.minItems, err = getCount(v)
+	case "maxItems":
+		nd.maxItems, err = getCount(v)
+	case "uniqueItems":
+		nd.uniqueItems, err = v.Bool()
+	case "properties":
+		var o *fastjson.Object
+		if o, err = v.Object(); err != nil {
+			break
+		}
+		o.Visit(func(k []byte, pv *fastjson.Value) {
+			if err != nil {
+				return
+			}
+			name := string(k)
+			var ps *node
+			ps, err = c.compile(pv, location+"/"+escapePointerToken(name))
+			nd.properties = append(nd.properties, property{
+				name:   name,
+				schema: ps,
+			})
+		})
+	case "patternProperties":
+		var o *fastjson.Object
+		if o, err = v.Object(); err != nil {
+			break
+		}
+		o.Visit(func(k []byte, pv *fastjson.Value) {
+			if err != nil {
+				return
+			}
+			var pp patternProperty
+			if pp.re, err = regexp.Compile(string(k)); err != nil {
+				return
+			}
+			pp.schema, err = c.compile(pv, location+"/"+escapePointerToken(string(k)))
+			nd.patternProperties = append(nd.patternProperties, pp)
+		})
+	case "additionalProperties":
+		nd.additionalProperties, err = c.compile(v, location)
+	case "required":
+		var a []*fastjson.Value
+		if a, err = v.Array(); err != nil {
+			break
+		}
+		for _, rv := range a {
+			var sb []byte
+			if sb, err = rv.StringBytes(); err != nil {
+				break
+			}
+			nd.required = append(nd.required, string(sb))
+		}
+	case "minProperties":
+		nd.minProperties, err = getCount(v)
+	case "maxProperties":
+		nd.maxProperties, err = getCount(v)
+	case "allOf":
+		nd.allOf, err = c.compileList(v, location)
+	case "anyOf":
+		nd.anyOf, err = c.compileList(v, location)
+	case "oneOf":
+		nd.oneOf, err = c.compileList(v, location)
+	case "not":
+		nd.not, err = c.compile(v, location)
+	case "$ref":
+		var sb []byte
+		if sb, err = v.StringBytes(); err != nil {
+			break
+		}
+		nd.ref = string(sb)
+		c.refs = append(c.refs, nd)
+	case "$defs", "definitions":
+		var o *fastjson.Object
+		if o, err = v.Object(); err != nil {
+			break
+		}
+		o.Visit(func(k []byte, dv *fastjson.Value) {
+			if err != nil {
+				return
+			}
+			_, err = c.compile(dv, location+"/"+escapePointerToken(string(k)))
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %q at %q: %s", keyword, nd.location, err)
+	}
+	return nil
+}
+
+func (c *compiler) compileList(v *fastjson.Value, location string) ([]*node, error) {
+	a, err := v.Array()
+	if err != nil {
+		return nil, err
+	}
+	if len(a) == 0 {
+		return nil, fmt.Errorf("array must be non-empty")
+	}
+	nds := make([]*node, len(a))
+	for i, sv := range a {
+		nds[i], err = c.compile(sv, location+"/"+strconv.Itoa(i))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return nds, nil
+}
+
+// checkLoops returns an error if a compiled schema applies itself
+// to the same value via $ref, allOf, anyOf, oneOf or not.
+//
+// Validation against such a schema would never finish.
+func (c *compiler) checkLoops() error {
+	locations := make([]string, 0, len(c.nodes))
+	for location := range c.nodes {
+		locations = append(locations, location)
+	}
+	sort.Strings(locations)
+
+	// visiting contains nodes on the current path, while checked contains
+	// nodes without loops.
+	visiting := make(map[*node]bool)
+	checked := make(map[*node]bool)
+	var visit func(nd *node) error
+	visit = func(nd *node) error {
+		if checked[nd] {
+			return nil
+		}
+		if visiting[nd] {
+			return fmt.Errorf("schema at %q applies itself to the same value in a loop via $ref, allOf, anyOf, oneOf or not", nd.location)
+		}
+		visiting[nd] = true
+		for _, sub := range nd.inPlaceSubschemas() {
+			if err := visit(sub); err != nil {
+				return err
+			}
+		}
+		visiting[nd] = false
+		checked[nd] = true
+		return nil
+	}
+	for _, location := range locations {
+		if err := visit(c.nodes[location]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inPlaceSubschemas returns subschemas applied to the same value as nd.
+func (nd *node) inPlaceSubschemas() []*node {
+	var nds []*node
+	if nd.refTarget != nil {
+		nds = append(nds, nd.refTarget)
+	}
+	nds = append(nds, nd.allOf...)
+	nds = append(nds, nd.anyOf...)
+	nds = append(nds, nd.oneOf...)
+	if nd.not != nil {
+		nds = append(nds, nd.not)
+	}
+	return nds
+}
+
+// resolveRef returns the schema referenced by ref.
+//
+// Only references to the current document are supported.
+func (c *compiler) resolveRef(ref string) (*node, error) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, fmt.Errorf("only references inside the schema document are supported")
+	}
+	location := ref[1:]
+	if nd := c.nodes[location]; nd != nil {
+		return nd, nil
+	}
+
+	// The referenced schema isn't compiled yet. Find it in the document.
+	if len(location) > 0 && location[0] != '/' {
+		return nil, fmt.Errorf("invalid JSON Pointer %q", location)
+	}
+	v := c.doc
+	for _, token := range strings.Split(location, "/")[1:] {
+		token = strings.Replace(token, "~1", "/", -1)
+		token = strings.Replace(token, "~0", "~", -1)
+		v = v.Get(token)
+		if v == nil {
+			return nil, fmt.Errorf("cannot find schema at %q", location)
+		}
+	}
+	return c.compile(v, location)
+}
+
+func compileTypes(v *fastjson.Value) (typeMask, error) {
+	if v.Type() == fastjson.TypeString {
+		return compileType(v)
+	}
+	a, err := v.Array()
+	if err != nil {
+		return 0, fmt.Errorf("must be string or array of strings")
+	}
+	var types typeMask
+	for _, tv := range a {
+		t, err := compileType(tv)
+		if err != nil {
+			return 0, err
+		}
+		types |= t
+	}
+	return types, nil
+}
+
+func compileType(v *fastjson.Value) (typeMask, error) {
+	sb, err := v.StringBytes()
+	if err != nil {
+		return 0, err
+	}
+	t, ok := typeNames[string(sb)]
+	if !ok {
+		return 0, fmt.Errorf("unknown type %q", sb)
+	}
+	return t, nil
+}
+
+func compilePattern(v *fastjson.Value) (*regexp.Regexp, error) {
+	sb, err := v.StringBytes()
+	if err != nil {
+		return nil, err
+	}
+	return regexp.Compile(string(sb))
+}
+
+func getNumber(v *fastjson.Value) (float64, error) {
+	return v.Float64()
+}
+
+func getCount(v *fastjson.Value) (int, error) {
+	f, err := v.Float64()
+	if err != nil {
+		return 0, err
+	}
+	if f < 0 || f != math.Trunc(f) {
+		return 0, fmt.Errorf("must be non-negative integer; got %v", f)
+	}
+	return int(f), nil
+}
+
+func escapePointerToken(s string) string {
+	if strings.IndexByte(s, '~') < 0 && strings.IndexByte(s, '/') < 0 {
+		return s
+	}
+	s = strings.Replace(s, "~", "~0", -1)
+	return strings.Replace(s, "/", "~1", -1)
+}
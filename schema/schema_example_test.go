@@ -0,0 +1,42 @@
+package schema_test
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/valyala/fastjson"
+	"github.com/valyala/fastjson/schema"
+)
+
+func ExampleSchema_Validate() {
+	sch, err := schema.Compile(`{
+		"type": "object",
+		"properties": {
+			"id": {"type": "integer", "minimum": 1},
+			"tags": {"type": "array", "items": {"$ref": "#/$defs/tag"}}
+		},
+		"required": ["id"],
+		"$defs": {
+			"tag": {"type": "string", "pattern": "^[a-z]+$"}
+		}
+	}`)
+	if err != nil {
+		log.Fatalf("cannot compile schema: %s", err)
+	}
+
+	var p fastjson.Parser
+	v, err := p.Parse(`{"id":0,"tags":["foo","Bar"]}`)
+	if err != nil {
+		log.Fatalf("cannot parse value: %s", err)
+	}
+	err = sch.Validate(v)
+	if ve, ok := err.(*schema.ValidationError); ok {
+		for _, e := range ve.Errors {
+			fmt.Printf("%s: %s\n", e.InstancePath, e.Message)
+		}
+	}
+
+	// Output:
+	// /id: 0 is smaller than 1
+	// /tags/1: string "Bar" doesn't match pattern "^[a-z]+$"
+}
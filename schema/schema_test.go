@@ -0,0 +1,303 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/valyala/fastjson"
+)
+
+func TestCompileError(t *testing.T) {
+	f := func(s string) {
+		t.Helper()
+		sch, err := Compile(s)
+		if err == nil {
+			t.Fatalf("expecting non-nil error when compiling %q", s)
+		}
+		if sch != nil {
+			t.Fatalf("expecting nil schema when compiling %q", s)
+		}
+	}
+	f(``)
+	f(`[`)
+	f(`123`)
+	f(`"foo"`)
+	f(`{"type":"foo"}`)
+	f(`{"type":123}`)
+	f(`{"type":["string",1]}`)
+	f(`{"enum":1}`)
+	f(`{"minLength":-1}`)
+	f(`{"minLength":1.5}`)
+	f(`{"multipleOf":0}`)
+	f(`{"pattern":"("}`)
+	f(`{"patternProperties":{"(":{}}}`)
+	f(`{"properties":{"a":1}}`)
+	f(`{"required":[1]}`)
+	f(`{"allOf":[]}`)
+	f(`{"anyOf":{}}`)
+	f(`{"items":"foo"}`)
+	f(`{"$ref":"#/$defs/missing"}`)
+	f(`{"$ref":"http://example.com/schema"}`)
+	f(`{"$ref":"#foo"}`)
+
+	// Loops applying a schema to the same value.
+	f(`{"$ref":"#"}`)
+	f(`{"$defs":{"a":{"$ref":"#/$defs/a"}},"$ref":"#/$defs/a"}`)
+	f(`{"$defs":{"a":{"$ref":"#/$defs/b"},"b":{"allOf":[{"$ref":"#/$defs/a"}]}},"properties":{"x":{"$ref":"#/$defs/a"}}}`)
+	f(`{"anyOf":[{"type":"string"},{"not":{"$ref":"#"}}]}`)
+}
+
+func TestCompileRecursiveSchema(t *testing.T) {
+	// Recursion via subschemas applied to nested values is allowed.
+	sch, err := Compile(`{"type":"object","properties":{"children":{"type":"array","items":{"$ref":"#"}}},"allOf":[{"$ref":"#/$defs/named"}],"$defs":{"named":{"required":["name"]}}}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := sch.ValidateBytes([]byte(`{"name":"a","children":[{"name":"b","children":[]}]}`)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := sch.ValidateBytes([]byte(`{"name":"a","children":[{"children":[]}]}`)); err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	f := func(schema, s string, expectedPaths ...string) {
+		t.Helper()
+		sch, err := Compile(schema)
+		if err != nil {
+			t.Fatalf("cannot compile %q: %s", schema, err)
+		}
+		var p fastjson.Parser
+		v, err := p.Parse(s)
+		if err != nil {
+			t.Fatalf("cannot parse %q: %s", s, err)
+		}
+		err = sch.Validate(v)
+		if len(expectedPaths) == 0 {
+			if err != nil {
+				t.Fatalf("unexpected error when validating %s against %s: %s", s, schema, err)
+			}
+			return
+		}
+		if err == nil {
+			t.Fatalf("expecting non-nil error when validating %s against %s", s, schema)
+		}
+		ve, ok := err.(*ValidationError)
+		if !ok {
+			t.Fatalf("unexpected error type: %T", err)
+		}
+		var paths []string
+		for _, e := range ve.Errors {
+			paths = append(paths, e.InstancePath)
+		}
+		if strings.Join(paths, ",") != strings.Join(expectedPaths, ",") {
+			t.Fatalf("unexpected error paths when validating %s against %s; got %q; want %q; err: %s", s, schema, paths, expectedPaths, err)
+		}
+	}
+
+	// boolean schemas
+	f(`true`, `123`)
+	f(`false`, `123`, "")
+	f(`{}`, `{"a":[1,2]}`)
+
+	// type
+	f(`{"type":"string"}`, `"foo"`)
+	f(`{"type":"string"}`, `123`, "")
+	f(`{"type":"integer"}`, `123`)
+	f(`{"type":"integer"}`, `1.0`)
+	f(`{"type":"integer"}`, `1.5`, "")
+	f(`{"type":"number"}`, `1.5`)
+	f(`{"type":"boolean"}`, `false`)
+	f(`{"type":"boolean"}`, `null`, "")
+	f(`{"type":["null","array"]}`, `null`)
+	f(`{"type":["null","array"]}`, `[]`)
+	f(`{"type":["null","array"]}`, `{}`, "")
+
+	// enum and const
+	f(`{"enum":[1,"foo",{"a":[1,2]}]}`, `1.0`)
+	f(`{"enum":[1,"foo",{"a":[1,2]}]}`, `"foo"`)
+	f(`{"enum":[1,"foo",{"a":[1,2]}]}`, `{"a":[1,2]}`)
+	f(`{"enum":[1,"foo",{"a":[1,2]}]}`, `{"a":[2,1]}`, "")
+	f(`{"enum":[1,"foo",{"a":[1,2]}]}`, `"1"`, "")
+	f(`{"const":{"x":null,"y":"a"}}`, `{"y":"a","x":null}`)
+	f(`{"const":{"x":null}}`, `{"x":false}`, "")
+
+	// numbers
+	f(`{"minimum":1,"maximum":3}`, `1`)
+	f(`{"minimum":1,"maximum":3}`, `3`)
+	f(`{"minimum":1,"maximum":3}`, `0`, "")
+	f(`{"minimum":1,"maximum":3}`, `4`, "")
+	f(`{"exclusiveMinimum":1,"exclusiveMaximum":3}`, `2`)
+	f(`{"exclusiveMinimum":1,"exclusiveMaximum":3}`, `1`, "")
+	f(`{"exclusiveMinimum":1,"exclusiveMaximum":3}`, `3`, "")
+	f(`{"multipleOf":0.1}`, `0.3`)
+	f(`{"multipleOf":2}`, `3`, "")
+	f(`{"minimum":10}`, `"foo"`)
+
+	// strings
+	f(`{"minLength":2,"maxLength":3}`, `"ab"`)
+	f(`{"minLength":2,"maxLength":3}`, `"абв"`)
+	f(`{"minLength":2,"maxLength":3}`, `"a"`, "")
+	f(`{"minLength":2,"maxLength":3}`, `"abcd"`, "")
+	f(`{"pattern":"^[a-z]+$"}`, `"foo"`)
+	f(`{"pattern":"^[a-z]+$"}`, `"foo1"`, "")
+	f(`{"pattern":"o"}`, `"foo"`)
+
+	// arrays
+	f(`{"items":{"type":"number"}}`, `[1,2,3]`)
+	f(`{"items":{"type":"number"}}`, `[1,"x",3,null]`, "/1", "/3")
+	f(`{"prefixItems":[{"type":"string"}],"items":{"type":"number"}}`, `["a",1,2]`)
+	f(`{"prefixItems":[{"type":"string"}],"items":{"type":"number"}}`, `[1,"a"]`, "/0", "/1")
+	f(`{"prefixItems":[{"type":"string"}],"items":false}`, `["a",1]`, "/1")
+	f(`{"minItems":1,"maxItems":2}`, `[]`, "")
+	f(`{"minItems":1,"maxItems":2}`, `[1,2,3]`, "")
+	f(`{"uniqueItems":true}`, `[1,"1",{"a":1},{"a":2}]`)
+	f(`{"uniqueItems":true}`, `[1,{"a":1},{"a":1.0}]`, "")
+	f(`{"contains":{"type":"string"}}`, `[1,"a"]`)
+	f(`{"contains":{"type":"string"}}`, `[1,2]`, "")
+
+	// objects
+	f(`{"required":["a","b"]}`, `{"a":1,"b":2}`)
+	f(`{"required":["a","b"]}`, `{"a":1}`, "")
+	f(`{"required":["a"]}`, `[1]`)
+	f(`{"properties":{"a":{"type":"string"},"b/c":{"type":"number"}}}`, `{"a":"x","b/c":1,"d":null}`)
+	f(`{"properties":{"a":{"type":"string"},"b/c":{"type":"number"}}}`, `{"a":1,"b/c":"x"}`, "/a", "/b~1c")
+	f(`{"properties":{"a":{"type":"string"}},"additionalProperties":false}`, `{"a":"x","b":1}`, "/b")
+	f(`{"patternProperties":{"^x-":{"type":"string"}},"additionalProperties":{"type":"number"}}`, `{"x-a":"foo","b":1}`)
+	f(`{"patternProperties":{"^x-":{"type":"string"}},"additionalProperties":{"type":"number"}}`, `{"x-a":1,"b":"foo"}`, "/x-a", "/b")
+	f(`{"minProperties":1,"maxProperties":1}`, `{}`, "")
+	f(`{"minProperties":1,"maxProperties":1}`, `{"a":1,"b":2}`, "")
+
+	// nested paths
+	f(`{"properties":{"a":{"items":{"properties":{"b":{"type":"string"}}}}}}`, `{"a":[{"b":"x"},{"b":1}]}`, "/a/1/b")
+
+	// combinators
+	f(`{"allOf":[{"type":"number"},{"minimum":2}]}`, `3`)
+	f(`{"allOf":[{"type":"number"},{"minimum":2}]}`, `"x"`, "")
+	f(`{"allOf":[{"type":"number"},{"minimum":2}]}`, `1`, "")
+	f(`{"anyOf":[{"type":"number"},{"type":"string"}]}`, `"x"`)
+	f(`{"anyOf":[{"type":"number"},{"type":"string"}]}`, `null`, "")
+	f(`{"oneOf":[{"type":"number"},{"type":"integer"}]}`, `1.5`)
+	f(`{"oneOf":[{"type":"number"},{"type":"integer"}]}`, `1`, "")
+	f(`{"oneOf":[{"type":"number"},{"type":"integer"}]}`, `"x"`, "")
+	f(`{"not":{"type":"null"}}`, `1`)
+	f(`{"not":{"type":"null"}}`, `null`, "")
+
+	// $ref
+	f(`{"$defs":{"pos":{"type":"integer","minimum":1}},"properties":{"a":{"$ref":"#/$defs/pos"}}}`, `{"a":1}`)
+	f(`{"$defs":{"pos":{"type":"integer","minimum":1}},"properties":{"a":{"$ref":"#/$defs/pos"}}}`, `{"a":0}`, "/a")
+	f(`{"definitions":{"s":{"type":"string"}},"items":{"$ref":"#/definitions/s"}}`, `["a",1]`, "/1")
+	f(`{"properties":{"a":{"type":"string"},"b":{"$ref":"#/properties/a"}}}`, `{"b":1}`, "/b")
+	f(`{"x":{"y":{"type":"null"}},"$ref":"#/x/y"}`, `1`, "")
+
+	// recursive schema
+	tree := `{"type":"object","properties":{"value":{"type":"number"},"children":{"type":"array","items":{"$ref":"#"}}},"required":["value"]}`
+	f(tree, `{"value":1,"children":[{"value":2},{"value":3,"children":[{"value":4}]}]}`)
+	f(tree, `{"value":1,"children":[{"value":2},{"value":3,"children":[{}]}]}`, "/children/1/children/0")
+}
+
+func TestValidationErrorMessage(t *testing.T) {
+	sch := MustCompile(`{"properties":{"a":{"type":"string"},"b":{"minimum":5}}}`)
+	var p fastjson.Parser
+	v, err := p.Parse(`{"a":1,"b":2}`)
+	if err != nil {
+		t.Fatalf("cannot parse value: %s", err)
+	}
+	err = sch.Validate(v)
+	if err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+	ve := err.(*ValidationError)
+	if len(ve.Errors) != 2 {
+		t.Fatalf("unexpected number of errors; got %d; want 2", len(ve.Errors))
+	}
+	e := ve.Errors[0]
+	if e.SchemaPath != "/properties/a/type" {
+		t.Fatalf("unexpected SchemaPath; got %q; want %q", e.SchemaPath, "/properties/a/type")
+	}
+	errStr := err.Error()
+	expectedStr := `value doesn't match schema: "/a": unexpected type number; "/b": 2 is smaller than 5`
+	if errStr != expectedStr {
+		t.Fatalf("unexpected error message;\ngot\n%s\nwant\n%s", errStr, expectedStr)
+	}
+}
+
+func TestValidateBytes(t *testing.T) {
+	sch := MustCompile(`{"type":"array","items":{"type":"string"}}`)
+	if err := sch.ValidateBytes([]byte(`["a","b"]`)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := sch.ValidateBytes([]byte(`["a",1]`)); err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+	if err := sch.ValidateBytes([]byte(`["a",`)); err == nil {
+		t.Fatalf("expecting non-nil error for invalid JSON")
+	}
+}
+
+func TestValidateConcurrent(t *testing.T) {
+	sch := MustCompile(`{"enum":[{"ab":"x\n"},2.50],"items":{"const":{"c":"d"}}}`)
+	ch := make(chan error, 4)
+	for i := 0; i < cap(ch); i++ {
+		go func() {
+			var p fastjson.Parser
+			for j := 0; j < 100; j++ {
+				v, err := p.Parse(`2.5`)
+				if err != nil {
+					ch <- err
+					return
+				}
+				if err := sch.Validate(v); err != nil {
+					ch <- err
+					return
+				}
+			}
+			ch <- nil
+		}()
+	}
+	for i := 0; i < cap(ch); i++ {
+		if err := <-ch; err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+}
+
+func TestValidateDeeplyNested(t *testing.T) {
+	f := func(schema, s string, expectedPath string) {
+		t.Helper()
+		sch := MustCompile(schema)
+		var p fastjson.Parser
+		v, err := p.Parse(s)
+		if err != nil {
+			t.Fatalf("cannot parse value: %s", err)
+		}
+		err = sch.Validate(v)
+		if expectedPath == "-" {
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			return
+		}
+		ve, ok := err.(*ValidationError)
+		if !ok {
+			t.Fatalf("unexpected error type: %T", err)
+		}
+		if len(ve.Errors) != 1 || ve.Errors[0].InstancePath != expectedPath {
+			t.Fatalf("unexpected errors: %s", err)
+		}
+	}
+
+	nested := func(prefix, suffix string, depth int) string {
+		return strings.Repeat(prefix, depth) + "1" + strings.Repeat(suffix, depth)
+	}
+	f(`{"items":{"$ref":"#"}}`, nested(`[`, `]`, MaxDepth), "-")
+	f(`{"items":{"$ref":"#"}}`, nested(`[`, `]`, MaxDepth+1), strings.Repeat("/0", MaxDepth))
+	f(`{"items":{"$ref":"#"}}`, nested(`[`, `]`, 100000), strings.Repeat("/0", MaxDepth))
+	f(`{"additionalProperties":{"$ref":"#"}}`, nested(`{"a":`, `}`, 100000), strings.Repeat("/a", MaxDepth))
+
+	// Deeply nested values are compared without validating their items.
+	deep := nested(`[`, `]`, 100000)
+	f(`{"uniqueItems":true}`, "["+deep+","+deep+"]", "")
+	f(`{"const":[1]}`, deep, "")
+}
@@ -0,0 +1,464 @@
+package schema
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/valyala/fastjson"
+)
+
+// Error describes a single validation failure.
+type Error struct {
+	// InstancePath is JSON Pointer ( https://tools.ietf.org/html/rfc6901 )
+	// to the invalid value in the validated document.
+	InstancePath string
+
+	// SchemaPath is JSON Pointer to the failed keyword
+	// in the schema document.
+	SchemaPath string
+
+	// Message describes the failure.
+	Message string
+}
+
+// Error implements error interface.
+func (e *Error) Error() string {
+	return fmt.Sprintf("%q: %s", e.InstancePath, e.Message)
+}
+
+// ValidationError is returned from Schema.Validate if the value
+// doesn't match the schema.
+type ValidationError struct {
+	// Errors contains all the found validation failures.
+	Errors []*Error
+}
+
+// Error implements error interface.
+func (e *ValidationError) Error() string {
+	// Use bytes.Buffer instead of strings.Builder,
+	// so it works on go 1.9 and below.
+	var bb bytes.Buffer
+	bb.WriteString("value doesn't match schema: ")
+	for i, err := range e.Errors {
+		if i > 0 {
+			bb.WriteString("; ")
+		}
+		bb.WriteString(err.Error())
+	}
+	return bb.String()
+}
+
+// Validate validates v against the schema.
+//
+// *ValidationError with all the found failures is returned
+// if v doesn't match the schema.
+func (sch *Schema) Validate(v *fastjson.Value) error {
+	var vd validator
+	vd.validate(sch.root, v)
+	if len(vd.errs) == 0 {
+		return nil
+	}
+	return &ValidationError{
+		Errors: vd.errs,
+	}
+}
+
+// ValidateBytes parses b and validates it against the schema.
+func (sch *Schema) ValidateBytes(b []byte) error {
+	p := parserPool.Get()
+	defer parserPool.Put(p)
+	v, err := p.ParseBytes(b)
+	if err != nil {
+		return err
+	}
+	return sch.Validate(v)
+}
+
+var parserPool fastjson.ParserPool
+
+// MaxDepth is the maximum nesting depth of values validated by Schema.
+//
+// Values nested deeper fail validation, so validation doesn't
+// overflow the stack on deeply nested values.
+const MaxDepth = 1000
+
+type validator struct {
+	// path is JSON Pointer to the currently validated value.
+	path []byte
+
+	// depth is the nesting depth of the currently validated value.
+	depth int
+
+	errs []*Error
+}
+
+func (vd *validator) addError(nd *node, keyword, format string, args ...interface{}) {
+	vd.errs = append(vd.errs, &Error{
+		InstancePath: string(vd.path),
+		SchemaPath:   nd.location + "/" + keyword,
+		Message:      fmt.Sprintf(format, args...),
+	})
+}
+
+// matches returns true if v matches nd.
+//
+// It doesn't record validation errors.
+func (vd *validator) matches(nd *node, v *fastjson.Value) bool {
+	sub := validator{
+		path:  vd.path,
+		depth: vd.depth,
+	}
+	sub.validate(nd, v)
+	return len(sub.errs) == 0
+}
+
+// enterChildren must be called before validating items of array
+// or object validated against nd.
+//
+// It returns false if the items are nested deeper than MaxDepth.
+// leaveChildren must be called after validating the items otherwise.
+func (vd *validator) enterChildren(nd *node) bool {
+	if vd.depth >= MaxDepth {
+		vd.errs = append(vd.errs, &Error{
+			InstancePath: string(vd.path),
+			SchemaPath:   nd.location,
+			Message:      fmt.Sprintf("value is nested too deeply; max depth is %d", MaxDepth),
+		})
+		return false
+	}
+	vd.depth++
+	return true
+}
+
+func (vd *validator) leaveChildren() {
+	vd.depth--
+}
+
+func (vd *validator) validate(nd *node, v *fastjson.Value) {
+	if nd.alwaysFalse {
+		vd.errs = append(vd.errs, &Error{
+			InstancePath: string(vd.path),
+			SchemaPath:   nd.location,
+			Message:      "false schema never matches",
+		})
+		return
+	}
+	if nd.refTarget != nil {
+		vd.validate(nd.refTarget, v)
+	}
+
+	t := v.Type()
+	if nd.types != 0 && !matchesType(nd.types, v) {
+		vd.addError(nd, "type", "unexpected type %s", typeName(v))
+	}
+	if nd.enum != nil {
+		found := false
+		for _, ev := range nd.enum {
+			if equalValues(ev, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			vd.addError(nd, "enum", "value must be one of the enum values")
+		}
+	}
+	if nd.constVal != nil && !equalValues(nd.constVal, v) {
+		vd.addError(nd, "const", "value must be equal to %s", nd.constVal)
+	}
+
+	switch t {
+	case fastjson.TypeNumber:
+		vd.validateNumber(nd, v.GetFloat64())
+	case fastjson.TypeString:
+		vd.validateString(nd, v.GetStringBytes())
+	case fastjson.TypeArray:
+		vd.validateArray(nd, v.GetArray())
+	case fastjson.TypeObject:
+		vd.validateObject(nd, v.GetObject())
+	}
+
+	for _, sub := range nd.allOf {
+		vd.validate(sub, v)
+	}
+	if nd.anyOf != nil {
+		found := false
+		for _, sub := range nd.anyOf {
+			if vd.matches(sub, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			vd.addError(nd, "anyOf", "value doesn't match any schema")
+		}
+	}
+	if nd.oneOf != nil {
+		n := 0
+		for _, sub := range nd.oneOf {
+			if vd.matches(sub, v) {
+				n++
+			}
+		}
+		if n != 1 {
+			vd.addError(nd, "oneOf", "value must match exactly one schema; matched %d schemas", n)
+		}
+	}
+	if nd.not != nil && vd.matches(nd.not, v) {
+		vd.addError(nd, "not", "value mustn't match the schema")
+	}
+}
+
+func (vd *validator) validateNumber(nd *node, f float64) {
+	if nd.multipleOf > 0 {
+		q := f / nd.multipleOf
+		if math.Abs(q-math.Floor(q+0.5)) > 1e-9 {
+			vd.addError(nd, "multipleOf", "%v isn't multiple of %v", f, nd.multipleOf)
+		}
+	}
+	if nd.hasMinimum && f < nd.minimum {
+		vd.addError(nd, "minimum", "%v is smaller than %v", f, nd.minimum)
+	}
+	if nd.hasMaximum && f > nd.maximum {
+		vd.addError(nd, "maximum", "%v is bigger than %v", f, nd.maximum)
+	}
+	if nd.hasExclusiveMin && f <= nd.exclusiveMinimum {
+		vd.addError(nd, "exclusiveMinimum", "%v must be bigger than %v", f, nd.exclusiveMinimum)
+	}
+	if nd.hasExclusiveMax && f >= nd.exclusiveMaximum {
+		vd.addError(nd, "exclusiveMaximum", "%v must be smaller than %v", f, nd.exclusiveMaximum)
+	}
+}
+
+func (vd *validator) validateString(nd *node, sb []byte) {
+	if nd.minLength >= 0 || nd.maxLength >= 0 {
+		n := utf8.RuneCount(sb)
+		if nd.minLength >= 0 && n < nd.minLength {
+			vd.addError(nd, "minLength", "string length %d is smaller than %d", n, nd.minLength)
+		}
+		if nd.maxLength >= 0 && n > nd.maxLength {
+			vd.addError(nd, "maxLength", "string length %d is bigger than %d", n, nd.maxLength)
+		}
+	}
+	if nd.pattern != nil && !nd.pattern.Match(sb) {
+		vd.addError(nd, "pattern", "string %q doesn't match pattern %q", sb, nd.pattern)
+	}
+}
+
+func (vd *validator) validateArray(nd *node, a []*fastjson.Value) {
+	if nd.minItems >= 0 && len(a) < nd.minItems {
+		vd.addError(nd, "minItems", "array length %d is smaller than %d", len(a), nd.minItems)
+	}
+	if nd.maxItems >= 0 && len(a) > nd.maxItems {
+		vd.addError(nd, "maxItems", "array length %d is bigger than %d", len(a), nd.maxItems)
+	}
+	if nd.uniqueItems {
+	uniqueLoop:
+		for i := 1; i < len(a); i++ {
+			for j := 0; j < i; j++ {
+				if equalValues(a[i], a[j]) {
+					vd.addError(nd, "uniqueItems", "items at indexes %d and %d are equal", j, i)
+					break uniqueLoop
+				}
+			}
+		}
+	}
+
+	if len(a) == 0 || (nd.items == nil && nd.prefixItems == nil && nd.contains == nil) {
+		return
+	}
+	if !vd.enterChildren(nd) {
+		return
+	}
+	defer vd.leaveChildren()
+
+	n := len(vd.path)
+	for i, item := range a {
+		var sub *node
+		if i < len(nd.prefixItems) {
+			sub = nd.prefixItems[i]
+		} else {
+			sub = nd.items
+		}
+		if sub == nil {
+			continue
+		}
+		vd.path = appendPointerIndex(vd.path[:n], i)
+		vd.validate(sub, item)
+	}
+	vd.path = vd.path[:n]
+
+	if nd.contains != nil {
+		found := false
+		for i, item := range a {
+			vd.path = appendPointerIndex(vd.path[:n], i)
+			if vd.matches(nd.contains, item) {
+				found = true
+				break
+			}
+		}
+		vd.path = vd.path[:n]
+		if !found {
+			vd.addError(nd, "contains", "array doesn't contain matching items")
+		}
+	}
+}
+
+func (vd *validator) validateObject(nd *node, o *fastjson.Object) {
+	if nd.minProperties >= 0 && o.Len() < nd.minProperties {
+		vd.addError(nd, "minProperties", "object has %d properties; want at least %d", o.Len(), nd.minProperties)
+	}
+	if nd.maxProperties >= 0 && o.Len() > nd.maxProperties {
+		vd.addError(nd, "maxProperties", "object has %d properties; want at most %d", o.Len(), nd.maxProperties)
+	}
+	for _, name := range nd.required {
+		if o.Get(name) == nil {
+			vd.addError(nd, "required", "missing required property %q", name)
+		}
+	}
+
+	if o.Len() == 0 || (nd.properties == nil && nd.patternProperties == nil && nd.additionalProperties == nil) {
+		return
+	}
+	if !vd.enterChildren(nd) {
+		return
+	}
+	defer vd.leaveChildren()
+
+	n := len(vd.path)
+	o.Visit(func(k []byte, v *fastjson.Value) {
+		vd.path = appendPointerToken(vd.path[:n], k)
+		matched := false
+		for i := range nd.properties {
+			p := &nd.properties[i]
+			if p.name == string(k) {
+				vd.validate(p.schema, v)
+				matched = true
+				break
+			}
+		}
+		for i := range nd.patternProperties {
+			pp := &nd.patternProperties[i]
+			if pp.re.Match(k) {
+				vd.validate(pp.schema, v)
+				matched = true
+			}
+		}
+		if !matched && nd.additionalProperties != nil {
+			vd.validate(nd.additionalProperties, v)
+		}
+	})
+	vd.path = vd.path[:n]
+}
+
+func matchesType(types typeMask, v *fastjson.Value) bool {
+	switch v.Type() {
+	case fastjson.TypeNull:
+		return types&typeNull != 0
+	case fastjson.TypeTrue, fastjson.TypeFalse:
+		return types&typeBoolean != 0
+	case fastjson.TypeObject:
+		return types&typeObject != 0
+	case fastjson.TypeArray:
+		return types&typeArray != 0
+	case fastjson.TypeString:
+		return types&typeString != 0
+	case fastjson.TypeNumber:
+		if types&typeNumber != 0 {
+			return true
+		}
+		f := v.GetFloat64()
+		return types&typeInteger != 0 && f == math.Trunc(f) && !math.IsInf(f, 0)
+	default:
+		return false
+	}
+}
+
+func typeName(v *fastjson.Value) string {
+	switch v.Type() {
+	case fastjson.TypeTrue, fastjson.TypeFalse:
+		return "boolean"
+	default:
+		return v.Type().String()
+	}
+}
+
+// equalValues returns true if a and b contain equal JSON values
+// according to JSON Schema rules.
+func equalValues(a, b *fastjson.Value) bool {
+	// Compare nested values without recursion,
+	// so deeply nested values don't overflow the stack.
+	stack := []*fastjson.Value{a, b}
+	for len(stack) > 0 {
+		a, b = stack[len(stack)-2], stack[len(stack)-1]
+		stack = stack[:len(stack)-2]
+		t := a.Type()
+		if t != b.Type() {
+			return false
+		}
+		switch t {
+		case fastjson.TypeObject:
+			ao := a.GetObject()
+			bo := b.GetObject()
+			if ao.Len() != bo.Len() {
+				return false
+			}
+			equal := true
+			ao.Visit(func(k []byte, av *fastjson.Value) {
+				if !equal {
+					return
+				}
+				bv := bo.Get(string(k))
+				if bv == nil {
+					equal = false
+					return
+				}
+				stack = append(stack, av, bv)
+			})
+			if !equal {
+				return false
+			}
+		case fastjson.TypeArray:
+			aa := a.GetArray()
+			ba := b.GetArray()
+			if len(aa) != len(ba) {
+				return false
+			}
+			for i := range aa {
+				stack = append(stack, aa[i], ba[i])
+			}
+		case fastjson.TypeString:
+			if string(a.GetStringBytes()) != string(b.GetStringBytes()) {
+				return false
+			}
+		case fastjson.TypeNumber:
+			if a.GetFloat64() != b.GetFloat64() {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func appendPointerToken(dst, key []byte) []byte {
+	dst = append(dst, '/')
+	for _, c := range key {
+		switch c {
+		case '~':
+			dst = append(dst, '~', '0')
+		case '/':
+			dst = append(dst, '~', '1')
+		default:
+			dst = append(dst, c)
+		}
+	}
+	return dst
+}
+
+func appendPointerIndex(dst []byte, n int) []byte {
+	dst = append(dst, '/')
+	return strconv.AppendInt(dst, int64(n), 10)
+}
@@ -0,0 +1,645 @@
+package fastjson
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// TokenKind is the kind of JSON token returned by Tokenizer.
+type TokenKind int
+
+const (
+	// TokenBeginObject is '{'.
+	TokenBeginObject TokenKind = iota
+
+	// TokenEndObject is '}'.
+	TokenEndObject
+
+	// TokenBeginArray is '['.
+	TokenBeginArray
+
+	// TokenEndArray is ']'.
+	TokenEndArray
+
+	// TokenString is JSON string.
+	TokenString
+
+	// TokenNumber is JSON number.
+	TokenNumber
+
+	// TokenTrue is true.
+	TokenTrue
+
+	// TokenFalse is false.
+	TokenFalse
+
+	// TokenNull is null.
+	TokenNull
+)
+
+// String returns string representation of k.
+func (k TokenKind) String() string {
+	switch k {
+	case TokenBeginObject:
+		return "begin object"
+	case TokenEndObject:
+		return "end object"
+	case TokenBeginArray:
+		return "begin array"
+	case TokenEndArray:
+		return "end array"
+	case TokenString:
+		return "string"
+	case TokenNumber:
+		return "number"
+	case TokenTrue:
+		return "true"
+	case TokenFalse:
+		return "false"
+	case TokenNull:
+		return "null"
+	default:
+		panic(fmt.Errorf("BUG: unknown TokenKind: %d", k))
+	}
+}
+
+// DefaultMaxTokenSize is the default value for Tokenizer.MaxTokenSize.
+const DefaultMaxTokenSize = 64 * 1024 * 1024
+
+// Tokenizer reads JSON tokens from io.Reader one by one without building
+// Value tree.
+//
+// Tokenizer uses memory proportional to the longest token and the nesting
+// depth, so it may process arbitrarily large documents, which cannot
+// be parsed with Parser.
+//
+// Tokenizer verifies the document structure, but it is lenient
+// to string and number contents like Parser is.
+//
+// Tokenizer may be re-used after Reset call.
+//
+// Tokenizer cannot be used from concurrent goroutines.
+type Tokenizer struct {
+	// MaxTokenSize is the maximum size in bytes of a single token
+	// such as string or number.
+	//
+	// DefaultMaxTokenSize is used by default.
+	MaxTokenSize int
+
+	// MultipleValues allows multiple top-level values delimited
+	// by optional whitespace in the input, like Scanner accepts.
+	MultipleValues bool
+
+	r io.Reader
+
+	// buf contains the read input starting at offset.
+	buf []byte
+
+	// pos is the position of the next unread byte in buf.
+	pos int
+
+	// offset is the offset of buf in the whole input.
+	offset int
+
+	// eof is set when no more data may be read from r.
+	eof bool
+
+	// readErr is the error returned from r.
+	readErr error
+
+	// err contains the first error. It is io.EOF at the end
+	// of the input.
+	err error
+
+	// stack contains '{' and '[' chars for the currently open objects
+	// and arrays.
+	stack []byte
+
+	state tokenizerState
+
+	// values is the number of read top-level values.
+	values int
+
+	// The current token.
+	kind      TokenKind
+	raw       []byte
+	rawOffset int
+	depth     int
+	key       []byte
+	hasKey    bool
+
+	// str contains the unescaped current string.
+	str         []byte
+	strUnescape bool
+}
+
+type tokenizerState int
+
+const (
+	tsValue tokenizerState = iota
+	tsArrayFirst
+	tsObjectFirst
+	tsArrayValue
+	tsKey
+	tsAfterValue
+)
+
+// tokenizerReadSize is the initial size of Tokenizer buffer.
+const tokenizerReadSize = 64 * 1024
+
+// Reset resets t, so it reads tokens from r.
+//
+// MaxTokenSize and MultipleValues settings are preserved.
+func (t *Tokenizer) Reset(r io.Reader) {
+	t.r = r
+	t.buf = t.buf[:0]
+	t.pos = 0
+	t.offset = 0
+	t.eof = false
+	t.readErr = nil
+	t.err = nil
+	t.stack = t.stack[:0]
+	t.state = tsValue
+	t.values = 0
+	t.resetToken()
+}
+
+func (t *Tokenizer) resetToken() {
+	t.raw = nil
+	t.rawOffset = 0
+	t.depth = 0
+	t.key = t.key[:0]
+	t.hasKey = false
+	t.str = t.str[:0]
+	t.strUnescape = false
+}
+
+// Next reads the next token.
+//
+// Returns true on success. The token is available via Kind, Key, Raw
+// and Depth calls.
+//
+// Returns false either on error or on the end of the input.
+// Call Error in order to determine the cause of the returned false.
+func (t *Tokenizer) Next() bool {
+	if t.err != nil {
+		return false
+	}
+	t.resetToken()
+	for {
+		ch, ok := t.peekNonWS()
+		if !ok {
+			t.finish()
+			return false
+		}
+		switch t.state {
+		case tsValue:
+			if t.values > 0 && !t.MultipleValues {
+				t.fail("unexpected tail")
+				return false
+			}
+			return t.readValue(ch)
+		case tsArrayFirst:
+			if ch == ']' {
+				return t.readEnd(ch)
+			}
+			return t.readValue(ch)
+		case tsArrayValue:
+			return t.readValue(ch)
+		case tsObjectFirst:
+			if ch == '}' {
+				return t.readEnd(ch)
+			}
+			return t.readMember(ch)
+		case tsKey:
+			return t.readMember(ch)
+		case tsAfterValue:
+			top := t.stack[len(t.stack)-1]
+			switch {
+			case ch == ',':
+				t.pos++
+				if top == '{' {
+					t.state = tsKey
+				} else {
+					t.state = tsArrayValue
+				}
+			case ch == '}' && top == '{', ch == ']' && top == '[':
+				return t.readEnd(ch)
+			case top == '{':
+				t.fail("missing ',' after object value")
+				return false
+			default:
+				t.fail("missing ',' after array value")
+				return false
+			}
+		default:
+			panic(fmt.Errorf("BUG: unexpected tokenizer state: %d", t.state))
+		}
+	}
+}
+
+// finish handles the end of the input.
+func (t *Tokenizer) finish() {
+	switch {
+	case t.readErr != nil:
+		t.err = fmt.Errorf("cannot read JSON: %s", t.readErr)
+	case len(t.stack) > 0:
+		t.fail("unexpected end of %s", t.containerName())
+	case t.values == 0 && !t.MultipleValues:
+		t.fail("cannot parse empty string")
+	default:
+		t.err = io.EOF
+	}
+}
+
+func (t *Tokenizer) readMember(ch byte) bool {
+	if ch != '"' {
+		t.fail(`cannot find opening '"' for object key`)
+		return false
+	}
+	n, ok := t.scanString()
+	if !ok {
+		return false
+	}
+	t.key = appendUnescaped(t.key[:0], t.buf[t.pos+1:t.pos+n-1])
+	t.hasKey = true
+	t.pos += n
+	ch, ok = t.peekNonWS()
+	if !ok {
+		t.finish()
+		return false
+	}
+	if ch != ':' {
+		t.fail("missing ':' after object key")
+		return false
+	}
+	t.pos++
+	ch, ok = t.peekNonWS()
+	if !ok {
+		t.finish()
+		return false
+	}
+	return t.readValue(ch)
+}
+
+func (t *Tokenizer) readValue(ch byte) bool {
+	t.depth = len(t.stack)
+	n := 1
+	switch ch {
+	case '{', '[':
+		t.stack = append(t.stack, ch)
+		if ch == '{' {
+			t.kind = TokenBeginObject
+			t.state = tsObjectFirst
+		} else {
+			t.kind = TokenBeginArray
+			t.state = tsArrayFirst
+		}
+		t.setRaw(n)
+		return true
+	case '"':
+		var ok bool
+		if n, ok = t.scanString(); !ok {
+			return false
+		}
+		t.kind = TokenString
+	case 't':
+		if !t.scanLiteral("true") {
+			return false
+		}
+		t.kind = TokenTrue
+		n = len("true")
+	case 'f':
+		if !t.scanLiteral("false") {
+			return false
+		}
+		t.kind = TokenFalse
+		n = len("false")
+	case 'n':
+		if !t.scanLiteral("null") {
+			return false
+		}
+		t.kind = TokenNull
+		n = len("null")
+	default:
+		var ok bool
+		if n, ok = t.scanNumber(); !ok {
+			return false
+		}
+		t.kind = TokenNumber
+	}
+	t.setRaw(n)
+	t.valueDone()
+	return true
+}
+
+func (t *Tokenizer) readEnd(ch byte) bool {
+	t.stack = t.stack[:len(t.stack)-1]
+	t.depth = len(t.stack)
+	if ch == '}' {
+		t.kind = TokenEndObject
+	} else {
+		t.kind = TokenEndArray
+	}
+	t.setRaw(1)
+	t.valueDone()
+	return true
+}
+
+func (t *Tokenizer) setRaw(n int) {
+	t.raw = t.buf[t.pos : t.pos+n]
+	t.rawOffset = t.offset + t.pos
+	t.pos += n
+}
+
+// valueDone must be called after the end of each value.
+func (t *Tokenizer) valueDone() {
+	if len(t.stack) == 0 {
+		t.values++
+		t.state = tsValue
+		return
+	}
+	t.state = tsAfterValue
+}
+
+// scanString returns the length of the string token at t.pos.
+func (t *Tokenizer) scanString() (int, bool) {
+	// scanned is the number of bytes known to contain no closing quote.
+	scanned := 1
+	for {
+		s := b2s(t.buf[t.pos:])
+		if strings.IndexByte(s[scanned:], '"') >= 0 {
+			_, tail, err := parseRawString(s)
+			if err == nil {
+				return t.checkTokenSize(len(s) - len(tail))
+			}
+		}
+		scanned = len(s)
+		if !t.more(len(s)) {
+			t.finishToken(`cannot parse string: missing closing '"'`)
+			return 0, false
+		}
+	}
+}
+
+// scanNumber returns the length of the number token at t.pos.
+func (t *Tokenizer) scanNumber() (int, bool) {
+	for {
+		s := b2s(t.buf[t.pos:])
+		_, tail, err := parseRawNumber(s)
+		if err != nil {
+			t.fail("cannot parse number: %s", err)
+			return 0, false
+		}
+		if len(tail) > 0 {
+			return t.checkTokenSize(len(s) - len(tail))
+		}
+		if !t.more(len(s)) {
+			if t.err != nil || t.readErr != nil {
+				t.finishToken("")
+				return 0, false
+			}
+			// The number ends at the end of the input.
+			return t.checkTokenSize(len(s))
+		}
+	}
+}
+
+func (t *Tokenizer) scanLiteral(lit string) bool {
+	for len(t.buf)-t.pos < len(lit) && t.more(len(t.buf)-t.pos) {
+	}
+	s := b2s(t.buf[t.pos:])
+	if len(s) > len(lit) {
+		s = s[:len(lit)]
+	}
+	if s != lit {
+		if len(s) < len(lit) && (t.err != nil || t.readErr != nil) {
+			t.finishToken("")
+		} else {
+			t.fail("unexpected value found: %q", s)
+		}
+		return false
+	}
+	return true
+}
+
+func (t *Tokenizer) maxTokenSize() int {
+	if t.MaxTokenSize <= 0 {
+		return DefaultMaxTokenSize
+	}
+	return t.MaxTokenSize
+}
+
+// checkTokenSize verifies the size n of the scanned token.
+func (t *Tokenizer) checkTokenSize(n int) (int, bool) {
+	if maxTokenSize := t.maxTokenSize(); n > maxTokenSize {
+		t.fail("too long token; it exceeds %d bytes", maxTokenSize)
+		return 0, false
+	}
+	return n, true
+}
+
+// finishToken sets an error for incomplete token at the end of the input.
+//
+// msg is used if the input ends without errors.
+func (t *Tokenizer) finishToken(msg string) {
+	switch {
+	case t.err != nil:
+		// The error is already set by more.
+	case t.readErr != nil:
+		t.err = fmt.Errorf("cannot read JSON: %s", t.readErr)
+	default:
+		t.fail("%s", msg)
+	}
+}
+
+// peekNonWS skips whitespace and returns the next char.
+//
+// false is returned at the end of the input.
+func (t *Tokenizer) peekNonWS() (byte, bool) {
+	for {
+		for t.pos < len(t.buf) {
+			ch := t.buf[t.pos]
+			if !isWS(ch) {
+				return ch, true
+			}
+			t.pos++
+		}
+		if !t.more(0) {
+			return 0, false
+		}
+	}
+}
+
+// more reads more data, so more than n bytes are available at t.pos.
+//
+// The buffer capacity is doubled when the incomplete token doesn't fit it.
+//
+// false is returned if no more data may be read.
+func (t *Tokenizer) more(n int) bool {
+	if t.eof {
+		return false
+	}
+	maxTokenSize := t.maxTokenSize()
+	if n >= maxTokenSize {
+		t.fail("too long token; it exceeds %d bytes", maxTokenSize)
+		return false
+	}
+	want := 2 * n
+	if want < tokenizerReadSize {
+		want = tokenizerReadSize
+	}
+	if t.pos+want > cap(t.buf) {
+		// Move the unread data to the start of the buffer.
+		avail := copy(t.buf[:cap(t.buf)], t.buf[t.pos:])
+		t.offset += t.pos
+		t.pos = 0
+		if want > cap(t.buf) {
+			b := make([]byte, avail, want)
+			copy(b, t.buf[:avail])
+			t.buf = b
+		} else {
+			t.buf = t.buf[:avail]
+		}
+	}
+	for len(t.buf)-t.pos <= n {
+		k, err := t.r.Read(t.buf[len(t.buf):cap(t.buf)])
+		t.buf = t.buf[:len(t.buf)+k]
+		if err != nil {
+			t.eof = true
+			if err != io.EOF {
+				t.readErr = err
+			}
+			break
+		}
+	}
+	return len(t.buf)-t.pos > n
+}
+
+// appendUnescaped appends unescaped string contents b to dst.
+func appendUnescaped(dst, b []byte) []byte {
+	n := len(dst)
+	dst = append(dst, b...)
+	// unescapeStringBestEffort unescapes the string in place.
+	s := unescapeStringBestEffort(b2s(dst[n:]))
+	return dst[:n+len(s)]
+}
+
+func (t *Tokenizer) containerName() string {
+	if t.stack[len(t.stack)-1] == '{' {
+		return "object"
+	}
+	return "array"
+}
+
+func (t *Tokenizer) fail(format string, args ...interface{}) {
+	if t.err != nil && t.err != io.EOF {
+		return
+	}
+	offset := t.offset + t.pos
+	err := fmt.Errorf(format, args...)
+	t.err = &ParseError{
+		Offset: offset,
+		Err:    err,
+		msg:    fmt.Sprintf("cannot parse JSON at offset %d: %s", offset, err),
+	}
+}
+
+// Error returns the last error.
+//
+// nil is returned at the end of the input.
+//
+// *ParseError is returned on invalid JSON.
+func (t *Tokenizer) Error() error {
+	if t.err == io.EOF {
+		return nil
+	}
+	return t.err
+}
+
+// Kind returns the kind of the current token.
+func (t *Tokenizer) Kind() TokenKind {
+	return t.kind
+}
+
+// Key returns the unescaped object key of the current value.
+//
+// nil is returned if the current token isn't an object member value.
+// For TokenBeginObject and TokenBeginArray it is the key of the object
+// or array started by the token.
+//
+// The returned key is valid until the next call to Next.
+func (t *Tokenizer) Key() []byte {
+	if !t.hasKey {
+		return nil
+	}
+	if t.key == nil {
+		return emptyKey
+	}
+	return t.key
+}
+
+var emptyKey = []byte{}
+
+// Raw returns raw bytes of the current token.
+//
+// It contains the quoted string for TokenString and a single char
+// for tokens starting or ending objects and arrays.
+//
+// The returned bytes are valid until the next call to Next.
+func (t *Tokenizer) Raw() []byte {
+	return t.raw
+}
+
+// Depth returns the nesting depth of the current token.
+//
+// Top-level values have zero depth. Tokens ending objects and arrays have
+// the same depth as the tokens starting them.
+func (t *Tokenizer) Depth() int {
+	return t.depth
+}
+
+// Offset returns the byte offset of the current token in the input.
+func (t *Tokenizer) Offset() int {
+	return t.rawOffset
+}
+
+// StringBytes returns the unescaped string for TokenString.
+//
+// The returned bytes are valid until the next call to Next.
+func (t *Tokenizer) StringBytes() ([]byte, error) {
+	if t.kind != TokenString || t.raw == nil {
+		return nil, fmt.Errorf("token doesn't contain string; it contains %s", t.kind)
+	}
+	if !t.strUnescape {
+		t.str = appendUnescaped(t.str[:0], t.raw[1:len(t.raw)-1])
+		t.strUnescape = true
+	}
+	return t.str, nil
+}
+
+// Float64 returns the number for TokenNumber.
+func (t *Tokenizer) Float64() (float64, error) {
+	if t.kind != TokenNumber || t.raw == nil {
+		return 0, fmt.Errorf("token doesn't contain number; it contains %s", t.kind)
+	}
+	return strconv.ParseFloat(b2s(t.raw), 64)
+}
+
+// Skip skips the object or array started by the current token,
+// so the next call to Next returns the token after it.
+//
+// Skip does nothing for other tokens.
+func (t *Tokenizer) Skip() {
+	if t.raw == nil || (t.kind != TokenBeginObject && t.kind != TokenBeginArray) {
+		return
+	}
+	depth := t.depth
+	for t.Next() {
+		if (t.kind == TokenEndObject || t.kind == TokenEndArray) && t.depth == depth {
+			return
+		}
+	}
+}
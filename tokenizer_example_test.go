@@ -0,0 +1,32 @@
+package fastjson_test
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/valyala/fastjson"
+)
+
+func ExampleTokenizer() {
+	r := strings.NewReader(`{"items": [{"id": 1, "tags": ["a"]}, {"id": 2, "tags": []}], "total": 2}`)
+	var t fastjson.Tokenizer
+	t.Reset(r)
+	for t.Next() {
+		switch {
+		case string(t.Key()) == "tags":
+			// Skip tags without reading them.
+			t.Skip()
+		case t.Kind() == fastjson.TokenNumber:
+			fmt.Printf("depth=%d, %s=%s\n", t.Depth(), t.Key(), t.Raw())
+		}
+	}
+	if err := t.Error(); err != nil {
+		log.Fatalf("cannot read JSON: %s", err)
+	}
+
+	// Output:
+	// depth=3, id=1
+	// depth=3, id=2
+	// depth=1, total=2
+}
@@ -0,0 +1,389 @@
+package fastjson
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// tokensString returns string representation of all the tokens read by t.
+func tokensString(t *Tokenizer) string {
+	var a []string
+	for t.Next() {
+		s := fmt.Sprintf("%d:%s", t.Depth(), t.Raw())
+		if key := t.Key(); key != nil {
+			s = fmt.Sprintf("%d:%q=%s", t.Depth(), key, t.Raw())
+		}
+		a = append(a, s)
+	}
+	return strings.Join(a, " ")
+}
+
+func TestTokenizerSuccess(t *testing.T) {
+	f := func(s, expected string) {
+		t.Helper()
+		for _, r := range []io.Reader{strings.NewReader(s), iotest.OneByteReader(strings.NewReader(s)), iotest.DataErrReader(strings.NewReader(s))} {
+			var tk Tokenizer
+			tk.Reset(r)
+			result := tokensString(&tk)
+			if err := tk.Error(); err != nil {
+				t.Fatalf("unexpected error for %q: %s", s, err)
+			}
+			if result != expected {
+				t.Fatalf("unexpected tokens for %q;\ngot\n%s\nwant\n%s", s, result, expected)
+			}
+		}
+	}
+
+	f(`1`, `0:1`)
+	f(` -12.5e+3 `, `0:-12.5e+3`)
+	f(`"foo"`, `0:"foo"`)
+	f(`true`, `0:true`)
+	f(` false`, `0:false`)
+	f("null\n", `0:null`)
+	f(`[]`, `0:[ 0:]`)
+	f(`{}`, `0:{ 0:}`)
+	f(` [ 1 , "x" , null ] `, `0:[ 1:1 1:"x" 1:null 0:]`)
+	f(`{"a":1,"b":[true,{"c":{}}],"d\"e":"f"}`, `0:{ 1:"a"=1 1:"b"=[ 2:true 2:{ 3:"c"={ 3:} 2:} 1:] 1:"d\"e"="f" 0:}`)
+	f(`[[[]],[{}]]`, `0:[ 1:[ 2:[ 2:] 1:] 1:[ 2:{ 2:} 1:] 0:]`)
+	f(`{"":""}`, `0:{ 1:""="" 0:}`)
+	f(`{"ab":"\\"}`, `0:{ 1:"ab"="\\" 0:}`)
+}
+
+func TestTokenizerError(t *testing.T) {
+	f := func(s string) {
+		t.Helper()
+		for _, r := range []io.Reader{strings.NewReader(s), iotest.OneByteReader(strings.NewReader(s))} {
+			var tk Tokenizer
+			tk.Reset(r)
+			for tk.Next() {
+			}
+			err := tk.Error()
+			if err == nil {
+				t.Fatalf("expecting non-nil error for %q", s)
+			}
+			if _, ok := err.(*ParseError); !ok {
+				t.Fatalf("unexpected error type for %q; got %T; want *ParseError", s, err)
+			}
+			if tk.Next() {
+				t.Fatalf("Next must return false after error for %q", s)
+			}
+		}
+	}
+
+	f(``)
+	f(`   `)
+	f(`1 2`)
+	f(`[] {}`)
+	f(`[`)
+	f(`{`)
+	f(`[1`)
+	f(`[1,`)
+	f(`[1 2]`)
+	f(`[1,]`)
+	f(`[,1]`)
+	f(`[}`)
+	f(`{]`)
+	f(`{"a"}`)
+	f(`{"a":}`)
+	f(`{"a":1,}`)
+	f(`{"a":1 "b":2}`)
+	f(`{"a" 1}`)
+	f(`{1:2}`)
+	f(`{"a`)
+	f(`{"a":"b`)
+	f(`"foo`)
+	f(`tru`)
+	f(`trux`)
+	f(`nul`)
+	f(`falsee`)
+	f(`x`)
+	f(`]`)
+	f(`}`)
+	f(`,`)
+	f(`[1]]`)
+}
+
+func TestTokenizerErrorOffset(t *testing.T) {
+	var tk Tokenizer
+	tk.Reset(strings.NewReader(`{"a": [1, 2 3]}`))
+	for tk.Next() {
+	}
+	err := tk.Error()
+	pe, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("unexpected error type; got %T; want *ParseError", err)
+	}
+	if pe.Offset != 12 {
+		t.Fatalf("unexpected offset; got %d; want %d", pe.Offset, 12)
+	}
+	if pe.Error() != "cannot parse JSON at offset 12: missing ',' after array value" {
+		t.Fatalf("unexpected error message: %s", pe)
+	}
+}
+
+func TestTokenizerReadError(t *testing.T) {
+	var tk Tokenizer
+	tk.Reset(io.MultiReader(strings.NewReader(`[1, "foo`), iotest.TimeoutReader(strings.NewReader("x"))))
+	for tk.Next() {
+	}
+	err := tk.Error()
+	if err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+	if _, ok := err.(*ParseError); ok {
+		t.Fatalf("unexpected *ParseError for read error: %s", err)
+	}
+	if !strings.Contains(err.Error(), iotest.ErrTimeout.Error()) {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestTokenizerMultipleValues(t *testing.T) {
+	f := func(s, expected string) {
+		t.Helper()
+		var tk Tokenizer
+		tk.MultipleValues = true
+		tk.Reset(strings.NewReader(s))
+		result := tokensString(&tk)
+		if err := tk.Error(); err != nil {
+			t.Fatalf("unexpected error for %q: %s", s, err)
+		}
+		if result != expected {
+			t.Fatalf("unexpected tokens for %q;\ngot\n%s\nwant\n%s", s, result, expected)
+		}
+	}
+
+	f(``, ``)
+	f(" \n ", ``)
+	f(`1 2`, `0:1 0:2`)
+	f("{\"a\":1}\n[2]\n\"x\"", `0:{ 1:"a"=1 0:} 0:[ 1:2 0:] 0:"x"`)
+	f(`[]{}truefalse`, `0:[ 0:] 0:{ 0:} 0:true 0:false`)
+}
+
+func TestTokenizerLongTokens(t *testing.T) {
+	longString := strings.Repeat("x", 3*tokenizerReadSize+17) + `\"` + strings.Repeat("y", tokenizerReadSize)
+	longNumber := "1" + strings.Repeat("2", 2*tokenizerReadSize)
+	longKey := strings.Repeat("k", tokenizerReadSize+1)
+	s := fmt.Sprintf(`[%q, %s, {%q: %q}]`, longString, longNumber, longKey, longString)
+	var tk Tokenizer
+	for _, r := range []io.Reader{strings.NewReader(s), iotest.HalfReader(strings.NewReader(s))} {
+		tk.Reset(r)
+		var strs, nums []string
+		var keys []string
+		for tk.Next() {
+			switch tk.Kind() {
+			case TokenString:
+				sb, err := tk.StringBytes()
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				strs = append(strs, string(sb))
+				if key := tk.Key(); key != nil {
+					keys = append(keys, string(key))
+				}
+			case TokenNumber:
+				nums = append(nums, string(tk.Raw()))
+			}
+		}
+		if err := tk.Error(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(strs) != 2 || strs[0] != longString || strs[1] != longString {
+			t.Fatalf("unexpected strings")
+		}
+		if len(nums) != 1 || nums[0] != longNumber {
+			t.Fatalf("unexpected numbers")
+		}
+		if len(keys) != 1 || keys[0] != longKey {
+			t.Fatalf("unexpected keys")
+		}
+	}
+
+	// Too long token.
+	tk.MaxTokenSize = tokenizerReadSize
+	tk.Reset(strings.NewReader(s))
+	for tk.Next() {
+	}
+	err := tk.Error()
+	if err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+	if !strings.Contains(err.Error(), "too long token") {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+// repeatReader returns prefix, then n items delimited by commas,
+// then suffix.
+type repeatReader struct {
+	prefix, item, suffix string
+	n                    int
+	b                    []byte
+}
+
+func (r *repeatReader) Read(p []byte) (int, error) {
+	for len(r.b) < len(p) && r.n >= 0 {
+		switch {
+		case r.prefix != "":
+			r.b = append(r.b, r.prefix...)
+			r.prefix = ""
+		case r.n > 0:
+			r.b = append(r.b, r.item...)
+			if r.n > 1 {
+				r.b = append(r.b, ',')
+			}
+			r.n--
+		default:
+			r.b = append(r.b, r.suffix...)
+			r.n = -1
+		}
+	}
+	if len(r.b) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, r.b)
+	r.b = r.b[:copy(r.b, r.b[n:])]
+	return n, nil
+}
+
+func TestTokenizerConstantMemory(t *testing.T) {
+	const n = 100000
+	r := &repeatReader{
+		prefix: `{"items":[`,
+		item:   `{"id":12345,"name":"foobar","tags":["a","b","c"],"nested":{"x":null,"y":true}}`,
+		suffix: `]}`,
+		n:      n,
+	}
+	var tk Tokenizer
+	tk.Reset(r)
+	items := 0
+	maxDepth := 0
+	for tk.Next() {
+		if tk.Depth() == 2 && tk.Kind() == TokenBeginObject {
+			items++
+		}
+		if tk.Depth() > maxDepth {
+			maxDepth = tk.Depth()
+		}
+	}
+	if err := tk.Error(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if items != n {
+		t.Fatalf("unexpected number of items; got %d; want %d", items, n)
+	}
+	if maxDepth != 4 {
+		t.Fatalf("unexpected max depth; got %d; want %d", maxDepth, 4)
+	}
+	if cap(tk.buf) > tokenizerReadSize {
+		t.Fatalf("unexpected buffer growth to %d bytes", cap(tk.buf))
+	}
+}
+
+func TestTokenizerSkip(t *testing.T) {
+	s := `{"a":{"b":[1,2,{"c":3}]},"d":[[]],"e":"f","g":[1]}`
+	var tk Tokenizer
+	tk.Reset(strings.NewReader(s))
+	var keys []string
+	for tk.Next() {
+		if tk.Depth() != 1 {
+			continue
+		}
+		keys = append(keys, string(tk.Key()))
+		tk.Skip()
+	}
+	if err := tk.Error(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if result := strings.Join(keys, ","); result != "a,d,e,g" {
+		t.Fatalf("unexpected keys; got %s; want %s", result, "a,d,e,g")
+	}
+
+	// Skip with invalid JSON.
+	tk.Reset(strings.NewReader(`[{"a":[1,}]`))
+	tk.Next()
+	tk.Skip()
+	if tk.Error() == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+}
+
+func TestTokenizerValues(t *testing.T) {
+	var tk Tokenizer
+	tk.Reset(strings.NewReader(`["a\nb\u0041", 1.5e2, -3, null]`))
+	if !tk.Next() || tk.Kind() != TokenBeginArray {
+		t.Fatalf("expecting begin array")
+	}
+	if _, err := tk.StringBytes(); err == nil {
+		t.Fatalf("expecting non-nil error for StringBytes on begin array")
+	}
+	if !tk.Next() || tk.Kind() != TokenString {
+		t.Fatalf("expecting string")
+	}
+	for i := 0; i < 2; i++ {
+		sb, err := tk.StringBytes()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if string(sb) != "a\nbA" {
+			t.Fatalf("unexpected string; got %q; want %q", sb, "a\nbA")
+		}
+	}
+	if string(tk.Raw()) != `"a\nb\u0041"` {
+		t.Fatalf("raw string must remain escaped; got %s", tk.Raw())
+	}
+	if _, err := tk.Float64(); err == nil {
+		t.Fatalf("expecting non-nil error for Float64 on string")
+	}
+	if !tk.Next() || tk.Kind() != TokenNumber {
+		t.Fatalf("expecting number")
+	}
+	if f, err := tk.Float64(); err != nil || f != 150 {
+		t.Fatalf("unexpected number; got %v, %v; want %v", f, err, 150)
+	}
+	if tk.Offset() != 15 {
+		t.Fatalf("unexpected offset; got %d; want %d", tk.Offset(), 15)
+	}
+	if !tk.Next() || tk.Kind() != TokenNumber {
+		t.Fatalf("expecting number")
+	}
+	if f, err := tk.Float64(); err != nil || f != -3 {
+		t.Fatalf("unexpected number; got %v, %v; want %v", f, err, -3)
+	}
+	if !tk.Next() || tk.Kind() != TokenNull || tk.Kind().String() != "null" {
+		t.Fatalf("expecting null")
+	}
+	if !tk.Next() || tk.Kind() != TokenEndArray {
+		t.Fatalf("expecting end array")
+	}
+	if tk.Next() {
+		t.Fatalf("unexpected token %s", tk.Kind())
+	}
+	if err := tk.Error(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestTokenizerMatchesValidator(t *testing.T) {
+	for _, s := range []string{smallFixture, mediumFixture, largeFixture} {
+		var tk Tokenizer
+		tk.Reset(bytes.NewReader([]byte(s)))
+		n := 0
+		for tk.Next() {
+			n++
+		}
+		if err := tk.Error(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if n == 0 {
+			t.Fatalf("missing tokens")
+		}
+		if err := Validate(s); err != nil {
+			t.Fatalf("unexpected validation error: %s", err)
+		}
+	}
+}
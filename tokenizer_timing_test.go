@@ -0,0 +1,37 @@
+package fastjson
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func BenchmarkTokenizer(b *testing.B) {
+	b.Run("small", func(b *testing.B) {
+		benchmarkTokenizer(b, smallFixture)
+	})
+	b.Run("medium", func(b *testing.B) {
+		benchmarkTokenizer(b, mediumFixture)
+	})
+	b.Run("large", func(b *testing.B) {
+		benchmarkTokenizer(b, largeFixture)
+	})
+}
+
+func benchmarkTokenizer(b *testing.B, s string) {
+	b.ReportAllocs()
+	b.SetBytes(int64(len(s)))
+	b.RunParallel(func(pb *testing.PB) {
+		var tk Tokenizer
+		var r strings.Reader
+		for pb.Next() {
+			r.Reset(s)
+			tk.Reset(&r)
+			for tk.Next() {
+			}
+			if err := tk.Error(); err != nil {
+				panic(fmt.Errorf("unexpected error: %s", err))
+			}
+		}
+	})
+}
@@ -0,0 +1,73 @@
+package fastjson
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// InvalidUTF8 is the policy for handling invalid UTF-8 in JSON strings
+// and object keys.
+//
+// JSON must be encoded in UTF-8, but the parser passes strings through
+// as is by default, so invalid UTF-8 from untrusted input may break
+// downstream consumers.
+type InvalidUTF8 int
+
+const (
+	// InvalidUTF8Allow passes invalid UTF-8 sequences through as is.
+	//
+	// This is the fastest policy, since strings aren't checked for UTF-8
+	// validity.
+	InvalidUTF8Allow InvalidUTF8 = 0
+
+	// InvalidUTF8Reject makes the parser returning an error for strings
+	// and object keys with invalid UTF-8.
+	InvalidUTF8Reject InvalidUTF8 = 1
+
+	// InvalidUTF8Replace replaces each byte of invalid UTF-8 sequences
+	// in strings and object keys with U+FFFD replacement char.
+	InvalidUTF8Replace InvalidUTF8 = 2
+)
+
+// checkUTF8 applies c.invalidUTF8 policy to s, which is a string
+// or an object key.
+//
+// The returned string is a copy of s if invalid UTF-8 sequences
+// have been replaced.
+func (c *cache) checkUTF8(s string) (string, error) {
+	if c.invalidUTF8 == InvalidUTF8Allow || utf8.ValidString(s) {
+		return s, nil
+	}
+	if c.invalidUTF8 == InvalidUTF8Reject {
+		return s, fmt.Errorf("invalid UTF-8 at offset %d", invalidUTF8Offset(s))
+	}
+	b := make([]byte, 0, len(s)+16)
+	return b2s(appendValidUTF8(b, s)), nil
+}
+
+// invalidUTF8Offset returns the offset of the first invalid UTF-8 sequence in s.
+func invalidUTF8Offset(s string) int {
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			return i
+		}
+		i += size
+	}
+	return -1
+}
+
+// appendValidUTF8 appends s to dst, replacing each byte of invalid UTF-8
+// sequences with U+FFFD.
+func appendValidUTF8(dst []byte, s string) []byte {
+	for len(s) > 0 {
+		n := invalidUTF8Offset(s)
+		if n < 0 {
+			return append(dst, s...)
+		}
+		dst = append(dst, s[:n]...)
+		dst = append(dst, string(utf8.RuneError)...)
+		s = s[n+1:]
+	}
+	return dst
+}
@@ -0,0 +1,85 @@
+package fastjson
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParserInvalidUTF8(t *testing.T) {
+	f := func(policy InvalidUTF8, relaxed bool, s, expected string) {
+		t.Helper()
+		p := &Parser{
+			InvalidUTF8: policy,
+			Relaxed:     relaxed,
+		}
+		v, err := p.Parse(s)
+		if err != nil {
+			t.Fatalf("unexpected error when parsing %q: %s", s, err)
+		}
+		result := string(v.MarshalTo(nil))
+		if result != expected {
+			t.Fatalf("unexpected result for %q;\ngot\n%q\nwant\n%q", s, result, expected)
+		}
+	}
+
+	for _, relaxed := range []bool{false, true} {
+		s := "{\"a\xffb\":[\"x\xc3\",\"\xe2\x82\xac\"],\"c\":\"\xf0\x9f\x98\"}"
+		f(InvalidUTF8Allow, relaxed, s, s)
+		f(InvalidUTF8Replace, relaxed, s, "{\"a\ufffdb\":[\"x\ufffd\",\"\u20ac\"],\"c\":\"\ufffd\ufffd\ufffd\"}")
+
+		// Valid UTF-8 isn't modified.
+		s = "{\"\u043a\":\"\u20ac\\n\\u00e9\"}"
+		f(InvalidUTF8Reject, relaxed, s, "{\"\u043a\":\"\u20ac\\n\u00e9\"}")
+		f(InvalidUTF8Replace, relaxed, s, "{\"\u043a\":\"\u20ac\\n\u00e9\"}")
+	}
+
+	// Escaped strings with invalid UTF-8.
+	f(InvalidUTF8Replace, false, "[\"\\\"\xff\\u0041\"]", "[\"\\\"\ufffdA\"]")
+}
+
+func TestParserInvalidUTF8Get(t *testing.T) {
+	p := &Parser{
+		InvalidUTF8: InvalidUTF8Replace,
+	}
+	v, err := p.Parse("{\"k\xff\":\"v\xfe\\t\",\"d\":[\"\xc0\"]}")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	sb := v.GetStringBytes("k\ufffd")
+	if string(sb) != "v\ufffd\t" {
+		t.Fatalf("unexpected string; got %q; want %q", sb, "v\ufffd\t")
+	}
+	sb = v.GetStringBytes("d", "0")
+	if string(sb) != "\ufffd" {
+		t.Fatalf("unexpected string; got %q; want %q", sb, "\ufffd")
+	}
+}
+
+func TestParserInvalidUTF8Reject(t *testing.T) {
+	f := func(relaxed bool, s, expectedTail string) {
+		t.Helper()
+		p := &Parser{
+			InvalidUTF8: InvalidUTF8Reject,
+			Relaxed:     relaxed,
+		}
+		_, err := p.Parse(s)
+		if err == nil {
+			t.Fatalf("expecting non-nil error when parsing %q", s)
+		}
+		if !strings.Contains(err.Error(), "invalid UTF-8") {
+			t.Fatalf("unexpected error when parsing %q: %s", s, err)
+		}
+		if !strings.HasSuffix(err.Error(), fmt.Sprintf("unparsed tail: %q", expectedTail)) {
+			t.Fatalf("unexpected tail in the error when parsing %q: %s; want %q", s, err, expectedTail)
+		}
+	}
+
+	for _, relaxed := range []bool{false, true} {
+		f(relaxed, "\"\xff\"", "\"\xff\"")
+		f(relaxed, "[1,\"a\xc3\"]", "\"a\xc3\"]")
+		f(relaxed, "{\"a\":1,\"b\xe2\x82\":2}", "\"b\xe2\x82\":2}")
+		f(relaxed, "{\"a\":{\"b\":\"\xed\xa0\x80\"}}", "\"\xed\xa0\x80\"}}")
+	}
+	f(true, "['\xff']", "'\xff']")
+}
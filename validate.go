@@ -0,0 +1,712 @@
+package fastjson
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Validator validates JSON in a single pass without building Value tree.
+//
+// Validator keeps only O(depth) state, so it may validate arbitrarily
+// large inputs written to it in chunks via Write / WriteString.
+// Call Close after writing the whole input in order to verify
+// the input is complete.
+//
+// Validator is stricter than Parser - it accepts only valid JSON
+// according to RFC 8259, i.e. it rejects invalid numbers, invalid
+// string escapes and unescaped control chars in strings.
+// See ValidateStrict for details.
+//
+// Validator may be re-used after Reset call.
+//
+// Validator cannot be used from concurrent goroutines.
+type Validator struct {
+	// MultipleValues allows multiple JSON values delimited by optional
+	// whitespace in the input, like Scanner accepts.
+	//
+	// Empty input is valid if MultipleValues is set.
+	MultipleValues bool
+
+	// stack contains '{' and '[' chars for the currently open objects
+	// and arrays.
+	stack []byte
+
+	// lit contains the remaining chars of true, false or null literal.
+	lit string
+
+	// offset is the number of bytes written to the validator.
+	offset int
+
+	// err contains the first error found.
+	err error
+
+	// hexDigits is the number of the remaining hex digits in \u escape.
+	hexDigits int
+
+	state validatorState
+
+	// inKey is set when the validator is inside object key.
+	inKey bool
+}
+
+type validatorState int
+
+const (
+	vsInit validatorState = iota
+	vsValue
+	vsArrayFirst
+	vsObjectFirst
+	vsKey
+	vsColon
+	vsAfterValue
+	vsString
+	vsStringEscape
+	vsStringHex
+	vsNumMinus
+	vsNumZero
+	vsNumInt
+	vsNumDot
+	vsNumFrac
+	vsNumE
+	vsNumESign
+	vsNumExp
+	vsLiteral
+)
+
+// Reset resets vr, so it may be used for validating new input.
+//
+// MultipleValues setting is preserved.
+func (vr *Validator) Reset() {
+	vr.stack = vr.stack[:0]
+	vr.lit = ""
+	vr.offset = 0
+	vr.err = nil
+	vr.hexDigits = 0
+	vr.state = vsInit
+	vr.inKey = false
+}
+
+// Write validates the next chunk of the input.
+//
+// It returns non-nil error if invalid JSON is detected.
+// All the subsequent calls return the same error until Reset is called.
+func (vr *Validator) Write(p []byte) (int, error) {
+	if err := vr.validate(b2s(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// WriteString validates the next chunk of the input.
+//
+// It returns non-nil error if invalid JSON is detected.
+// All the subsequent calls return the same error until Reset is called.
+func (vr *Validator) WriteString(s string) (int, error) {
+	if err := vr.validate(s); err != nil {
+		return 0, err
+	}
+	return len(s), nil
+}
+
+// Close verifies the input written to vr is complete valid JSON.
+func (vr *Validator) Close() error {
+	if vr.err != nil {
+		return vr.err
+	}
+	switch vr.state {
+	case vsInit:
+		if !vr.MultipleValues {
+			vr.err = fmt.Errorf("cannot validate empty string")
+		}
+	case vsAfterValue:
+		if len(vr.stack) > 0 {
+			vr.err = vr.errorf("unexpected end of %s", vr.containerName())
+		}
+	case vsNumZero, vsNumInt, vsNumFrac, vsNumExp:
+		if len(vr.stack) > 0 {
+			vr.err = vr.errorf("unexpected end of %s", vr.containerName())
+		}
+	case vsString, vsStringEscape, vsStringHex:
+		vr.err = vr.errorf(`missing closing '"'`)
+	default:
+		vr.err = vr.errorf("unexpected end of JSON")
+	}
+	return vr.err
+}
+
+func (vr *Validator) containerName() string {
+	if vr.stack[len(vr.stack)-1] == '{' {
+		return "object"
+	}
+	return "array"
+}
+
+func (vr *Validator) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("cannot validate JSON at offset %d: %s", vr.offset, fmt.Sprintf(format, args...))
+}
+
+// valueDone must be called after the end of each value.
+func (vr *Validator) valueDone() {
+	vr.state = vsAfterValue
+}
+
+func (vr *Validator) validate(s string) error {
+	if vr.err != nil {
+		return vr.err
+	}
+	i := 0
+	for i < len(s) {
+		ch := s[i]
+		switch vr.state {
+		case vsInit:
+			if isWS(ch) {
+				i++
+				continue
+			}
+			vr.state = vsValue
+		case vsValue:
+			i++
+			switch {
+			case isWS(ch):
+			case ch == '{':
+				vr.stack = append(vr.stack, '{')
+				vr.state = vsObjectFirst
+			case ch == '[':
+				vr.stack = append(vr.stack, '[')
+				vr.state = vsArrayFirst
+			case ch == '"':
+				vr.inKey = false
+				vr.state = vsString
+			case ch == '-':
+				vr.state = vsNumMinus
+			case ch == '0':
+				vr.state = vsNumZero
+			case ch >= '1' && ch <= '9':
+				vr.state = vsNumInt
+			case ch == 't':
+				vr.lit = "rue"
+				vr.state = vsLiteral
+			case ch == 'f':
+				vr.lit = "alse"
+				vr.state = vsLiteral
+			case ch == 'n':
+				vr.lit = "ull"
+				vr.state = vsLiteral
+			default:
+				return vr.fail(i-1, "unexpected char %q when expecting value", ch)
+			}
+		case vsArrayFirst:
+			if isWS(ch) {
+				i++
+				continue
+			}
+			if ch == ']' {
+				i++
+				vr.stack = vr.stack[:len(vr.stack)-1]
+				vr.valueDone()
+				continue
+			}
+			vr.state = vsValue
+		case vsObjectFirst, vsKey:
+			i++
+			switch {
+			case isWS(ch):
+			case ch == '"':
+				vr.inKey = true
+				vr.state = vsString
+			case ch == '}' && vr.state == vsObjectFirst:
+				vr.stack = vr.stack[:len(vr.stack)-1]
+				vr.valueDone()
+			default:
+				return vr.fail(i-1, "unexpected char %q when expecting object key", ch)
+			}
+		case vsColon:
+			i++
+			switch {
+			case isWS(ch):
+			case ch == ':':
+				vr.state = vsValue
+			default:
+				return vr.fail(i-1, "missing ':' after object key")
+			}
+		case vsAfterValue:
+			if isWS(ch) {
+				i++
+				continue
+			}
+			if len(vr.stack) == 0 {
+				if !vr.MultipleValues {
+					return vr.fail(i, "unexpected tail: %q", s[i:])
+				}
+				vr.state = vsValue
+				continue
+			}
+			i++
+			top := vr.stack[len(vr.stack)-1]
+			switch {
+			case ch == ',' && top == '[':
+				vr.state = vsValue
+			case ch == ',' && top == '{':
+				vr.state = vsKey
+			case ch == ']' && top == '[', ch == '}' && top == '{':
+				vr.stack = vr.stack[:len(vr.stack)-1]
+				vr.valueDone()
+			default:
+				return vr.fail(i-1, "missing ',' after %s value", vr.containerName())
+			}
+		case vsString:
+			i = skipStringChars(s, i)
+			if i == len(s) {
+				continue
+			}
+			ch = s[i]
+			i++
+			switch {
+			case ch == '"':
+				if vr.inKey {
+					vr.state = vsColon
+				} else {
+					vr.valueDone()
+				}
+			case ch == '\\':
+				vr.state = vsStringEscape
+			default:
+				return vr.fail(i-1, "unescaped control char %q in string", ch)
+			}
+		case vsStringEscape:
+			i++
+			switch ch {
+			case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+				vr.state = vsString
+			case 'u':
+				vr.hexDigits = 4
+				vr.state = vsStringHex
+			default:
+				return vr.fail(i-1, "unknown escape sequence \\%c", ch)
+			}
+		case vsStringHex:
+			i++
+			if !isHexChar(ch) {
+				return vr.fail(i-1, "invalid char %q in \\u escape sequence", ch)
+			}
+			vr.hexDigits--
+			if vr.hexDigits == 0 {
+				vr.state = vsString
+			}
+		case vsNumMinus:
+			i++
+			switch {
+			case ch == '0':
+				vr.state = vsNumZero
+			case ch >= '1' && ch <= '9':
+				vr.state = vsNumInt
+			default:
+				return vr.fail(i-1, "missing digit after '-'")
+			}
+		case vsNumZero, vsNumInt, vsNumFrac, vsNumExp:
+			for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+				if vr.state == vsNumZero {
+					return vr.fail(i, "leading zero in number")
+				}
+				i++
+			}
+			if i == len(s) {
+				continue
+			}
+			ch = s[i]
+			switch {
+			case ch == '.' && (vr.state == vsNumZero || vr.state == vsNumInt):
+				i++
+				vr.state = vsNumDot
+			case (ch == 'e' || ch == 'E') && vr.state != vsNumExp:
+				i++
+				vr.state = vsNumE
+			default:
+				// The end of number. Process ch in the next state.
+				vr.valueDone()
+			}
+		case vsNumDot:
+			i++
+			if ch < '0' || ch > '9' {
+				return vr.fail(i-1, "missing digit after '.'")
+			}
+			vr.state = vsNumFrac
+		case vsNumE:
+			i++
+			switch {
+			case ch == '+' || ch == '-':
+				vr.state = vsNumESign
+			case ch >= '0' && ch <= '9':
+				vr.state = vsNumExp
+			default:
+				return vr.fail(i-1, "missing exponent digit")
+			}
+		case vsNumESign:
+			i++
+			if ch < '0' || ch > '9' {
+				return vr.fail(i-1, "missing exponent digit")
+			}
+			vr.state = vsNumExp
+		case vsLiteral:
+			i++
+			if ch != vr.lit[0] {
+				return vr.fail(i-1, "unexpected char %q in literal", ch)
+			}
+			vr.lit = vr.lit[1:]
+			if len(vr.lit) == 0 {
+				vr.valueDone()
+			}
+		default:
+			panic(fmt.Errorf("BUG: unexpected validator state: %d", vr.state))
+		}
+	}
+	vr.offset += len(s)
+	return nil
+}
+
+func (vr *Validator) fail(i int, format string, args ...interface{}) error {
+	vr.offset += i
+	vr.err = vr.errorf(format, args...)
+	return vr.err
+}
+
+func isWS(ch byte) bool {
+	// Whitespace chars are obtained from http://www.ietf.org/rfc/rfc4627.txt .
+	return ch == '\x20' || ch == '\x0A' || ch == '\x0D' || ch == '\x09'
+}
+
+// skipStringChars returns the index of the first char starting from s[i],
+// which requires special handling inside JSON string, i.e. '"', '\\'
+// or control char.
+//
+// It returns len(s) if there are no such chars.
+func skipStringChars(s string, i int) int {
+	// Fast path - check 8 bytes at once.
+	for i+8 <= len(s) {
+		x := uint64(s[i]) | uint64(s[i+1])<<8 | uint64(s[i+2])<<16 | uint64(s[i+3])<<24 |
+			uint64(s[i+4])<<32 | uint64(s[i+5])<<40 | uint64(s[i+6])<<48 | uint64(s[i+7])<<56
+		ctl := (x - 0x2020202020202020) &^ x
+		q := x ^ 0x2222222222222222
+		q = (q - 0x0101010101010101) &^ q
+		bs := x ^ 0x5c5c5c5c5c5c5c5c
+		bs = (bs - 0x0101010101010101) &^ bs
+		if (ctl|q|bs)&0x8080808080808080 != 0 {
+			break
+		}
+		i += 8
+	}
+
+	// Slow path - check the remaining bytes one by one.
+	for i < len(s) {
+		ch := s[i]
+		if ch < 0x20 || ch == '"' || ch == '\\' {
+			return i
+		}
+		i++
+	}
+	return i
+}
+
+// hasSpecialStringChars returns true if s contains '\\' or control chars.
+func hasSpecialStringChars(s string) bool {
+	i := 0
+	for i+8 <= len(s) {
+		x := uint64(s[i]) | uint64(s[i+1])<<8 | uint64(s[i+2])<<16 | uint64(s[i+3])<<24 |
+			uint64(s[i+4])<<32 | uint64(s[i+5])<<40 | uint64(s[i+6])<<48 | uint64(s[i+7])<<56
+		bs := x ^ 0x5c5c5c5c5c5c5c5c
+		if ((x-0x2020202020202020)&^x|(bs-0x0101010101010101)&^bs)&0x8080808080808080 != 0 {
+			return true
+		}
+		i += 8
+	}
+	for i < len(s) {
+		if s[i] < 0x20 || s[i] == '\\' {
+			return true
+		}
+		i++
+	}
+	return false
+}
+
+func isHexChar(ch byte) bool {
+	return (ch >= '0' && ch <= '9') || (ch >= 'a' && ch <= 'f') || (ch >= 'A' && ch <= 'F')
+}
+
+// validate validates the whole JSON s.
+//
+// It accepts the same input as Parser with the default settings
+// if strict isn't set. Otherwise it accepts only valid JSON according
+// to RFC 8259 like Validator does.
+//
+// It is faster than Validator, since it processes the whole input at once
+// instead of a byte-by-byte state machine.
+func validate(s string, strict bool) error {
+	tail, err := validateValue(skipWS(s), strict)
+	if err == nil {
+		tail = skipWS(tail)
+		if len(tail) > 0 {
+			err = fmt.Errorf("unexpected tail: %q", tail)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("cannot validate JSON at offset %d: %s", len(s)-len(tail), err)
+	}
+	return nil
+}
+
+// validateValue validates JSON value at the start of s.
+//
+// Nested arrays and objects are validated iteratively with an explicit
+// stack, so the nesting depth isn't limited by the goroutine stack.
+func validateValue(s string, strict bool) (string, error) {
+	// stack contains '{' and '[' chars for the currently open objects
+	// and arrays. Memory is allocated only for deeply nested input.
+	var buf [64]byte
+	stack := buf[:0]
+	var err error
+
+validateNext:
+	for {
+		if len(s) == 0 {
+			return s, fmt.Errorf("cannot validate empty string")
+		}
+		switch s[0] {
+		case '{':
+			s = skipWS(s[1:])
+			if len(s) > 0 && s[0] == '}' {
+				s = s[1:]
+				break
+			}
+			stack = append(stack, '{')
+			if s, err = validateKey(s, strict); err != nil {
+				return s, err
+			}
+			continue
+		case '[':
+			s = skipWS(s[1:])
+			if len(s) > 0 && s[0] == ']' {
+				s = s[1:]
+				break
+			}
+			stack = append(stack, '[')
+			continue
+		case '"':
+			if s, err = validateRawString(s, strict); err != nil {
+				return s, err
+			}
+		case 't':
+			if s, err = validateLiteral(s, "true"); err != nil {
+				return s, err
+			}
+		case 'f':
+			if s, err = validateLiteral(s, "false"); err != nil {
+				return s, err
+			}
+		case 'n':
+			if s, err = validateLiteral(s, "null"); err != nil {
+				return s, err
+			}
+		default:
+			if strict {
+				s, err = validateNumber(s)
+			} else {
+				_, s, err = parseRawNumber(s)
+			}
+			if err != nil {
+				return s, err
+			}
+		}
+
+		// Close the parent arrays and objects, which end after the value.
+		for len(stack) > 0 {
+			s = skipWS(s)
+			isArray := stack[len(stack)-1] == '['
+			if len(s) == 0 {
+				if isArray {
+					return s, fmt.Errorf("unexpected end of array")
+				}
+				return s, fmt.Errorf("unexpected end of object")
+			}
+			if s[0] == ',' {
+				if isArray {
+					s = skipWS(s[1:])
+				} else if s, err = validateKey(s[1:], strict); err != nil {
+					return s, err
+				}
+				continue validateNext
+			}
+			if isArray && s[0] != ']' {
+				return s, fmt.Errorf("missing ',' after array value")
+			}
+			if !isArray && s[0] != '}' {
+				return s, fmt.Errorf("missing ',' after object value")
+			}
+			s = s[1:]
+			stack = stack[:len(stack)-1]
+		}
+		return s, nil
+	}
+}
+
+func validateLiteral(s, lit string) (string, error) {
+	if len(s) < len(lit) || s[:len(lit)] != lit {
+		return s, fmt.Errorf("unexpected value found: %q", s)
+	}
+	return s[len(lit):], nil
+}
+
+// validateKey validates object key at the start of s followed by ':'.
+//
+// It returns the tail starting at the value for the key.
+func validateKey(s string, strict bool) (string, error) {
+	s = skipWS(s)
+	if len(s) == 0 || s[0] != '"' {
+		return s, fmt.Errorf(`missing opening '"' for object key`)
+	}
+	s, err := validateRawString(s, strict)
+	if err != nil {
+		return s, err
+	}
+	s = skipWS(s)
+	if len(s) == 0 || s[0] != ':' {
+		return s, fmt.Errorf("missing ':' after object key")
+	}
+	return skipWS(s[1:]), nil
+}
+
+// validateRawString validates JSON string at the start of s.
+func validateRawString(s string, strict bool) (string, error) {
+	if strict {
+		return validateString(s[1:])
+	}
+	_, tail, err := parseRawString(s)
+	return tail, err
+}
+
+// validateString validates JSON string s without the opening '"'.
+func validateString(s string) (string, error) {
+	n := strings.IndexByte(s, '"')
+	if n < 0 {
+		return "", fmt.Errorf(`missing closing '"'`)
+	}
+	if !hasSpecialStringChars(s[:n]) {
+		// Fast path - no escape sequences and control chars.
+		return s[n+1:], nil
+	}
+
+	// Slow path - validate escape sequences.
+	for {
+		n := skipStringChars(s, 0)
+		if n == len(s) {
+			return s[n:], fmt.Errorf(`missing closing '"'`)
+		}
+		ch := s[n]
+		s = s[n+1:]
+		switch {
+		case ch == '"':
+			return s, nil
+		case ch != '\\':
+			return s, fmt.Errorf("unescaped control char %q in string", ch)
+		case len(s) == 0:
+			return s, fmt.Errorf(`missing closing '"'`)
+		}
+		switch s[0] {
+		case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+			s = s[1:]
+		case 'u':
+			if len(s) < 5 || !isHexChar(s[1]) || !isHexChar(s[2]) || !isHexChar(s[3]) || !isHexChar(s[4]) {
+				return s, fmt.Errorf("invalid \\u escape sequence")
+			}
+			s = s[5:]
+		default:
+			return s, fmt.Errorf("unknown escape sequence \\%c", s[0])
+		}
+	}
+}
+
+func validateNumber(s string) (string, error) {
+	i := 0
+	if s[0] == '-' {
+		i++
+	}
+	if i == len(s) || s[i] < '0' || s[i] > '9' {
+		return s[i:], fmt.Errorf("unexpected char when expecting number: %q", s[i:])
+	}
+	if s[i] == '0' {
+		i++
+	} else {
+		i = skipDigits(s, i)
+	}
+	if i < len(s) && s[i] == '.' {
+		i++
+		if i == len(s) || s[i] < '0' || s[i] > '9' {
+			return s[i:], fmt.Errorf("missing digit after '.'")
+		}
+		i = skipDigits(s, i)
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			i++
+		}
+		if i == len(s) || s[i] < '0' || s[i] > '9' {
+			return s[i:], fmt.Errorf("missing exponent digit")
+		}
+		i = skipDigits(s, i)
+	}
+	return s[i:], nil
+}
+
+func skipDigits(s string, i int) int {
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return i
+}
+
+var validatorPool sync.Pool
+
+func getValidator() *Validator {
+	v := validatorPool.Get()
+	if v == nil {
+		return &Validator{}
+	}
+	return v.(*Validator)
+}
+
+func putValidator(vr *Validator) {
+	vr.Reset()
+	vr.MultipleValues = false
+	validatorPool.Put(vr)
+}
+
+// ValidateReader validates JSON read from r.
+//
+// The input is validated in a single pass using O(depth) memory.
+// It must be valid JSON according to RFC 8259 like ValidateStrict requires.
+func ValidateReader(r io.Reader) error {
+	vr := getValidator()
+	_, err := io.Copy(vr, r)
+	if err == nil {
+		err = vr.Close()
+	}
+	putValidator(vr)
+	return err
+}
+
+// ValidateStream validates a stream of JSON values read from r.
+//
+// Values may be delimited by whitespace like Scanner accepts.
+// They must be valid JSON according to RFC 8259 like ValidateStrict requires.
+func ValidateStream(r io.Reader) error {
+	vr := getValidator()
+	vr.MultipleValues = true
+	_, err := io.Copy(vr, r)
+	if err == nil {
+		err = vr.Close()
+	}
+	putValidator(vr)
+	return err
+}
@@ -0,0 +1,253 @@
+package fastjson
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidatorSuccess(t *testing.T) {
+	f := func(s string) {
+		t.Helper()
+		if err := ValidateStrict(s); err != nil {
+			t.Fatalf("unexpected error when validating %q: %s", s, err)
+		}
+		if err := Validate(s); err != nil {
+			t.Fatalf("unexpected error when validating %q in non-strict mode: %s", s, err)
+		}
+		if err := validateByteByByte(s); err != nil {
+			t.Fatalf("unexpected error when validating %q byte by byte: %s", s, err)
+		}
+	}
+
+	f(`0`)
+	f(` -0.5e+10 `)
+	f(`123.456E-7`)
+	f(`""`)
+	f(`"foo\"\\\/\b\f\n\r\tኯ bar"`)
+	f(`"тест"`)
+	f(`true`)
+	f(`false`)
+	f(`null`)
+	f(`[]`)
+	f(`{}`)
+	f("\t[ 1 , [ ] , { } , \"x\" ]\r\n")
+	f(`{"foo":{"bar":[1,{"baz":null}]},"x":-1}`)
+	f(largeFixture)
+}
+
+func TestValidatorError(t *testing.T) {
+	f := func(s string) {
+		t.Helper()
+		if err := ValidateStrict(s); err == nil {
+			t.Fatalf("expecting non-nil error when validating %q", s)
+		}
+		if err := validateByteByByte(s); err == nil {
+			t.Fatalf("expecting non-nil error when validating %q byte by byte", s)
+		}
+	}
+
+	f(``)
+	f(`   `)
+	f(`foobar`)
+	f(`tru`)
+	f(`truex`)
+	f(`nul`)
+	f(`01`)
+	f(`-`)
+	f(`1.`)
+	f(`1.e5`)
+	f(`1e`)
+	f(`1e+`)
+	f(`123+456`)
+	f(`+1`)
+	f(`.1`)
+	f(`"`)
+	f(`"foo`)
+	f(`"foo\"`)
+	f(`"\q"`)
+	f(`"\u12"`)
+	f(`"\u12x4"`)
+	f("\"foo\nbar\"")
+	f(`[`)
+	f(`[1,]`)
+	f(`[1 2]`)
+	f(`[1}`)
+	f(`{`)
+	f(`{"foo"}`)
+	f(`{"foo":}`)
+	f(`{"foo":1,}`)
+	f(`{foo:1}`)
+	f(`{"foo":1]`)
+	f(`{} {}`)
+	f(`[] x`)
+}
+
+func TestValidateMatchesParser(t *testing.T) {
+	f := func(s string) {
+		t.Helper()
+		var p Parser
+		_, errParse := p.Parse(s)
+		err := Validate(s)
+		if (err == nil) != (errParse == nil) {
+			t.Fatalf("Validate and Parser disagree on %q; Validate error: %v; Parser error: %v", s, err, errParse)
+		}
+		if err := ValidateBytes([]byte(s)); (err == nil) != (errParse == nil) {
+			t.Fatalf("ValidateBytes and Parser disagree on %q; ValidateBytes error: %v; Parser error: %v", s, err, errParse)
+		}
+	}
+
+	// Valid JSON.
+	f(`0`)
+	f(` {"foo":[1,{"bar":null}],"x":"y\"z"} `)
+	f(largeFixture)
+
+	// Invalid JSON accepted by Parser.
+	f(`01`)
+	f(`-`)
+	f(`1.`)
+	f(`+1`)
+	f(`123+456`)
+	f(`Infinity`)
+	f(`-inf`)
+	f(`NaN`)
+	f(`"\q"`)
+	f(`"\u12"`)
+	f("\"foo\nbar\"")
+	f(`[01,"\x"]`)
+	f(`{"a\z":1.e5}`)
+
+	// Invalid JSON rejected by Parser.
+	f(``)
+	f(`   `)
+	f(`nan`)
+	f(`.1`)
+	f(`tru`)
+	f(`nul`)
+	f(`"foo`)
+	f(`"foo\"`)
+	f(`[`)
+	f(`[ `)
+	f(`[1,]`)
+	f(`[1 2]`)
+	f(`[1}`)
+	f(`[1]]`)
+	f(`{`)
+	f(`{ `)
+	f(`{"foo"}`)
+	f(`{"foo":}`)
+	f(`{"foo":1,}`)
+	f(`{foo:1}`)
+	f(`{"foo":1]`)
+	f(`{} {}`)
+	f(`[] x`)
+	f(`[{"a":[1,{"b":tru}]}]`)
+}
+
+func TestValidateDeeplyNested(t *testing.T) {
+	const depth = 1000000
+	s := strings.Repeat(`[{"a":`, depth) + `1` + strings.Repeat(`}]`, depth)
+	if err := Validate(s); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := ValidateStrict(s); err != nil {
+		t.Fatalf("unexpected error in strict mode: %s", err)
+	}
+	s = strings.Repeat(`[{"a":`, depth) + `1` + strings.Repeat(`}]`, depth-1) + `}`
+	if err := Validate(s); err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+}
+
+func TestValidateNoAllocs(t *testing.T) {
+	for _, s := range []string{smallFixture, mediumFixture, largeFixture} {
+		n := testing.AllocsPerRun(10, func() {
+			if err := Validate(s); err != nil {
+				panic(err)
+			}
+			if err := ValidateStrict(s); err != nil {
+				panic(err)
+			}
+		})
+		if n > 0 {
+			t.Fatalf("unexpected memory allocations: %v", n)
+		}
+	}
+}
+
+func validateByteByByte(s string) error {
+	var vr Validator
+	for i := 0; i < len(s); i++ {
+		if _, err := vr.WriteString(s[i : i+1]); err != nil {
+			return err
+		}
+	}
+	return vr.Close()
+}
+
+func TestValidatorChunks(t *testing.T) {
+	s := `{"foo":[1.5e-3,"bar\"",true,{"x":null}],"y":-0}`
+	for n := 1; n < len(s); n++ {
+		var vr Validator
+		for i := 0; i < len(s); i += n {
+			end := i + n
+			if end > len(s) {
+				end = len(s)
+			}
+			if _, err := vr.WriteString(s[i:end]); err != nil {
+				t.Fatalf("unexpected error for chunk size %d: %s", n, err)
+			}
+		}
+		if err := vr.Close(); err != nil {
+			t.Fatalf("unexpected error on close for chunk size %d: %s", n, err)
+		}
+	}
+
+	var vr Validator
+	if _, err := vr.WriteString(`[1,2`); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := vr.Close(); err == nil {
+		t.Fatalf("expecting non-nil error for incomplete input")
+	}
+
+	vr.Reset()
+	if _, err := vr.WriteString(`[1,x]`); err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+	if _, err := vr.WriteString(`1`); err == nil {
+		t.Fatalf("expecting non-nil error after the first error")
+	}
+}
+
+func TestValidatorMultipleValues(t *testing.T) {
+	f := func(s string, expectError bool) {
+		t.Helper()
+		err := ValidateStream(strings.NewReader(s))
+		if expectError && err == nil {
+			t.Fatalf("expecting non-nil error when validating %q", s)
+		}
+		if !expectError && err != nil {
+			t.Fatalf("unexpected error when validating %q: %s", s, err)
+		}
+	}
+
+	f(``, false)
+	f(" \n ", false)
+	f(`[] {} "" 123`, false)
+	f(`[]{}""123"xyz"true null`, false)
+	f("{\"a\":1}\n{\"a\":2}\n", false)
+	f(`[] sdfdsfdf`, true)
+	f(`{"a":1} {"a":`, true)
+}
+
+func TestValidateReader(t *testing.T) {
+	if err := ValidateReader(strings.NewReader(largeFixture)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := ValidateReader(strings.NewReader(`{"foo": bar}`)); err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+	if err := ValidateReader(strings.NewReader(`1 2`)); err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+}
@@ -0,0 +1,366 @@
+package fastjson
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Writer writes JSON tokens to io.Writer or to an internal buffer.
+//
+// Writer verifies that the written tokens form valid JSON. The first error
+// is remembered and returned from Flush and Close, while the subsequent
+// writes are ignored, so errors may be checked only once after writing
+// the whole JSON.
+//
+// Zero Writer collects the output in an internal buffer. Call Reset
+// for writing the output to io.Writer.
+//
+// Writer may be re-used after Reset call.
+//
+// Writer cannot be used from concurrent goroutines.
+type Writer struct {
+	// EscapeHTML enables escaping of <, > and & chars in strings, so the
+	// output may be safely embedded into HTML. U+2028 and U+2029
+	// line terminators are escaped too, so the output may be safely
+	// embedded into JavaScript.
+	EscapeHTML bool
+
+	// ASCII enables escaping of all the non-ASCII chars in strings
+	// with \uXXXX escape sequences. Invalid UTF-8 sequences are replaced
+	// with U+FFFD in this mode.
+	ASCII bool
+
+	// MultipleValues allows writing multiple top-level values.
+	// Values are delimited by '\n', so the output is JSON lines
+	// ( http://jsonlines.org/ ).
+	MultipleValues bool
+
+	// Sequence enables writing JSON text sequences
+	// ( https://tools.ietf.org/html/rfc7464 ), which are also known
+	// as application/json-seq. Each top-level value is prefixed
+	// with the record separator (0x1E) and is followed by '\n'.
+	//
+	// Multiple top-level values may be written in this mode.
+	Sequence bool
+
+	w io.Writer
+
+	// b contains buffered output.
+	b []byte
+
+	// stack contains '{' and '[' chars for the currently open objects
+	// and arrays.
+	stack []byte
+
+	// err contains the first error.
+	err error
+
+	// values is the number of written top-level values.
+	values int
+
+	// needComma is set if the current object or array already
+	// contains items.
+	needComma bool
+
+	// afterKey is set if an object key has been written
+	// and the value is expected.
+	afterKey bool
+}
+
+// writerFlushSize is the size of buffered output, which is flushed
+// to the underlying io.Writer.
+const writerFlushSize = 64 * 1024
+
+// Reset resets jw, so it writes JSON to w.
+//
+// The output is collected in an internal buffer if w is nil.
+// Use Bytes for obtaining the buffered output in this case.
+//
+// EscapeHTML, ASCII, MultipleValues and Sequence settings are preserved.
+func (jw *Writer) Reset(w io.Writer) {
+	jw.w = w
+	jw.b = jw.b[:0]
+	jw.stack = jw.stack[:0]
+	jw.err = nil
+	jw.values = 0
+	jw.needComma = false
+	jw.afterKey = false
+}
+
+// Bytes returns the buffered output.
+//
+// The returned bytes are valid until the next call to jw methods.
+func (jw *Writer) Bytes() []byte {
+	return jw.b
+}
+
+// Flush writes the buffered output to the io.Writer passed to Reset.
+//
+// It returns the first error occurred during writing.
+func (jw *Writer) Flush() error {
+	if jw.err != nil {
+		return jw.err
+	}
+	if jw.w == nil || len(jw.b) == 0 {
+		return nil
+	}
+	if _, err := jw.w.Write(jw.b); err != nil {
+		jw.err = fmt.Errorf("cannot write JSON: %s", err)
+		return jw.err
+	}
+	jw.b = jw.b[:0]
+	return nil
+}
+
+// Close verifies that complete JSON has been written and flushes
+// the buffered output.
+//
+// It returns the first error occurred during writing.
+func (jw *Writer) Close() error {
+	if jw.err == nil {
+		if len(jw.stack) > 0 {
+			jw.errorf("missing %q", closingChar(jw.stack[len(jw.stack)-1]))
+		} else if jw.values == 0 && !jw.MultipleValues && !jw.Sequence {
+			jw.errorf("missing JSON value")
+		}
+	}
+	return jw.Flush()
+}
+
+// BeginObject starts JSON object.
+func (jw *Writer) BeginObject() {
+	jw.beginContainer('{')
+}
+
+// EndObject ends JSON object started with BeginObject.
+func (jw *Writer) EndObject() {
+	jw.endContainer('{')
+}
+
+// BeginArray starts JSON array.
+func (jw *Writer) BeginArray() {
+	jw.beginContainer('[')
+}
+
+// EndArray ends JSON array started with BeginArray.
+func (jw *Writer) EndArray() {
+	jw.endContainer('[')
+}
+
+// Key writes object key k.
+//
+// The value for the key must be written after the key.
+func (jw *Writer) Key(k string) {
+	if jw.err != nil {
+		return
+	}
+	if len(jw.stack) == 0 || jw.stack[len(jw.stack)-1] != '{' {
+		jw.errorf("cannot write key %q outside object", k)
+		return
+	}
+	if jw.afterKey {
+		jw.errorf("missing value before key %q", k)
+		return
+	}
+	if jw.needComma {
+		jw.b = append(jw.b, ',')
+	}
+	jw.b = appendEscapedStringFlags(jw.b, k, jw.escapeFlags())
+	jw.b = append(jw.b, ':')
+	jw.afterKey = true
+}
+
+// String writes string s.
+func (jw *Writer) String(s string) {
+	if !jw.beforeValue() {
+		return
+	}
+	jw.b = appendEscapedStringFlags(jw.b, s, jw.escapeFlags())
+	jw.afterValue()
+}
+
+// StringBytes writes string b.
+func (jw *Writer) StringBytes(b []byte) {
+	jw.String(b2s(b))
+}
+
+// Int64 writes number n.
+func (jw *Writer) Int64(n int64) {
+	if !jw.beforeValue() {
+		return
+	}
+	jw.b = strconv.AppendInt(jw.b, n, 10)
+	jw.afterValue()
+}
+
+// Uint64 writes number n.
+func (jw *Writer) Uint64(n uint64) {
+	if !jw.beforeValue() {
+		return
+	}
+	jw.b = strconv.AppendUint(jw.b, n, 10)
+	jw.afterValue()
+}
+
+// Float64 writes number f.
+//
+// Infinity and NaN are written as null, since JSON cannot represent them.
+func (jw *Writer) Float64(f float64) {
+	if !jw.beforeValue() {
+		return
+	}
+	jw.b = appendFloat64(jw.b, f)
+	jw.afterValue()
+}
+
+// Bool writes true or false.
+func (jw *Writer) Bool(b bool) {
+	if !jw.beforeValue() {
+		return
+	}
+	if b {
+		jw.b = append(jw.b, "true"...)
+	} else {
+		jw.b = append(jw.b, "false"...)
+	}
+	jw.afterValue()
+}
+
+// Null writes null.
+func (jw *Writer) Null() {
+	if !jw.beforeValue() {
+		return
+	}
+	jw.b = append(jw.b, "null"...)
+	jw.afterValue()
+}
+
+// Raw writes JSON value b as is.
+//
+// b must contain a single valid JSON value. Surrounding whitespace
+// is stripped.
+func (jw *Writer) Raw(b []byte) {
+	if jw.err != nil {
+		return
+	}
+	s := b2s(b)
+	if err := validate(s, true); err != nil {
+		jw.errorf("cannot write invalid raw JSON: %s", err)
+		return
+	}
+	if !jw.beforeValue() {
+		return
+	}
+	s = skipWS(s)
+	tail, _ := validateValue(s, true)
+	jw.b = append(jw.b, s[:len(s)-len(tail)]...)
+	jw.afterValue()
+}
+
+// Value writes v.
+func (jw *Writer) Value(v *Value) {
+	if !jw.beforeValue() {
+		return
+	}
+	jw.b = v.marshalTo(jw.b, jw.escapeFlags())
+	jw.afterValue()
+}
+
+func (jw *Writer) beginContainer(ch byte) {
+	if !jw.beforeValue() {
+		return
+	}
+	jw.b = append(jw.b, ch)
+	jw.stack = append(jw.stack, ch)
+	jw.needComma = false
+}
+
+func (jw *Writer) endContainer(ch byte) {
+	if jw.err != nil {
+		return
+	}
+	if len(jw.stack) == 0 || jw.stack[len(jw.stack)-1] != ch {
+		jw.errorf("unexpected %q", closingChar(ch))
+		return
+	}
+	if jw.afterKey {
+		jw.errorf("missing value for the last key before %q", closingChar(ch))
+		return
+	}
+	jw.stack = jw.stack[:len(jw.stack)-1]
+	jw.b = append(jw.b, closingChar(ch))
+	jw.afterValue()
+}
+
+// beforeValue prepares jw for writing a value.
+//
+// It returns false if the value cannot be written.
+func (jw *Writer) beforeValue() bool {
+	if jw.err != nil {
+		return false
+	}
+	if len(jw.stack) == 0 {
+		if jw.Sequence {
+			jw.b = append(jw.b, recordSeparator)
+			return true
+		}
+		if jw.values > 0 {
+			if !jw.MultipleValues {
+				jw.errorf("cannot write multiple top-level values without MultipleValues")
+				return false
+			}
+			jw.b = append(jw.b, '\n')
+		}
+		return true
+	}
+	if jw.stack[len(jw.stack)-1] == '{' {
+		if !jw.afterKey {
+			jw.errorf("missing key before object value")
+			return false
+		}
+		jw.afterKey = false
+		return true
+	}
+	if jw.needComma {
+		jw.b = append(jw.b, ',')
+	}
+	return true
+}
+
+// afterValue must be called after writing a value.
+func (jw *Writer) afterValue() {
+	if len(jw.stack) > 0 {
+		jw.needComma = true
+	} else {
+		jw.values++
+		if jw.Sequence {
+			jw.b = append(jw.b, '\n')
+		}
+	}
+	if jw.w != nil && len(jw.b) >= writerFlushSize {
+		jw.Flush()
+	}
+}
+
+func (jw *Writer) escapeFlags() escapeFlags {
+	var flags escapeFlags
+	if jw.EscapeHTML {
+		flags |= escapeHTML
+	}
+	if jw.ASCII {
+		flags |= escapeASCII
+	}
+	return flags
+}
+
+func (jw *Writer) errorf(format string, args ...interface{}) {
+	jw.err = fmt.Errorf("cannot write JSON: "+format, args...)
+}
+
+func closingChar(ch byte) byte {
+	if ch == '{' {
+		return '}'
+	}
+	return ']'
+}
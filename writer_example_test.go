@@ -0,0 +1,31 @@
+package fastjson_test
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/valyala/fastjson"
+)
+
+func ExampleWriter() {
+	var w fastjson.Writer
+	w.Reset(os.Stdout)
+	w.BeginObject()
+	w.Key("name")
+	w.String("<foo>")
+	w.Key("items")
+	w.BeginArray()
+	for i := 0; i < 3; i++ {
+		w.Int64(int64(i))
+	}
+	w.EndArray()
+	w.EndObject()
+	if err := w.Close(); err != nil {
+		log.Fatalf("cannot write JSON: %s", err)
+	}
+	fmt.Println()
+
+	// Output:
+	// {"name":"<foo>","items":[0,1,2]}
+}
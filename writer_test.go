@@ -0,0 +1,327 @@
+package fastjson
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestWriter(t *testing.T) {
+	var w Writer
+	w.BeginObject()
+	w.Key("str")
+	w.String("foo\n\"bar\"")
+	w.Key("int")
+	w.Int64(-123)
+	w.Key("uint")
+	w.Uint64(math.MaxUint64)
+	w.Key("float")
+	w.Float64(1.5e-7)
+	w.Key("inf")
+	w.Float64(math.Inf(1))
+	w.Key("arr")
+	w.BeginArray()
+	w.Bool(true)
+	w.Bool(false)
+	w.Null()
+	w.BeginArray()
+	w.EndArray()
+	w.BeginObject()
+	w.EndObject()
+	w.StringBytes([]byte("x"))
+	w.EndArray()
+	w.Key("raw")
+	w.Raw([]byte(` {"a": [1, 2]} `))
+	w.EndObject()
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	result := string(w.Bytes())
+	expected := `{"str":"foo\n\"bar\"","int":-123,"uint":18446744073709551615,"float":1.5e-07,"inf":null,"arr":[true,false,null,[],{},"x"],"raw":{"a": [1, 2]}}`
+	if result != expected {
+		t.Fatalf("unexpected result;\ngot\n%s\nwant\n%s", result, expected)
+	}
+	if err := ValidateStrict(result); err != nil {
+		t.Fatalf("invalid JSON written: %s", err)
+	}
+}
+
+func TestWriterValue(t *testing.T) {
+	var p Parser
+	v, err := p.Parse(`{"a": [1, "x\u0001y", {"b": null}], "c\"d": -1.50}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var w Writer
+	w.BeginArray()
+	w.Value(v)
+	w.Value(v.Get("a", "2"))
+	w.EndArray()
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	result := string(w.Bytes())
+	expected := `[{"a":[1,"x\u0001y",{"b":null}],"c\"d":-1.50},{"b":null}]`
+	if result != expected {
+		t.Fatalf("unexpected result;\ngot\n%s\nwant\n%s", result, expected)
+	}
+}
+
+func TestWriterValueInvalidNumber(t *testing.T) {
+	f := func(s, expected string) {
+		t.Helper()
+		var p Parser
+		v, err := p.Parse(s)
+		if err != nil {
+			t.Fatalf("unexpected error when parsing %q: %s", s, err)
+		}
+		var w Writer
+		w.Value(v)
+		if err := w.Close(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		result := string(w.Bytes())
+		if result != expected {
+			t.Fatalf("unexpected result for %q; got %q; want %q", s, result, expected)
+		}
+		if err := Validate(result); err != nil {
+			t.Fatalf("invalid JSON written for %q: %s", s, err)
+		}
+		if result := string(v.MarshalTo(nil)); result != expected {
+			t.Fatalf("unexpected MarshalTo result for %q; got %q; want %q", s, result, expected)
+		}
+	}
+	f(`[-]`, `[0]`)
+	f(`[01]`, `[1]`)
+	f(`[1.]`, `[1]`)
+	f(`{"a":1e}`, `{"a":0}`)
+	f(`[+1]`, `[1]`)
+	f(`[-0.5e+3]`, `[-0.5e+3]`)
+}
+
+func TestWriterEscaping(t *testing.T) {
+	f := func(escapeHTML, ascii bool, s, expected string) {
+		t.Helper()
+		w := &Writer{
+			EscapeHTML: escapeHTML,
+			ASCII:      ascii,
+		}
+		w.BeginObject()
+		w.Key(s)
+		w.String(s)
+		w.EndObject()
+		if err := w.Close(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		result := string(w.Bytes())
+		expected = "{" + expected + ":" + expected + "}"
+		if result != expected {
+			t.Fatalf("unexpected result for %q;\ngot\n%s\nwant\n%s", s, result, expected)
+		}
+
+		// Verify that the string is unescaped to the original value.
+		var p Parser
+		v, err := p.Parse(result)
+		if err != nil {
+			t.Fatalf("cannot parse %s: %s", result, err)
+		}
+		if !strings.Contains(s, "\xff") {
+			if sb := v.GetStringBytes(s); string(sb) != s {
+				t.Fatalf("unexpected unescaped string; got %q; want %q", sb, s)
+			}
+		}
+	}
+
+	f(false, false, "", `""`)
+	f(false, false, "foo", `"foo"`)
+	f(false, false, "<a href=\"x\">&\t\x00</a>", `"<a href=\"x\">&\t\u0000</a>"`)
+	f(true, false, "<a href=\"x\">&\t\x00</a>", `"\u003ca href=\"x\"\u003e\u0026\t\u0000\u003c/a\u003e"`)
+	f(false, false, "\u043f\u0440\u0438", "\"\u043f\u0440\u0438\"")
+	f(false, false, "\u2028\u2029", "\"\u2028\u2029\"")
+	f(true, false, "\u2028\u2029", `"\u2028\u2029"`)
+	f(false, true, "\u043f\u0440\u0438", `"\u043f\u0440\u0438"`)
+	f(false, true, "a\U0001f600b", `"a\ud83d\ude00b"`)
+	f(false, true, "a\xffb", `"a\ufffdb"`)
+	f(true, true, "<\u0444>", `"\u003c\u0444\u003e"`)
+}
+
+func TestWriterMultipleValues(t *testing.T) {
+	var bb bytes.Buffer
+	w := &Writer{
+		MultipleValues: true,
+	}
+	w.Reset(&bb)
+	w.Int64(1)
+	w.BeginObject()
+	w.Key("a")
+	w.String("b")
+	w.EndObject()
+	w.Null()
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	result := bb.String()
+	expected := "1\n{\"a\":\"b\"}\nnull"
+	if result != expected {
+		t.Fatalf("unexpected result;\ngot\n%q\nwant\n%q", result, expected)
+	}
+	if len(w.Bytes()) != 0 {
+		t.Fatalf("unexpected buffered data after Close: %q", w.Bytes())
+	}
+}
+
+func TestWriterFlush(t *testing.T) {
+	var bb bytes.Buffer
+	var w Writer
+	w.Reset(&bb)
+	w.BeginArray()
+	item := strings.Repeat("x", 1000)
+	for i := 0; i < 100; i++ {
+		w.String(item)
+	}
+
+	// Big output must be flushed automatically.
+	if bb.Len() == 0 {
+		t.Fatalf("expecting non-empty flushed output")
+	}
+	w.EndArray()
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := ValidateStrict(bb.String()); err != nil {
+		t.Fatalf("invalid JSON written: %s", err)
+	}
+
+	// Reuse the writer.
+	bb.Reset()
+	w.Reset(&bb)
+	w.Int64(1)
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if bb.String() != "1" {
+		t.Fatalf("unexpected output; got %q; want %q", bb.String(), "1")
+	}
+}
+
+type errorWriter struct{}
+
+func (ew *errorWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("write error")
+}
+
+func TestWriterError(t *testing.T) {
+	f := func(name string, write func(w *Writer)) {
+		t.Helper()
+		var w Writer
+		write(&w)
+		if err := w.Close(); err == nil {
+			t.Fatalf("%s: expecting non-nil error; output: %s", name, w.Bytes())
+		}
+	}
+
+	f("empty", func(w *Writer) {})
+	f("unclosed object", func(w *Writer) {
+		w.BeginObject()
+	})
+	f("unclosed array", func(w *Writer) {
+		w.BeginArray()
+		w.BeginObject()
+		w.EndObject()
+	})
+	f("mismatched close", func(w *Writer) {
+		w.BeginArray()
+		w.EndObject()
+	})
+	f("extra close", func(w *Writer) {
+		w.BeginArray()
+		w.EndArray()
+		w.EndArray()
+	})
+	f("value without key", func(w *Writer) {
+		w.BeginObject()
+		w.Int64(1)
+		w.EndObject()
+	})
+	f("key without value", func(w *Writer) {
+		w.BeginObject()
+		w.Key("a")
+		w.EndObject()
+	})
+	f("two keys", func(w *Writer) {
+		w.BeginObject()
+		w.Key("a")
+		w.Key("b")
+		w.Null()
+		w.EndObject()
+	})
+	f("key in array", func(w *Writer) {
+		w.BeginArray()
+		w.Key("a")
+		w.EndArray()
+	})
+	f("top-level key", func(w *Writer) {
+		w.Key("a")
+	})
+	f("multiple values", func(w *Writer) {
+		w.Int64(1)
+		w.Int64(2)
+	})
+	f("invalid raw", func(w *Writer) {
+		w.Raw([]byte(`{"a":`))
+	})
+	f("raw with tail", func(w *Writer) {
+		w.Raw([]byte(`1 2`))
+	})
+	f("io error", func(w *Writer) {
+		w.Reset(&errorWriter{})
+		w.Int64(1)
+	})
+}
+
+func TestWriterSequence(t *testing.T) {
+	w := &Writer{
+		Sequence: true,
+	}
+	w.BeginObject()
+	w.Key("a")
+	w.Int64(1)
+	w.EndObject()
+	w.Int64(2)
+	w.String("x")
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	result := string(w.Bytes())
+	expected := "\x1e{\"a\":1}\n\x1e2\n\x1e\"x\"\n"
+	if result != expected {
+		t.Fatalf("unexpected result;\ngot\n%q\nwant\n%q", result, expected)
+	}
+
+	// The written sequence must be readable by Scanner.
+	sc := &Scanner{
+		Sequence: true,
+	}
+	sc.Init(result)
+	var values []string
+	for sc.Next() {
+		values = append(values, sc.Value().String())
+	}
+	if err := sc.Error(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if s := strings.Join(values, ","); s != `{"a":1},2,"x"` {
+		t.Fatalf("unexpected values; got %q; want %q", s, `{"a":1},2,"x"`)
+	}
+	if sc.BadRecords() != 0 {
+		t.Fatalf("unexpected bad records: %d", sc.BadRecords())
+	}
+
+	// Empty sequence is valid.
+	w.Reset(nil)
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}